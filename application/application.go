@@ -1,30 +1,154 @@
 package application
 
 import (
+	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/tpp/msf/application/handler"
+	"github.com/tpp/msf/application/middleware"
 	"github.com/tpp/msf/application/router"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/health"
 	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/worker"
 )
 
 type Config struct {
-	Port int `yaml:"port"`
+	Name    string `config:"name"`
+	Version string `config:"version"`
+	Port    int    `config:"port" default:"8080" validate:"min=1,max=65535"`
+	// MaxBodySize the limit in MB of the request bodies, default: 1
+	MaxBodySize int64 `config:"max_body_size" default:"1" validate:"gte=0"`
+	// MaxUploadSize the limit in MB of the multipart request bodies with files, default: 32
+	MaxUploadSize int64 `config:"max_upload_size" default:"32" validate:"gte=0"`
+	// CORSOrigins the origins allowed to call the API from a browser, default: all of them
+	CORSOrigins []string `config:"cors_origins"`
+	// TrustedProxies the IPs or CIDRs of the proxies whose X-Forwarded-For and X-Real-IP headers give the IP of the
+	// client, default: none, the IP of the connection is the one of the client
+	TrustedProxies []string `config:"trusted_proxies" validate:"dive,ip|cidr"`
+	// IdempotencyTTL how long in seconds the responses of the requests with an Idempotency-Key are replayed
+	IdempotencyTTL int64 `config:"idempotency_ttl" default:"86400" validate:"gte=0"`
+	// ReadHeaderTimeout seconds to read the headers of a request, default: 10
+	ReadHeaderTimeout time.Duration `config:"read_header_timeout" default:"10" validate:"gte=0"`
+	// ReadTimeout seconds to read a request with its body, default: 60
+	ReadTimeout time.Duration `config:"read_timeout" default:"60" validate:"gte=0"`
+	// WriteTimeout seconds to handle a request and write its response, default: 60
+	WriteTimeout time.Duration `config:"write_timeout" default:"60" validate:"gte=0"`
+	// IdleTimeout seconds a kept-alive connection waits for the next request, default: 120
+	IdleTimeout time.Duration `config:"idle_timeout" default:"120" validate:"gte=0"`
+	// MaxHeaderSize the limit in KB of the headers of a request, default: 64
+	MaxHeaderSize int `config:"max_header_size" default:"64" validate:"gte=0"`
+	// ShutdownTimeout seconds the in-flight requests and the background workers are awaited on shutdown, default: 30
+	ShutdownTimeout time.Duration `config:"shutdown_timeout" default:"30" validate:"gte=0"`
+	// ShutdownDelay seconds /readyz fails before the server stops accepting connections on shutdown, so that the load
+	// balancers stop sending it requests first, default: 5
+	ShutdownDelay time.Duration `config:"shutdown_delay" default:"5" validate:"gte=0"`
+	// HealthTimeout seconds a dependency check of /readyz and /admin/health may take, default: 2
+	HealthTimeout time.Duration `config:"health_timeout" default:"2" validate:"gte=0"`
+	// Metrics serve /metrics to the scrapers presenting MetricsToken, which is required then, default: true
+	Metrics bool `config:"metrics" default:"true"`
+	// MetricsToken the bearer token the scrapers present to read /metrics
+	MetricsToken string `config:"metrics_token" secret:"true" validate:"required_if=Metrics true"`
+	// TLSCertFile and TLSKeyFile the PEM files of the certificate and of its key, the server is served over HTTPS
+	// when they are set
+	TLSCertFile string `config:"tls_cert_file" validate:"required_with=TLSKeyFile,omitempty,file"`
+	TLSKeyFile  string `config:"tls_key_file" validate:"required_with=TLSCertFile,omitempty,file"`
 }
 
-func Run(c *Config) {
+// Configure apply the settings of the requests, before serving and when the configuration is reloaded
+func Configure(c *Config) {
+	middleware.SetCORSOrigins(c.CORSOrigins)
+	middleware.SetTrustedProxies(c.TrustedProxies)
+	base.SetBodyLimits(c.MaxBodySize, c.MaxUploadSize)
+	health.SetTimeout(c.HealthTimeout * time.Second)
+	if c.Metrics {
+		middleware.SetMetricsToken(c.MetricsToken)
+	} else {
+		middleware.SetMetricsToken("")
+	}
+}
+
+// Run serve until SIGTERM or SIGINT, then shut down gracefully: /readyz fails for ShutdownDelay, then the new
+// connections are refused, the in-flight requests then the background workers are awaited, for at most
+// ShutdownTimeout in all
+func Run(c *Config) error {
 	if c == nil {
 		c = &Config{Port: 8080}
 	}
+	Configure(c)
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", c.Port))
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer listener.Close()
 	router.SetupHandler(handler.New())
+	server := newServer(c)
 
-	log.Info().Msg(fmt.Sprintf("Start http server at :%d", c.Port))
-	log.Fatal().Err(http.Serve(listener, router.Router)).Msg("")
+	served := make(chan error, 1)
+	go func() {
+		if c.TLSCertFile != "" {
+			log.Info().Msg(fmt.Sprintf("Start https server at :%d", c.Port))
+			served <- server.ServeTLS(listener, c.TLSCertFile, c.TLSKeyFile)
+			return
+		}
+		log.Info().Msg(fmt.Sprintf("Start http server at :%d", c.Port))
+		served <- server.Serve(listener)
+	}()
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGTERM, os.Interrupt)
+	defer signal.Stop(stop)
+	select {
+	case err = <-served:
+		return err
+	case sig := <-stop:
+		log.Info().Str("signal", sig.String()).Msg("ShuttingDown")
+	}
+	health.SetShuttingDown()
+	select {
+	case <-time.After(c.ShutdownDelay * time.Second):
+	case sig := <-stop:
+		log.Info().Str("signal", sig.String()).Msg("ShutdownDelaySkipped")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout(c))
+	defer cancel()
+	err = server.Shutdown(ctx)
+	if stopErr := worker.Stop(ctx); err == nil {
+		err = stopErr
+	}
+	if err != nil {
+		return fmt.Errorf("shut down: %w", err)
+	}
+	if err = <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	log.Info().Msg("ShutDown")
+	return nil
+}
+
+func newServer(c *Config) *http.Server {
+	return &http.Server{
+		Handler:           router.Router,
+		ReadHeaderTimeout: c.ReadHeaderTimeout * time.Second,
+		ReadTimeout:       c.ReadTimeout * time.Second,
+		WriteTimeout:      c.WriteTimeout * time.Second,
+		IdleTimeout:       c.IdleTimeout * time.Second,
+		MaxHeaderBytes:    c.MaxHeaderSize << 10,
+		TLSConfig:         &tls.Config{MinVersion: tls.VersionTLS12},
+	}
+}
+
+func shutdownTimeout(c *Config) time.Duration {
+	if c.ShutdownTimeout <= 0 {
+		return 30 * time.Second
+	}
+	return c.ShutdownTimeout * time.Second
 }
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/go-chi/chi"
+	"github.com/tpp/msf/config"
+	"github.com/tpp/msf/domain/usecase/admin"
+	"github.com/tpp/msf/domain/usecase/rbac"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+)
+
+type Handler interface {
+	ListEmailTemplates(w http.ResponseWriter, r *http.Request) error
+	PreviewEmailTemplate(w http.ResponseWriter, r *http.Request) error
+	ExportRBAC(w http.ResponseWriter, r *http.Request) error
+	PlanRBAC(w http.ResponseWriter, r *http.Request) error
+	ApplyRBAC(w http.ResponseWriter, r *http.Request) error
+	ReloadConfig(w http.ResponseWriter, r *http.Request) error
+}
+
+type handler struct {
+	base.HTTPHandler
+	usecase admin.Usecase
+	rbac    rbac.Usecase
+}
+
+func (h *handler) ListEmailTemplates(w http.ResponseWriter, r *http.Request) error {
+	h.ResponseSuccess(w, h.usecase.EmailTemplates())
+	return nil
+}
+
+func (h *handler) PreviewEmailTemplate(w http.ResponseWriter, r *http.Request) error {
+
+	var previewReq previewEmailReq
+	ctx, err := h.Parse(r, &previewReq, base.ParseTypeParam)
+	if err != nil {
+		return err
+	}
+
+	if isValidationErrs, err := h.Validate(ctx, &previewReq); err != nil {
+		if isValidationErrs {
+			h.Debug(ctx).Err(err).Msg("InvalidPreviewEmailReq")
+		}
+		return err
+	}
+
+	message, err := h.usecase.PreviewEmail(chi.URLParamFromCtx(ctx, "name"), previewReq.Locale)
+	if err != nil {
+		h.Error(ctx).Err(err).Msg("PreviewEmailError")
+		return err
+	}
+
+	switch previewReq.Format {
+	case "html":
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(message.HTML))
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(message.Text))
+	default:
+		h.ResponseSuccess(w, message)
+	}
+	return nil
+
+}
+
+// ExportRBAC respond the roles and the permissions as a YAML policy, see ApplyRBAC
+func (h *handler) ExportRBAC(w http.ResponseWriter, r *http.Request) error {
+	ctx, _ := h.Parse(r, nil, base.ParseTypeNone)
+	// the policy is the base of the next import, it must not lag
+	h.Autocommit(ctx)
+	policy, err := h.rbac.Export(ctx)
+	if err != nil {
+		return err
+	}
+
+	var body bytes.Buffer
+	if err = rbac.WritePolicy(&body, policy); err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/yaml; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="rbac.yaml"`)
+	w.Write(body.Bytes())
+	return nil
+}
+
+// PlanRBAC respond the changes importing the policy of the body, nothing is changed
+func (h *handler) PlanRBAC(w http.ResponseWriter, r *http.Request) error {
+	var policy model.RBACPolicy
+	ctx, err := h.Parse(r, &policy, base.ParseTypeYAML)
+	if err != nil {
+		return err
+	}
+
+	h.Autocommit(ctx)
+	plan, err := h.rbac.Plan(ctx, &policy)
+	if err != nil {
+		return err
+	}
+	h.ResponseSuccess(w, plan)
+	return nil
+}
+
+// ApplyRBAC import the policy of the body, the changes are made in the transaction of the request and responded
+func (h *handler) ApplyRBAC(w http.ResponseWriter, r *http.Request) error {
+	var policy model.RBACPolicy
+	ctx, err := h.Parse(r, &policy, base.ParseTypeYAML)
+	if err != nil {
+		return err
+	}
+
+	plan, err := h.rbac.Apply(ctx, &policy)
+	if err != nil {
+		return err
+	}
+	h.Info(ctx).Uint64("user_id", ctx.User().ID).Int("changes", len(plan.Changes)).Msg("RBACImported")
+	h.ResponseSuccess(w, plan)
+	return nil
+}
+
+// ReloadConfig apply the configuration file and the environment without restarting, as SIGHUP does. An invalid
+// configuration is rejected, the current one is kept.
+func (h *handler) ReloadConfig(w http.ResponseWriter, r *http.Request) error {
+	ctx, _ := h.Parse(r, nil, base.ParseTypeNone)
+	cfg, err := config.Reload()
+	if err != nil {
+		h.Error(ctx).Err(err).Msg("ReloadConfigError")
+		var invalid *config.InvalidError
+		if errors.As(err, &invalid) {
+			return base.NewBadRequestError(base.CodeValidationFailed, err.Error())
+		}
+		return err
+	}
+	h.Info(ctx).Uint64("user_id", ctx.User().ID).Msg("ConfigReloaded")
+	h.ResponseSuccess(w, config.Redact(cfg))
+	return nil
+}
+
+func New() Handler {
+	return &handler{
+		HTTPHandler: base.NewBaseHTTPHandler("admin"),
+		usecase:     admin.New(),
+		rbac:        rbac.New(),
+	}
+}
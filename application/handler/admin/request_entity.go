@@ -0,0 +1,16 @@
+package admin
+
+import (
+	"github.com/tpp/msf/shared/validator"
+)
+
+var v = validator.Get()
+
+type previewEmailReq struct {
+	Locale string `schema:"locale" validate:"omitempty,bcp47_language_tag"`
+	Format string `schema:"format" validate:"omitempty,oneof=json html text"`
+}
+
+func (r *previewEmailReq) IsValid() error {
+	return v.Struct(r)
+}
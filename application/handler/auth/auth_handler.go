@@ -8,15 +8,21 @@ import (
 
 	"github.com/tpp/msf/domain/usecase/auth"
 	"github.com/tpp/msf/model"
+	helper "github.com/tpp/msf/shared/auth"
 	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/utils"
 )
 
 type Handler interface {
-	ListRoles(w http.ResponseWriter, r *http.Request)
-	Login(w http.ResponseWriter, r *http.Request)
-	ForgotPassword(w http.ResponseWriter, r *http.Request)
-	Get(w http.ResponseWriter, r *http.Request)
-	ListPermissions(w http.ResponseWriter, r *http.Request)
+	ListRoles(w http.ResponseWriter, r *http.Request) error
+	Login(w http.ResponseWriter, r *http.Request) error
+	Logout(w http.ResponseWriter, r *http.Request) error
+	RevokeSession(w http.ResponseWriter, r *http.Request) error
+	ForgotPassword(w http.ResponseWriter, r *http.Request) error
+	ResetPassword(w http.ResponseWriter, r *http.Request) error
+	ActivateAccount(w http.ResponseWriter, r *http.Request) error
+	Get(w http.ResponseWriter, r *http.Request) error
+	ListPermissions(w http.ResponseWriter, r *http.Request) error
 }
 
 type handler struct {
@@ -24,68 +30,53 @@ type handler struct {
 	usecase auth.Usecase
 }
 
-func (h *handler) ListRoles(w http.ResponseWriter, r *http.Request) {
+func (h *handler) ListRoles(w http.ResponseWriter, r *http.Request) error {
 	var reqParams base.ListParams
 	ctx, err := h.Parse(r, &reqParams, base.ParseTypeParam)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 	reqParams.EnsureDefault()
 	reqParams.RegisterFilterKeys(listAcceptedFilterKeys)
-	if isValidationErrs, err := h.Validate(&reqParams); err != nil {
+	if isValidationErrs, err := h.Validate(ctx, &reqParams); err != nil {
 		if isValidationErrs {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidListParams")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 	h.QueryOnly(ctx)
 	//role := ctx.Role()
 	// filters.0.key=full_name&filters.0.operator=like&filters.0.value=Hank
-	roles, err := h.usecase.ListRoles(ctx, reqParams.Limit, reqParams.Offset, reqParams.Sort, reqParams.Filters)
+	roles, page, err := h.usecase.ListRoles(ctx, &reqParams)
 	var listRoles []*model.ListRoleRes
 	for _, role := range roles {
 		listRoles = append(listRoles, role.MapRoleListModel())
 	}
 	if err != nil {
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
-	var totalPages int64
-	var total int64
-	h.ResponseSuccess(w, &base.Pagination{
-		Limit:     reqParams.Limit,
-		Offset:    reqParams.Offset,
-		Sort:      reqParams.Sort,
-		Total:     total,
-		TotalPage: totalPages,
-		List:      listRoles,
-	})
+	list, err := roleProjection.Project(&reqParams.Fieldset, listRoles)
+	if err != nil {
+		return err
+	}
+	h.ResponseSuccess(w, base.NewPagination(&reqParams, page, list))
+	return nil
 
 }
 
-func (h *handler) ListPermissions(w http.ResponseWriter, r *http.Request) {
+func (h *handler) ListPermissions(w http.ResponseWriter, r *http.Request) error {
 	var reqParams base.ListParams
 	ctx, err := h.Parse(r, &reqParams, base.ParseTypeParam)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 	reqParams.EnsureDefault()
 	reqParams.RegisterFilterKeys(listAcceptedFilterKeys)
-	if isValidationErrs, err := h.Validate(&reqParams); err != nil {
+	if isValidationErrs, err := h.Validate(ctx, &reqParams); err != nil {
 		if isValidationErrs {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidListParams")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 	h.QueryOnly(ctx)
 	permissions, err := h.usecase.ListPermission(ctx)
@@ -95,10 +86,10 @@ func (h *handler) ListPermissions(w http.ResponseWriter, r *http.Request) {
 		listPermission = append(listPermission, &permissonNew)
 	}
 	if err != nil {
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 	h.ResponseSuccess(w, listPermission)
+	return nil
 }
 
 func addFilter(role *model.Role, filters []*base.Filter) []*base.Filter {
@@ -115,12 +106,12 @@ func addFilter(role *model.Role, filters []*base.Filter) []*base.Filter {
 	}
 
 }
-func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
+func (h *handler) Get(w http.ResponseWriter, r *http.Request) error {
 
-	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	var fs base.Fieldset
+	ctx, err := h.Parse(r, &fs, base.ParseTypeQuery)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 	var roleID uint64
 	uStr := chi.URLParamFromCtx(ctx, "id")
@@ -129,104 +120,153 @@ func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
 	} else {
 		roleID, err = strconv.ParseUint(uStr, 10, 64)
 		if err != nil {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidRoleID")
-			return
+			return base.NewApiErrors("id", "invalid role id")
 		}
 	}
 
 	h.QueryOnly(ctx)
-	role, err := h.usecase.GetRole(ctx, roleID)
+	role, err := h.usecase.GetRole(ctx, roleID, &fs)
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
-	h.ResponseSuccess(w, role.MapRoleListModel())
+	data, err := roleProjection.Project(&fs, role.MapRoleListModel())
+	if err != nil {
+		return err
+	}
+	h.ResponseVersioned(w, r, data, role.Version)
+	return nil
 
 }
-func (h *handler) Login(w http.ResponseWriter, r *http.Request) {
+func (h *handler) Login(w http.ResponseWriter, r *http.Request) error {
 
 	var loginReq *loginReq
 	ctx, err := h.Parse(r, &loginReq, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 
-	if isValidationError, err := h.Validate(loginReq); err != nil {
+	if isValidationError, err := h.Validate(ctx, loginReq); err != nil {
 		if isValidationError {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidLoginReq")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 
-	h.QueryOnly(ctx)
-	user, accessToken, err := h.usecase.Login(ctx, loginReq.Email, loginReq.Password)
+	// the attempts are recorded even when the login fails
+	h.Autocommit(ctx)
+	user, accessToken, err := h.usecase.Login(ctx, loginReq.Email, loginReq.Password, utils.ClientFromRequest(r))
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, userRes{AccessToken: accessToken, Data: user})
+	return nil
+
+}
+
+func (h *handler) Logout(w http.ResponseWriter, r *http.Request) error {
+
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
 	if err != nil {
-		if _, ok := err.(base.APIErrors); ok {
-			h.ResponseBadRequest(w, err)
-			return
+		return err
+	}
+
+	err = h.usecase.Logout(ctx)
+	if err != nil {
+		return err
+	}
 
+	h.ResponseSuccess(w, nil, 204)
+	return nil
+
+}
+
+// RevokeSession revoke a session with the token of the new sign-in email, no login required
+func (h *handler) RevokeSession(w http.ResponseWriter, r *http.Request) error {
+
+	var revokeSessionReq *revokeSessionReq
+	ctx, err := h.Parse(r, &revokeSessionReq, base.ParseTypeJSON)
+	if err != nil {
+		return err
+	}
+
+	if isValidationError, err := h.Validate(ctx, revokeSessionReq); err != nil {
+		if isValidationError {
+			h.Debug(ctx).Err(err).Msg("InvalidRevokeSessionReq")
 		}
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
+		return err
+	}
+
+	err = h.usecase.RevokeSessionByToken(ctx, revokeSessionReq.Token)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, nil, 204)
+	return nil
+
+}
+
+// ResetPassword set the password with the token of the reset password email, no login required
+func (h *handler) ResetPassword(w http.ResponseWriter, r *http.Request) error {
+	return h.setPassword(w, r, helper.ActionResetPassword)
+}
+
+// ActivateAccount set the password with the token of the account activation email, no login required
+func (h *handler) ActivateAccount(w http.ResponseWriter, r *http.Request) error {
+	return h.setPassword(w, r, helper.ActionActivateAccount)
+}
+
+func (h *handler) setPassword(w http.ResponseWriter, r *http.Request, action string) error {
+
+	var setPasswordReq *setPasswordReq
+	ctx, err := h.Parse(r, &setPasswordReq, base.ParseTypeJSON)
+	if err != nil {
+		return err
+	}
+
+	if isValidationError, err := h.Validate(ctx, setPasswordReq); err != nil {
+		if isValidationError {
+			h.Debug(ctx).Err(err).Msg("InvalidSetPasswordReq")
 		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
-	h.ResponseSuccess(w, userRes{AccessToken: accessToken, Data: user})
+	if err = h.usecase.SetPasswordByToken(ctx, action, setPasswordReq.Token, setPasswordReq.Password); err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, nil, 204)
+	return nil
 
 }
 
 // forgot password
-func (h *handler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+func (h *handler) ForgotPassword(w http.ResponseWriter, r *http.Request) error {
 
 	var sendMailReq *sendMailReq
 	ctx, err := h.Parse(r, &sendMailReq, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 
-	if isValidationError, err := h.Validate(sendMailReq); err != nil {
+	if isValidationError, err := h.Validate(ctx, sendMailReq); err != nil {
 		if isValidationError {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidLoginReq")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 
 	h.QueryOnly(ctx)
 	message, err := h.usecase.ForgotPassword(ctx, sendMailReq.Email)
 	if err != nil {
-		if _, ok := err.(base.APIErrors); ok {
-			h.ResponseBadRequest(w, err)
-			return
-
-		}
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, emailRes{Message: message})
+	return nil
 
 }
 func New() Handler {
@@ -20,10 +20,36 @@ func (lr *loginReq) IsValid() error {
 type sendMailReq struct {
 	Email string `json:"email" validate:"required,email"`
 }
+
+// request to revoke a session from the new sign-in email
+type revokeSessionReq struct {
+	Token string `json:"token" validate:"required"`
+}
+
+func (lr *revokeSessionReq) IsValid() error {
+	return v.Struct(lr)
+}
+
+// request to set the password with the token of the reset password or of the activation email
+type setPasswordReq struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required,gte=6"`
+}
+
+func (lr *setPasswordReq) IsValid() error {
+	return v.Struct(lr)
+}
+
 type listParams = base.ListParams
 
 var listAcceptedFilterKeys = []string{"full_name", "email", "org_name", "role_name", "role_id"}
 
+// roleProjection the JSON keys of model.ListRoleRes for the fields and expand params
+var roleProjection = &base.Projection{
+	Relations: []string{"permissions"},
+	Computed:  []string{"total_users"},
+}
+
 // check for forgot password
 func (lr *sendMailReq) IsValid() error {
 	return v.Struct(lr)
@@ -2,6 +2,8 @@ package contracts
 
 import (
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 
@@ -9,11 +11,16 @@ import (
 	"github.com/tpp/msf/domain/usecase/contracts"
 	"github.com/tpp/msf/model"
 	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
 )
 
 type Handler interface {
-	List(w http.ResponseWriter, r *http.Request)
-	Get(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request) error
+	Get(w http.ResponseWriter, r *http.Request) error
+	UploadAttachments(w http.ResponseWriter, r *http.Request) error
+	ListAttachments(w http.ResponseWriter, r *http.Request) error
+	DownloadAttachment(w http.ResponseWriter, r *http.Request) error
+	DeleteAttachment(w http.ResponseWriter, r *http.Request) error
 }
 
 type handler struct {
@@ -21,75 +28,73 @@ type handler struct {
 	usecase contracts.Usecase
 }
 
-func (h *handler) List(w http.ResponseWriter, r *http.Request) {
+func (h *handler) List(w http.ResponseWriter, r *http.Request) error {
 	var reqParams base.ListParams
 	ctx, err := h.Parse(r, &reqParams, base.ParseTypeParam)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 
 	reqParams.EnsureDefault()
 	reqParams.RegisterFilterKeys(listAcceptedFilterKeys)
 
-	if isValidationErrs, err := h.Validate(&reqParams); err != nil {
+	if isValidationErrs, err := h.Validate(ctx, &reqParams); err != nil {
 		if isValidationErrs {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("IndalidListParams")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 	h.QueryOnly(ctx)
 
 	user := ctx.User()
-	filters := addFilter(user, reqParams.Filters)
+	reqParams.Filters = addFilter(user, reqParams.Filters)
 
-	contracts, total, err := h.usecase.List(ctx, reqParams.Limit, reqParams.Offset, reqParams.Sort, filters)
+	contracts, page, err := h.usecase.List(ctx, &reqParams)
 	if err != nil {
-		h.ResponseInternalServerError(w)
 		h.Error(ctx).Err(err).Msg("InvalidValidationError")
-		return
-	}
-	h.ResponseSuccess(w, &base.Pagination{
-		Limit:  reqParams.Limit,
-		Offset: reqParams.Offset,
-		Total:  total,
-		List:   contracts,
-	})
+		return err
+	}
+	list, err := contractProjection.Project(&reqParams.Fieldset, contracts)
+	if err != nil {
+		return err
+	}
+	h.ResponseSuccess(w, base.NewPagination(&reqParams, page, list))
+	return nil
 }
 
 func addFilter(user *model.User, filters []*base.Filter) []*base.Filter {
-
-	userpermissions := user.Roles[0].Permissions
-	needFilter := false
-	for _, p := range userpermissions {
-		if p.Name == "VIEW_CONTRACT_LIST" {
-			needFilter = true
-			break
-		}
-	}
-
-	if needFilter {
+	if vendorID, scoped := vendorScope(user); scoped {
 		return append(filters, &base.Filter{
 			Key:      "supply_vendor_id",
 			Operator: "eq",
-			Value:    fmt.Sprint(user.OrgID),
+			Value:    fmt.Sprint(vendorID),
 		})
-	} else {
-		return filters
 	}
+	return filters
+}
 
+// vendorScope the vendor whose contracts are the only ones user sees, when one of its roles grants
+// VIEW_CONTRACT_LIST. The admins are never scoped.
+func vendorScope(user *model.User) (vendorID uint64, scoped bool) {
+	if user.IsAdmin {
+		return 0, false
+	}
+	for _, role := range user.Roles {
+		for _, p := range role.Permissions {
+			if p.Name == "VIEW_CONTRACT_LIST" {
+				return user.OrgID, true
+			}
+		}
+	}
+	return 0, false
 }
 
-func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
+func (h *handler) Get(w http.ResponseWriter, r *http.Request) error {
 
-	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	var fs base.Fieldset
+	ctx, err := h.Parse(r, &fs, base.ParseTypeQuery)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 
 	var contractID uint64
@@ -97,25 +102,203 @@ func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
 
 	contractID, err = strconv.ParseUint(uStr, 10, 64)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		h.Debug(ctx).Err(err).Msg("InvalidUserID")
-		return
-
+		h.Debug(ctx).Err(err).Msg("InvalidContractID")
+		return base.NewApiErrors("id", "invalid contract id")
 	}
 
 	h.QueryOnly(ctx)
-	user, err := h.usecase.GetContract(ctx, contractID)
+	contract, err := h.usecase.GetContract(ctx, contractID, &fs)
+	if err != nil {
+		return err
+	}
+
+	data, err := contractProjection.Project(&fs, contract)
+	if err != nil {
+		return err
+	}
+	h.ResponseVersioned(w, r, data, contract.Version)
+	return nil
+
+}
+
+func (h *handler) UploadAttachments(w http.ResponseWriter, r *http.Request) error {
+	var req uploadAttachmentsReq
+	ctx, err := h.Parse(r, &req, base.ParseTypePath|base.ParseTypeMultipartForm)
+	if err != nil {
+		return err
+	}
+
+	// the files are stored while parsing, they are discarded unless attached
+	defer func() {
+		if err != nil {
+			h.usecase.DiscardBlobs(ctx, req.blobKeys()...)
+		}
+	}()
+
+	var isValidationErrs bool
+	if isValidationErrs, err = h.Validate(ctx, &req); err != nil {
+		if isValidationErrs {
+			h.Debug(ctx).Err(err).Msg("InvalidUploadAttachments")
+		}
+		return err
+	}
+
+	if _, err = h.scopedContract(ctx, req.ContractID); err != nil {
+		return err
+	}
+	if err = h.claimVersion(ctx, w, r, req.ContractID); err != nil {
+		return err
+	}
+
+	user := ctx.User()
+	attachments := req.mapAttachmentModels(req.ContractID, &user.ID)
+	if err = h.usecase.CreateAttachments(ctx, attachments); err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, attachments, http.StatusCreated)
+	return nil
+}
+
+func (h *handler) ListAttachments(w http.ResponseWriter, r *http.Request) error {
+	var reqParams base.ListParams
+	ctx, err := h.Parse(r, &reqParams, base.ParseTypeParam)
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-		} else {
-			h.ResponseInternalServerError(w)
+		return err
+	}
+
+	reqParams.EnsureDefault()
+	reqParams.RegisterFilterKeys(attachmentAcceptedFilterKeys)
+
+	if isValidationErrs, err := h.Validate(ctx, &reqParams); err != nil {
+		if isValidationErrs {
+			h.Debug(ctx).Err(err).Msg("InvalidListParams")
 		}
-		return
+		return err
+	}
+
+	contractID, err := pathID(ctx, "id", "invalid contract id")
+	if err != nil {
+		return err
+	}
+
+	h.QueryOnly(ctx)
+	if _, err = h.scopedContract(ctx, contractID); err != nil {
+		return err
+	}
+
+	attachments, page, err := h.usecase.ListAttachments(ctx, contractID, &reqParams)
+	if err != nil {
+		return err
+	}
+	list, err := attachmentProjection.Project(&reqParams.Fieldset, attachments)
+	if err != nil {
+		return err
+	}
+	h.ResponseSuccess(w, base.NewPagination(&reqParams, page, list))
+	return nil
+}
+
+func (h *handler) DownloadAttachment(w http.ResponseWriter, r *http.Request) error {
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	if err != nil {
+		return err
+	}
+
+	h.QueryOnly(ctx)
+	attachment, err := h.scopedAttachment(ctx)
+	if err != nil {
+		return err
+	}
+
+	rc, err := h.usecase.OpenAttachment(ctx, attachment)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err = io.Copy(w, rc); err != nil {
+		// the response has started, only the log tells the download was cut
+		h.Error(ctx).Err(err).Msg("DownloadAttachmentError")
+	}
+	return nil
+}
+
+func (h *handler) DeleteAttachment(w http.ResponseWriter, r *http.Request) error {
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	if err != nil {
+		return err
 	}
 
-	h.ResponseSuccess(w, user)
+	attachment, err := h.scopedAttachment(ctx)
+	if err != nil {
+		return err
+	}
+	if err = h.claimVersion(ctx, w, r, attachment.ContractID); err != nil {
+		return err
+	}
+	if err = h.usecase.DeleteAttachment(ctx, attachment); err != nil {
+		return err
+	}
 
+	h.ResponseSuccess(w, nil, http.StatusNoContent)
+	return nil
+}
+
+// scopedContract the contract contractID, not found when the user is restricted to the contracts of another vendor
+func (h *handler) scopedContract(ctx context.Context, contractID uint64) (*model.Contract, error) {
+	contract, err := h.usecase.GetContract(ctx, contractID, &base.Fieldset{})
+	if err != nil {
+		return nil, err
+	}
+	if vendorID, scoped := vendorScope(ctx.User()); scoped && contract.VendorID != vendorID {
+		h.Debug(ctx).Uint64("contract_id", contractID).Msg("ContractOfAnotherVendor")
+		return nil, base.ErrorNotFound
+	}
+	return contract, nil
+}
+
+// scopedAttachment the attachment of the path, see scopedContract
+func (h *handler) scopedAttachment(ctx context.Context) (*model.ContractAttachment, error) {
+	contractID, err := pathID(ctx, "id", "invalid contract id")
+	if err != nil {
+		return nil, err
+	}
+	attachmentID, err := pathID(ctx, "attachment_id", "invalid attachment id")
+	if err != nil {
+		return nil, err
+	}
+	if _, err = h.scopedContract(ctx, contractID); err != nil {
+		return nil, err
+	}
+	return h.usecase.GetAttachment(ctx, contractID, attachmentID)
+}
+
+// claimVersion check the If-Match header of r against the version of the contract then increment it for the
+// changes of the transaction, the new ETag of the contract is set on w
+func (h *handler) claimVersion(ctx context.Context, w http.ResponseWriter, r *http.Request, contractID uint64) error {
+	version, err := base.IfMatch(r)
+	if err != nil {
+		return err
+	}
+	if version, err = h.usecase.ClaimVersion(ctx, contractID, version); err != nil {
+		return err
+	}
+	w.Header().Set("ETag", base.ETag(version))
+	return nil
+}
+
+func pathID(ctx context.Context, key, msg string) (uint64, error) {
+	id, err := strconv.ParseUint(chi.URLParamFromCtx(ctx, key), 10, 64)
+	if err != nil {
+		return 0, base.NewApiErrors(key, msg)
+	}
+	return id, nil
 }
 
 func New() Handler {
@@ -1,3 +1,55 @@
 package contracts
 
+import (
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/validator"
+)
+
+var v = validator.Get()
+
 var listAcceptedFilterKeys = []string{"code", "start_date", "end_date", "supply_vendor_name", "base_amount", "actual_amount"}
+
+var attachmentAcceptedFilterKeys = []string{"name", "content_type", "size", "created_at"}
+
+// contractProjection the JSON keys of model.Contract for the fields and expand params
+var contractProjection = &base.Projection{
+	Relations: []string{"supply_vendor"},
+	JSONKeys:  map[string]string{"supply_vendor": "supply_vendor_name"},
+}
+
+var attachmentProjection = &base.Projection{}
+
+type uploadAttachmentsReq struct {
+	ContractID uint64       `schema:"id"`
+	Files      []*base.File `schema:"files" file:"files" validate:"required,max=10"`
+}
+
+func (r *uploadAttachmentsReq) IsValid() error {
+	return v.Struct(r)
+}
+
+// blobKeys the keys of the files stored while parsing
+func (r *uploadAttachmentsReq) blobKeys() []string {
+	keys := make([]string, len(r.Files))
+	for i, f := range r.Files {
+		keys[i] = f.Key
+	}
+	return keys
+}
+
+func (r *uploadAttachmentsReq) mapAttachmentModels(contractID uint64, uploadedBy *uint64) []*model.ContractAttachment {
+	attachments := make([]*model.ContractAttachment, len(r.Files))
+	for i, f := range r.Files {
+		attachments[i] = &model.ContractAttachment{
+			ContractID:  contractID,
+			Name:        f.Name,
+			ContentType: f.ContentType,
+			Size:        f.Size,
+			Checksum:    f.Checksum,
+			BlobKey:     f.Key,
+			UploadedBy:  uploadedBy,
+		}
+	}
+	return attachments
+}
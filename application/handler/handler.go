@@ -1,8 +1,10 @@
 package handler
 
 import (
+	"github.com/tpp/msf/application/handler/admin"
 	"github.com/tpp/msf/application/handler/auth"
 	"github.com/tpp/msf/application/handler/contracts"
+	"github.com/tpp/msf/application/handler/health"
 	"github.com/tpp/msf/application/handler/users"
 )
 
@@ -10,12 +12,16 @@ type Handler interface {
 	Users() users.Handler
 	Contracts() contracts.Handler
 	Auth() auth.Handler
+	Admin() admin.Handler
+	Health() health.Handler
 }
 
 type handler struct {
 	user     users.Handler
 	contract contracts.Handler
 	auth     auth.Handler
+	admin    admin.Handler
+	health   health.Handler
 }
 
 func (h *handler) Users() users.Handler {
@@ -28,10 +34,20 @@ func (h *handler) Auth() auth.Handler {
 	return h.auth
 }
 
+func (h *handler) Admin() admin.Handler {
+	return h.admin
+}
+
+func (h *handler) Health() health.Handler {
+	return h.health
+}
+
 func New() Handler {
 	return &handler{
 		user:     users.New(),
 		contract: contracts.New(),
 		auth:     auth.New(),
+		admin:    admin.New(),
+		health:   health.New(),
 	}
 }
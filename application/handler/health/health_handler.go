@@ -0,0 +1,61 @@
+package health
+
+import (
+	"net/http"
+
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/health"
+)
+
+type Handler interface {
+	Live(w http.ResponseWriter, r *http.Request) error
+	Ready(w http.ResponseWriter, r *http.Request) error
+	Health(w http.ResponseWriter, r *http.Request) error
+}
+
+type handler struct {
+	base.HTTPHandler
+}
+
+// Live respond the process is up, whatever its dependencies, for the liveness probes
+func (h *handler) Live(w http.ResponseWriter, r *http.Request) error {
+	h.ResponseSuccess(w, statusRes{Status: health.StatusUp})
+	return nil
+}
+
+// Ready respond whether the service can handle requests, for the readiness probes and the load balancers: 503 when a
+// dependency is down or the service is shutting down. The errors of the checks are only shown by Health.
+func (h *handler) Ready(w http.ResponseWriter, r *http.Request) error {
+	ctx, _ := h.Parse(r, nil, base.ParseTypeNone)
+	report := health.Run(ctx)
+
+	resp := readyRes{Status: report.Status, Checks: make(map[string]string, len(report.Checks))}
+	for name, result := range report.Checks {
+		resp.Checks[name] = result.Status
+	}
+	if report.Status != health.StatusUp {
+		h.Warn(ctx).Interface("checks", report.Checks).Str("status", report.Status).Msg("NotReady")
+		h.ResponseSuccess(w, resp, http.StatusServiceUnavailable)
+		return nil
+	}
+	h.ResponseSuccess(w, resp)
+	return nil
+}
+
+// Health respond the status of each dependency with the latency, the detail and the error of its check
+func (h *handler) Health(w http.ResponseWriter, r *http.Request) error {
+	ctx, _ := h.Parse(r, nil, base.ParseTypeNone)
+	report := health.Run(ctx)
+	if report.Status != health.StatusUp {
+		h.ResponseSuccess(w, report, http.StatusServiceUnavailable)
+		return nil
+	}
+	h.ResponseSuccess(w, report)
+	return nil
+}
+
+func New() Handler {
+	return &handler{
+		HTTPHandler: base.NewBaseHTTPHandler("health"),
+	}
+}
@@ -0,0 +1,11 @@
+package health
+
+type statusRes struct {
+	Status string `json:"status"`
+}
+
+type readyRes struct {
+	Status string `json:"status"`
+	// Checks the status of each dependency
+	Checks map[string]string `json:"checks"`
+}
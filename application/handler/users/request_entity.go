@@ -12,9 +12,20 @@ type listParams = base.ListParams
 
 var listAcceptedFilterKeys = []string{"full_name", "email", "org_name", "role_name", "role_id"}
 
+var loginHistoryAcceptedFilterKeys = []string{"success", "reason", "ip", "device", "created_at"}
+
+// userProjection the JSON keys of model.ListUserRes for the fields and expand params
+var userProjection = &base.Projection{
+	Relations: []string{"roles", "roles.permissions", "org"},
+	JSONKeys:  map[string]string{"last_login": "last_Login", "org": "orgs"},
+}
+
+var loginHistoryProjection = &base.Projection{}
+
 type createUserReq struct {
 	FullName string  `json:"full_name" schema:"full_name" validate:"required"`
 	Email    string  `json:"email" schema:"email" validate:"required,email"`
+	Locale   string  `json:"locale" schema:"locale" validate:"omitempty,bcp47_language_tag"`
 	Role     []int64 `json:"role"`
 }
 
@@ -25,6 +36,7 @@ func (r *createUserReq) mapUserModel() *model.User {
 	return &model.User{
 		FullName: r.FullName,
 		Email:    r.Email,
+		Locale:   r.Locale,
 		Status:   false,
 		IsAdmin:  false,
 	}
@@ -60,6 +72,14 @@ type PassWordReset struct {
 type UpdateName struct {
 	FullName string `json:"full_name" validate:"required"`
 }
+type UpdateLocale struct {
+	Locale string `json:"locale" validate:"required,bcp47_language_tag"`
+}
+
+func (l *UpdateLocale) IsValid() error {
+	return v.Struct(l)
+}
+
 type UpdateActive struct {
 	UserId   int  `json:"id"`
 	IsActive bool `json:"is_active"`
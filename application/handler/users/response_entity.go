@@ -1,8 +1,15 @@
 package users
 
+import "github.com/tpp/msf/model"
+
 //var v = validator.Get()
 
 //
 //type listParams = base.ListParams
 
 var listAcceptedFilterKeysNew = []string{"full_name", "name", "email", "status", "last_login"}
+
+type impersonateRes struct {
+	AccessToken string         `json:"access_token"`
+	Session     *model.Session `json:"session"`
+}
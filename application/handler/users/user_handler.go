@@ -3,7 +3,6 @@ package users
 import (
 	"fmt"
 	"gorm.io/gorm"
-	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,18 +12,26 @@ import (
 	"github.com/go-chi/chi"
 	"github.com/tpp/msf/domain/usecase/users"
 	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/utils"
 )
 
 type Handler interface {
-	List(w http.ResponseWriter, r *http.Request)
-	Get(w http.ResponseWriter, r *http.Request)
-	Create(w http.ResponseWriter, r *http.Request)
-	CreateRole(w http.ResponseWriter, r *http.Request)
-	AssignRole(w http.ResponseWriter, r *http.Request)
-	UpdatePassWord(w http.ResponseWriter, r *http.Request)
-	UpdateName(w http.ResponseWriter, r *http.Request)
-	UpdateActive(w http.ResponseWriter, r *http.Request)
-	AdminResetPWForUser(w http.ResponseWriter, r *http.Request)
+	List(w http.ResponseWriter, r *http.Request) error
+	Get(w http.ResponseWriter, r *http.Request) error
+	Create(w http.ResponseWriter, r *http.Request) error
+	CreateRole(w http.ResponseWriter, r *http.Request) error
+	AssignRole(w http.ResponseWriter, r *http.Request) error
+	UpdatePassWord(w http.ResponseWriter, r *http.Request) error
+	UpdateName(w http.ResponseWriter, r *http.Request) error
+	UpdateLocale(w http.ResponseWriter, r *http.Request) error
+	UpdateActive(w http.ResponseWriter, r *http.Request) error
+	AdminResetPWForUser(w http.ResponseWriter, r *http.Request) error
+	ListSessions(w http.ResponseWriter, r *http.Request) error
+	RevokeSession(w http.ResponseWriter, r *http.Request) error
+	RevokeSessions(w http.ResponseWriter, r *http.Request) error
+	ListLoginHistory(w http.ResponseWriter, r *http.Request) error
+	Impersonate(w http.ResponseWriter, r *http.Request) error
 }
 
 type handler struct {
@@ -32,54 +39,44 @@ type handler struct {
 	usecase users.Usecase
 }
 
-func (h *handler) List(w http.ResponseWriter, r *http.Request) {
+func (h *handler) List(w http.ResponseWriter, r *http.Request) error {
 
 	var reqParams listParams
 	ctx, err := h.Parse(r, &reqParams, base.ParseTypeParam)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 	reqParams.EnsureDefault()
 	reqParams.RegisterFilterKeys(listAcceptedFilterKeys)
 
-	if isValidationErrs, err := h.Validate(&reqParams); err != nil {
+	if isValidationErrs, err := h.Validate(ctx, &reqParams); err != nil {
 		if isValidationErrs {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidListParams")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 	h.QueryOnly(ctx)
 	user := ctx.User()
 	// filters.0.key=full_name&filters.0.operator=like&filters.0.value=Hank
-	filters := addFilter(user, reqParams.Filters)
-	users, total, err := h.usecase.ListUsers(ctx, reqParams.Limit, reqParams.Offset, reqParams.Sort, filters)
+	reqParams.Filters = addFilter(user, reqParams.Filters)
+	users, page, err := h.usecase.ListUsers(ctx, &reqParams)
 	var listUsers []*model.ListUserRes
 	for _, user := range users {
 		listUsers = append(listUsers, user.MapUserModel())
 	}
 	if err != nil {
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
-	if total == 0 {
+	if page.Total != nil && *page.Total == 0 {
 		h.ResponseSuccess(w, "No data to show")
-		return
-	}
-	var totalPages int64
-	totalPages = int64(math.Ceil(float64(total) / float64(reqParams.Limit)))
-	h.ResponseSuccess(w, &base.Pagination{
-		Limit:     reqParams.Limit,
-		Offset:    reqParams.Offset,
-		Sort:      reqParams.Sort,
-		Total:     total,
-		TotalPage: totalPages,
-		List:      listUsers,
-	})
+		return nil
+	}
+	list, err := userProjection.Project(&reqParams.Fieldset, listUsers)
+	if err != nil {
+		return err
+	}
+	h.ResponseSuccess(w, base.NewPagination(&reqParams, page, list))
+	return nil
 }
 
 func addFilter(user *model.User, filters []*base.Filter) []*base.Filter {
@@ -97,12 +94,12 @@ func addFilter(user *model.User, filters []*base.Filter) []*base.Filter {
 
 }
 
-func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
+func (h *handler) Get(w http.ResponseWriter, r *http.Request) error {
 
-	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	var fs base.Fieldset
+	ctx, err := h.Parse(r, &fs, base.ParseTypeQuery)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 
 	var userID uint64
@@ -114,331 +111,409 @@ func (h *handler) Get(w http.ResponseWriter, r *http.Request) {
 		// other wise, do convertion
 		userID, err = strconv.ParseUint(uStr, 10, 64)
 		if err != nil {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidUserID")
-			return
+			return base.NewApiErrors("id", "invalid user id")
 		}
 	}
 
 	h.QueryOnly(ctx)
-	user, err := h.usecase.GetUser(ctx, userID)
+	user, err := h.usecase.FindUser(ctx, userID, &fs)
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
-	h.ResponseSuccess(w, user.MapUserModel())
+	data, err := userProjection.Project(&fs, user.MapUserModel())
+	if err != nil {
+		return err
+	}
+	h.ResponseVersioned(w, r, data, user.Version)
+	return nil
 
 }
 
-func (h *handler) Create(w http.ResponseWriter, r *http.Request) {
+func (h *handler) Create(w http.ResponseWriter, r *http.Request) error {
 
 	var createUserReq *createUserReq
 	var err error
 	ctx, err := h.Parse(r, &createUserReq, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w)
-		return
+		return err
 	}
 
-	if isValidationError, err := h.Validate(createUserReq); err != nil {
+	if isValidationError, err := h.Validate(ctx, createUserReq); err != nil {
 		if isValidationError {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("validation error")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidationError")
-		return
+		return err
 	}
 
-	h.Start(ctx)
-	defer func() {
-		if err != nil {
-			h.Rollback(ctx)
-		} else {
-			h.Commit(ctx)
-		}
-	}()
-
 	var user = createUserReq.mapUserModel()
 	err = h.usecase.CreateUser(ctx, user)
 	if err != nil {
-		if _, ok := err.(base.APIErrors); ok {
-			h.ResponseBadRequest(w, err)
-			return
-		}
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 	var roles = createUserReq.Role
 	err = h.usecase.AssignMultipleRole(ctx, int64(user.ID), roles)
 	if err != nil {
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, "An email will be sent to user's email address with a link to activate the account", 201)
+	return nil
 
 }
 
-func (h *handler) CreateRole(w http.ResponseWriter, r *http.Request) {
+func (h *handler) CreateRole(w http.ResponseWriter, r *http.Request) error {
 
 	var createRoleReq *createRoleReq
 	var err error
 	ctx, err := h.Parse(r, &createRoleReq, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w)
-		return
+		return err
 	}
 
-	if isValidationError, err := h.Validate(createRoleReq); err != nil {
+	if isValidationError, err := h.Validate(ctx, createRoleReq); err != nil {
 		if isValidationError {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("validation error")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidationError")
-		return
+		return err
 	}
 
-	h.Start(ctx)
-	defer func() {
-		if err != nil {
-			h.Rollback(ctx)
-		} else {
-			h.Commit(ctx)
-		}
-	}()
-
 	var role = createRoleReq.mapRoleModel()
 	err = h.usecase.CreateRole(ctx, role)
 	if err != nil {
-		if _, ok := err.(base.APIErrors); ok {
-			h.ResponseBadRequest(w, err)
-			return
-		}
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, "Successfully", 201)
+	return nil
 
 }
 
-func (h *handler) AssignRole(w http.ResponseWriter, r *http.Request) {
+func (h *handler) AssignRole(w http.ResponseWriter, r *http.Request) error {
 
 	var assignRoleReq *assignRoleReq
 	var err error
 	ctx, err := h.Parse(r, &assignRoleReq, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w)
-		return
+		return err
 	}
 
 	if len(assignRoleReq.RoleIDs) == 0 {
 		if checkEmptyString(assignRoleReq.UserName) {
-			h.ResponseBadRequest(w, "nothing to change")
-			return
+			return base.NewBadRequestError(base.CodeBadRequest, "nothing to change")
 		}
 	}
 
-	h.Start(ctx)
-	defer func() {
-		if err != nil {
-			h.Rollback(ctx)
-		} else {
-			h.Commit(ctx)
-		}
-	}()
+	err = h.claimVersion(ctx, w, r, assignRoleReq.UserID)
+	if err == base.ErrorNotFound {
+		return base.NewApiErrors("user_id", "this user does not existed")
+	}
+	if err != nil {
+		return err
+	}
 
 	if len(assignRoleReq.RoleIDs) != 0 {
 		err = h.usecase.AssignRole(ctx, int64(assignRoleReq.UserID), assignRoleReq.RoleIDs)
 		if err != nil {
-			if _, ok := err.(base.APIErrors); ok {
-				h.ResponseBadRequest(w, err)
-				return
-
-			}
-			if err == base.ErrorNotFound {
-				h.ResponseNotFound(w)
-				return
-			}
-			h.ResponseInternalServerError(w)
-			return
+			return err
 		}
 	}
 
 	if !checkEmptyString(assignRoleReq.UserName) {
 		err = h.usecase.UpdateName(ctx, assignRoleReq.UserName, int64(assignRoleReq.UserID))
 		if err != nil {
-			if _, ok := err.(base.APIErrors); ok {
-				h.ResponseBadRequest(w, err)
-				return
-
-			}
-			if err == base.ErrorNotFound {
-				h.ResponseNotFound(w)
-				return
-			}
-			h.ResponseInternalServerError(w)
-			return
+			return err
 		}
 	}
 
 	h.ResponseSuccess(w, nil, 204)
+	return nil
 
 }
-func (h *handler) UpdatePassWord(w http.ResponseWriter, r *http.Request) {
+func (h *handler) UpdatePassWord(w http.ResponseWriter, r *http.Request) error {
 	var passWord *PassWordReset
 	ctx, err := h.Parse(r, &passWord, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
-	if isValidationError, err := h.Validate(passWord); err != nil {
+	if isValidationError, err := h.Validate(ctx, passWord); err != nil {
 		if isValidationError {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidPassword")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 
-	h.Start(ctx)
-	defer func() {
-		if err != nil {
-			h.Rollback(ctx)
-		} else {
-			h.Commit(ctx)
-		}
-	}()
+	if err = h.claimVersion(ctx, w, r, ctx.User().ID); err != nil {
+		return err
+	}
 	err = h.usecase.UpdatePassWord(ctx, int64(ctx.User().ID), passWord.Password)
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, "Password is changed")
+	return nil
 
 }
-func (h *handler) UpdateName(w http.ResponseWriter, r *http.Request) {
+func (h *handler) UpdateName(w http.ResponseWriter, r *http.Request) error {
 	var name *UpdateName
 	ctx, err := h.Parse(r, &name, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 	var checkName string = name.FullName
 	if checkEmptyString(checkName) {
-		h.Error(ctx).Msg("Name is required")
-		h.ResponseBadRequest(w, err)
-		return
+		h.Debug(ctx).Msg("Name is required")
+		return base.NewApiErrors("full_name", "Name is required")
+	}
+	if err = h.claimVersion(ctx, w, r, ctx.User().ID); err != nil {
+		return err
 	}
-	h.QueryOnly(ctx)
 	err = h.usecase.UpdateName(ctx, name.FullName, int64(ctx.User().ID))
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, "Your name is changed")
+	return nil
 
 }
-func (h *handler) UpdateActive(w http.ResponseWriter, r *http.Request) {
+func (h *handler) UpdateLocale(w http.ResponseWriter, r *http.Request) error {
+	var locale *UpdateLocale
+	ctx, err := h.Parse(r, &locale, base.ParseTypeJSON)
+	if err != nil {
+		return err
+	}
+	if isValidationError, err := h.Validate(ctx, locale); err != nil {
+		if isValidationError {
+			h.Debug(ctx).Err(err).Msg("InvalidLocale")
+		}
+		return err
+	}
+
+	if err = h.claimVersion(ctx, w, r, ctx.User().ID); err != nil {
+		return err
+	}
+	err = h.usecase.UpdateLocale(ctx, locale.Locale, int64(ctx.User().ID))
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, "Your language is changed")
+	return nil
+
+}
+func (h *handler) UpdateActive(w http.ResponseWriter, r *http.Request) error {
 	var active *UpdateActive
 	ctx, err := h.Parse(r, &active, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 
-	h.Start(ctx)
-	defer func() {
-		if err != nil {
-			h.Rollback(ctx)
-		} else {
-			h.Commit(ctx)
-		}
-	}()
+	if err = h.claimVersion(ctx, w, r, uint64(active.UserId)); err != nil {
+		return err
+	}
 	err = h.usecase.UpdateActive(ctx, int64(active.UserId), active.IsActive)
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, "active is changed")
+	return nil
 
 }
-func (h *handler) AdminResetPWForUser(w http.ResponseWriter, r *http.Request) {
+func (h *handler) AdminResetPWForUser(w http.ResponseWriter, r *http.Request) error {
 	var passWord *PassWordResetByAdmin
 	ctx, err := h.Parse(r, &passWord, base.ParseTypeJSON)
 	if err != nil {
-		h.ResponseBadRequest(w, err)
-		return
+		return err
 	}
 	var validatePassword *PassWordReset
 	validatePassword = validatePassword.setPassWordReset(passWord.Password)
-	if isValidationError, err := h.Validate(validatePassword); err != nil {
+	if isValidationError, err := h.Validate(ctx, validatePassword); err != nil {
 		if isValidationError {
-			h.ResponseBadRequest(w, err)
 			h.Debug(ctx).Err(err).Msg("InvalidPassword")
-			return
 		}
-		h.ResponseInternalServerError(w)
-		h.Error(ctx).Err(err).Msg("InvalidValidatorError")
-		return
+		return err
 	}
 
-	h.Start(ctx)
-	defer func() {
-		if err != nil {
-			h.Rollback(ctx)
-		} else {
-			h.Commit(ctx)
-		}
-	}()
+	err = h.claimVersion(ctx, w, r, uint64(passWord.UserId))
+	if err == base.ErrorNotFound {
+		return base.NewApiErrors("user_id", "user is not existed")
+	}
+	if err != nil {
+		return err
+	}
 	err = h.usecase.UpdatePassWord(ctx, int64(passWord.UserId), passWord.Password)
 	if err == gorm.ErrRecordNotFound {
-		h.ResponseBadRequest(w, "user is not existed")
+		return base.NewApiErrors("user_id", "user is not existed")
 	}
 	if err != nil {
-		if err == base.ErrorNotFound {
-			h.ResponseNotFound(w)
-			return
-		}
-		h.ResponseInternalServerError(w)
-		return
+		return err
 	}
 
 	h.ResponseSuccess(w, "user's password is changed")
+	return nil
+
+}
+
+// claimVersion check the If-Match header of r against the version of the user then increment it for the
+// changes of the transaction, the new ETag of the user is set on w
+func (h *handler) claimVersion(ctx context.Context, w http.ResponseWriter, r *http.Request, userID uint64) error {
+	version, err := base.IfMatch(r)
+	if err != nil {
+		return err
+	}
+	if version, err = h.usecase.ClaimVersion(ctx, userID, version); err != nil {
+		return err
+	}
+	w.Header().Set("ETag", base.ETag(version))
+	return nil
+}
+
+// sessionOwnerID the user given in the path, otherwise the current user
+func sessionOwnerID(ctx context.Context) (uint64, error) {
+	uStr := chi.URLParamFromCtx(ctx, "id")
+	if uStr == "" {
+		return ctx.User().ID, nil
+	}
+	return strconv.ParseUint(uStr, 10, 64)
+}
+
+func (h *handler) ListSessions(w http.ResponseWriter, r *http.Request) error {
+
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	if err != nil {
+		return err
+	}
+
+	userID, err := sessionOwnerID(ctx)
+	if err != nil {
+		h.Debug(ctx).Err(err).Msg("InvalidUserID")
+		return base.NewApiErrors("id", "invalid user id")
+	}
+
+	h.QueryOnly(ctx)
+	sessions, err := h.usecase.ListSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, sessions)
+	return nil
+
+}
+
+func (h *handler) RevokeSession(w http.ResponseWriter, r *http.Request) error {
+
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	if err != nil {
+		return err
+	}
+
+	userID, err := sessionOwnerID(ctx)
+	if err != nil {
+		h.Debug(ctx).Err(err).Msg("InvalidUserID")
+		return base.NewApiErrors("id", "invalid user id")
+	}
+	sessionID, err := strconv.ParseUint(chi.URLParamFromCtx(ctx, "session_id"), 10, 64)
+	if err != nil {
+		h.Debug(ctx).Err(err).Msg("InvalidSessionID")
+		return base.NewApiErrors("session_id", "invalid session id")
+	}
+
+	err = h.usecase.RevokeSession(ctx, userID, sessionID)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, nil, 204)
+	return nil
+
+}
+
+func (h *handler) RevokeSessions(w http.ResponseWriter, r *http.Request) error {
+
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	if err != nil {
+		return err
+	}
+
+	userID, err := sessionOwnerID(ctx)
+	if err != nil {
+		h.Debug(ctx).Err(err).Msg("InvalidUserID")
+		return base.NewApiErrors("id", "invalid user id")
+	}
+
+	err = h.usecase.RevokeSessions(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, nil, 204)
+	return nil
+
+}
+
+func (h *handler) ListLoginHistory(w http.ResponseWriter, r *http.Request) error {
+
+	var reqParams listParams
+	ctx, err := h.Parse(r, &reqParams, base.ParseTypeParam)
+	if err != nil {
+		return err
+	}
+	reqParams.EnsureDefault()
+	reqParams.RegisterFilterKeys(loginHistoryAcceptedFilterKeys)
+
+	if isValidationErrs, err := h.Validate(ctx, &reqParams); err != nil {
+		if isValidationErrs {
+			h.Debug(ctx).Err(err).Msg("InvalidListParams")
+		}
+		return err
+	}
+
+	userID, err := sessionOwnerID(ctx)
+	if err != nil {
+		h.Debug(ctx).Err(err).Msg("InvalidUserID")
+		return base.NewApiErrors("id", "invalid user id")
+	}
+
+	h.QueryOnly(ctx)
+	events, page, err := h.usecase.ListLoginHistory(ctx, userID, &reqParams)
+	if err != nil {
+		return err
+	}
+
+	list, err := loginHistoryProjection.Project(&reqParams.Fieldset, events)
+	if err != nil {
+		return err
+	}
+	h.ResponseSuccess(w, base.NewPagination(&reqParams, page, list))
+	return nil
+
+}
+
+func (h *handler) Impersonate(w http.ResponseWriter, r *http.Request) error {
+
+	ctx, err := h.Parse(r, nil, base.ParseTypeNone)
+	if err != nil {
+		return err
+	}
+
+	userID, err := strconv.ParseUint(chi.URLParamFromCtx(ctx, "id"), 10, 64)
+	if err != nil {
+		h.Debug(ctx).Err(err).Msg("InvalidUserID")
+		return base.NewApiErrors("id", "invalid user id")
+	}
+
+	session, accessToken, err := h.usecase.Impersonate(ctx, userID, utils.ClientFromRequest(r))
+	if err != nil {
+		return err
+	}
+
+	h.ResponseSuccess(w, impersonateRes{AccessToken: accessToken, Session: session}, 201)
+	return nil
 
 }
 
@@ -5,10 +5,10 @@ import (
 	"net/http"
 	"strings"
 
-	"github.com/tpp/msf/config"
 	"github.com/tpp/msf/domain/usecase/users"
 	"github.com/tpp/msf/external-adapter/db"
 	"github.com/tpp/msf/shared/auth"
+	"github.com/tpp/msf/shared/base"
 	"github.com/tpp/msf/shared/context"
 	"github.com/tpp/msf/shared/log"
 )
@@ -20,8 +20,11 @@ func Auth(next http.Handler) http.Handler {
 		ctx := context.FromBaseContext(r.Context())
 		logger := log.Logger.With().Str("req_id", ctx.ReqID()).Logger()
 
-		jwtHeader := config.GetConfig[string]("auth.header")
-		tokenSchema := config.GetConfig[string]("auth.token_schema")
+		settings := auth.Settings()
+		jwtHeader, tokenSchema := settings.Header, settings.TokenSchema
+		if jwtHeader == "" {
+			jwtHeader = "Authorization"
+		}
 		if tokenSchema == "" {
 			tokenSchema = "Bearer"
 		}
@@ -30,25 +33,66 @@ func Auth(next http.Handler) http.Handler {
 		// Check token string with format
 		if !strings.HasPrefix(strings.ToLower(tokenString), strings.ToLower(tokenSchema)+" ") {
 			logger.Error().Str("error", "wrong token chema format").Msg("AuthError")
-			w.WriteHeader(http.StatusUnauthorized)
+			base.ResponseError(w, r, base.NewUnauthorizedError("the access token is missing"))
 			return
 		}
 
 		tokenString = strings.Split(tokenString, " ")[1]
-		ID, err := auth.ParseJWTToken(tokenString)
+		claims, err := auth.ParseJWTClaims(tokenString)
 		if err != nil {
 			logger.Error().Err(err).Msg("AuthError")
-			w.WriteHeader(http.StatusUnauthorized)
+			base.ResponseError(w, r, base.NewUnauthorizedError("the access token is invalid"))
 			return
 		}
 
+		// every access token is tied to a session, the ones issued before the sessions are refused
+		if claims.TokenID == "" {
+			logger.Error().Int64("user_id", claims.UserID).Msg("SessionError")
+			base.ResponseError(w, r, base.NewUnauthorizedError("the access token is not tied to a session"))
+			return
+		}
 		var dbc = db.GetDBInstance()
+		if _, err = authUsecase.ValidateSession(context.Background().WithDBTx(dbc), claims.TokenID); err != nil {
+			logger.Error().Err(err).Msg("SessionError")
+			base.ResponseError(w, r, base.NewUnauthorizedError("the session is expired or revoked"))
+			return
+		}
+
 		var user *model.User
-		if user, err = authUsecase.GetUser(context.Background().WithDBTx(dbc), uint64(ID)); err != nil || ID == 0 {
-			w.WriteHeader(http.StatusUnauthorized)
+		if user, err = authUsecase.GetUser(context.Background().WithDBTx(dbc), uint64(claims.UserID)); err != nil || claims.UserID == 0 {
+			base.ResponseError(w, r, base.NewUnauthorizedError("the user of the access token does not exist"))
 			return
 		}
+		if !user.Status {
+			logger.Error().Uint64("user_id", user.ID).Msg("InactiveUserError")
+			base.ResponseError(w, r, base.NewUnauthorizedError("the user of the access token is not active"))
+			return
+		}
+
+		ctx.WithUser(user).WithTokenID(claims.TokenID)
+
+		if claims.ImpersonatorID != 0 {
+			var impersonator *model.User
+			if impersonator, err = authUsecase.GetUser(context.Background().WithDBTx(dbc), uint64(claims.ImpersonatorID)); err != nil || !impersonator.Status {
+				logger.Error().Err(err).Int64("impersonator_id", claims.ImpersonatorID).Msg("ImpersonatorError")
+				base.ResponseError(w, r, base.NewUnauthorizedError("the impersonator is not active"))
+				return
+			}
+			// the impersonation ends as soon as the impersonator may no longer impersonate
+			if !hasPermisson(impersonator, []string{"IMPERSONATE_USER"}) {
+				logger.Error().Uint64("impersonator_id", impersonator.ID).Msg("ImpersonatorPermissionError")
+				base.ResponseError(w, r, base.NewUnauthorizedError("the impersonator is no longer allowed to impersonate"))
+				return
+			}
+			ctx.WithImpersonator(impersonator)
+			logger.Info().
+				Uint64("impersonator_id", impersonator.ID).
+				Uint64("user_id", user.ID).
+				Str("method", r.Method).
+				Str("path", r.URL.Path).
+				Msg("ImpersonatedRequest")
+		}
 
-		next.ServeHTTP(w, r.WithContext(ctx.WithUser(user)))
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -2,15 +2,23 @@ package middleware
 
 import (
 	"net/http"
+	"regexp"
 
 	"github.com/rs/xid"
 	"github.com/tpp/msf/shared/context"
 )
 
+// reqIDPattern the request ids accepted from the clients, others are replaced by a generated one
+var reqIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID tag the request with the Request-Id header of the client, or a new id, and echo it in the response
 func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := context.FromBaseContext(r.Context())
-		reqID := xid.New().String()
+		reqID := r.Header.Get("Request-Id")
+		if !reqIDPattern.MatchString(reqID) {
+			reqID = xid.New().String()
+		}
 		ctx.WithReqID(reqID)
 		w.Header().Set("Request-Id", reqID)
 		next.ServeHTTP(w, r.WithContext(ctx))
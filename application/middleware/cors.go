@@ -2,13 +2,40 @@ package middleware
 
 import (
 	"net/http"
+	"sync/atomic"
 )
 
+// corsOrigins the map[string]bool of the origins allowed by CORS, all of them when it is empty
+var corsOrigins atomic.Value
+
+// SetCORSOrigins set the origins allowed to call the API from a browser, all of them when origins is empty or
+// holds *
+func SetCORSOrigins(origins []string) {
+	allowed := map[string]bool{}
+	for _, origin := range origins {
+		if origin == "*" {
+			allowed = map[string]bool{}
+			break
+		}
+		allowed[origin] = true
+	}
+	corsOrigins.Store(allowed)
+}
+
+func allowOrigin(origin string) bool {
+	allowed, _ := corsOrigins.Load().(map[string]bool)
+	return len(allowed) == 0 || allowed[origin]
+}
+
 func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", r.Header.Get("origin")) // change this later
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		w.Header().Add("Vary", "Origin")
+		if origin := r.Header.Get("origin"); allowOrigin(origin) {
+			w.Header().Set("Access-Control-Allow-Origin", origin)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+		}
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Request-Id, Idempotency-Key, Accept-Language, If-Match, If-None-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "Request-Id, Idempotent-Replayed, ETag")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
 
 		if r.Method == "OPTIONS" {
@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORS(t *testing.T) {
+	t.Cleanup(func() { SetCORSOrigins(nil) })
+	handler := CORS(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	allowedOrigin := func(origin string) string {
+		r := httptest.NewRequest(http.MethodGet, "/users", nil)
+		r.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w.Header().Get("Access-Control-Allow-Origin")
+	}
+
+	require.EqualValues(t, "https://any.example", allowedOrigin("https://any.example"))
+
+	SetCORSOrigins([]string{"https://app.example"})
+	require.EqualValues(t, "https://app.example", allowedOrigin("https://app.example"))
+	require.Empty(t, allowedOrigin("https://any.example"))
+
+	SetCORSOrigins([]string{"https://app.example", "*"})
+	require.EqualValues(t, "https://any.example", allowedOrigin("https://any.example"))
+}
@@ -0,0 +1,206 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tpp/msf/domain/usecase/idempotency"
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/worker"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// maxIdempotencyKeyLen the length of the idempotency_keys.key column
+	maxIdempotencyKeyLen = 255
+	// maxReplayBodySize the responses larger than it are not replayed, their key is released
+	maxReplayBodySize = 1 << 20
+	// spoolThreshold the request bodies larger than it are fingerprinted through a temporary file
+	spoolThreshold = 1 << 20
+	purgeInterval  = time.Hour
+)
+
+var purgeOnce sync.Once
+
+// Idempotency replay the response of a POST, PUT or PATCH request to its retries sent with the same
+// Idempotency-Key header, keys are scoped by user so it is placed after Auth. A key sent with a different
+// request is rejected, as is a retry while the first request is in progress. The 5xx responses are not
+// replayed, the request can be retried with the same key.
+func Idempotency(next http.Handler) http.Handler {
+	var idempotencyUsecase = idempotency.New()
+	purgeOnce.Do(func() {
+		worker.Every("idempotency-purge", purgeInterval, func(ctx context.Context) {
+			purgeIdempotencyKeys(ctx, idempotencyUsecase)
+		})
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := r.Header.Get(idempotencyKeyHeader)
+		if name == "" || (r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.FromBaseContext(r.Context())
+		logger := log.Logger.With().Str("req_id", ctx.ReqID()).Logger()
+		if len(name) > maxIdempotencyKeyLen {
+			base.ResponseError(w, r, base.NewApiErrors(idempotencyKeyHeader, "the key is longer than 255 characters"))
+			return
+		}
+
+		body, fingerprint, err := fingerprintRequest(r)
+		if err != nil {
+			base.ResponseError(w, r, base.NewBadRequestError(base.CodeMalformedRequest, "the request could not be read").WithCause(err))
+			return
+		}
+		defer body.Close()
+		r.Body = body
+
+		var userID uint64
+		if user := ctx.User(); user != nil {
+			userID = user.ID
+		}
+		dbCtx := context.Background().WithDBTx(db.GetDBInstance())
+		key, replay, err := idempotencyUsecase.Begin(dbCtx, userID, name, fingerprint)
+		if err != nil {
+			base.ResponseError(w, r, err)
+			return
+		}
+		if replay {
+			replayResponse(w, key)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			if completed {
+				return
+			}
+			// a panic or a failure, the request may be retried
+			if err := idempotencyUsecase.Release(dbCtx, key); err != nil {
+				logger.Error().Err(err).Str("key", name).Msg("ReleaseIdempotencyKeyError")
+			}
+		}()
+		next.ServeHTTP(rec, r)
+
+		if rec.status() >= http.StatusInternalServerError || rec.overflow {
+			return
+		}
+		if err = idempotencyUsecase.Complete(dbCtx, key, rec.status(), rec.Header().Get("Content-Type"), rec.body.Bytes()); err != nil {
+			logger.Error().Err(err).Str("key", name).Msg("CompleteIdempotencyKeyError")
+			return
+		}
+		completed = true
+	})
+}
+
+func replayResponse(w http.ResponseWriter, key *model.IdempotencyKey) {
+	if key.ContentType != "" {
+		w.Header().Set("Content-Type", key.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(key.Status)
+	w.Write(key.Body)
+}
+
+// fingerprintRequest the hex encoded sha256 of the method, the path and the body of r, along with a body to
+// read r again
+func fingerprintRequest(r *http.Request) (io.ReadCloser, string, error) {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	if r.Body == nil || r.Body == http.NoBody {
+		return http.NoBody, hex.EncodeToString(hash.Sum(nil)), nil
+	}
+	defer r.Body.Close()
+
+	var head bytes.Buffer
+	n, err := io.Copy(io.MultiWriter(&head, hash), io.LimitReader(r.Body, spoolThreshold+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if n <= spoolThreshold {
+		return io.NopCloser(&head), hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	f, err := os.CreateTemp("", "idempotency-*")
+	if err != nil {
+		return nil, "", err
+	}
+	spooled := &spooledBody{File: f}
+	if _, err = io.Copy(io.MultiWriter(f, hash), io.MultiReader(&head, r.Body)); err != nil {
+		spooled.Close()
+		return nil, "", err
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		spooled.Close()
+		return nil, "", err
+	}
+	return spooled, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// spooledBody a request body spooled into a temporary file, removed when closed
+type spooledBody struct {
+	*os.File
+}
+
+func (b *spooledBody) Close() error {
+	err := b.File.Close()
+	os.Remove(b.Name())
+	return err
+}
+
+// responseRecorder write the response through while keeping it to be replayed
+type responseRecorder struct {
+	http.ResponseWriter
+	code     int
+	body     bytes.Buffer
+	overflow bool
+}
+
+func (rec *responseRecorder) WriteHeader(code int) {
+	if rec.code == 0 {
+		rec.code = code
+	}
+	rec.ResponseWriter.WriteHeader(code)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.code == 0 {
+		rec.code = http.StatusOK
+	}
+	if !rec.overflow {
+		if rec.body.Len()+len(b) > maxReplayBodySize {
+			rec.overflow = true
+			rec.body.Reset()
+		} else {
+			rec.body.Write(b)
+		}
+	}
+	return rec.ResponseWriter.Write(b)
+}
+
+func (rec *responseRecorder) status() int {
+	if rec.code == 0 {
+		return http.StatusOK
+	}
+	return rec.code
+}
+
+func purgeIdempotencyKeys(ctx context.Context, u idempotency.Usecase) {
+	if n, err := u.Purge(ctx.WithDBTx(db.GetDBInstance())); err != nil {
+		log.Logger.Error().Err(err).Msg("PurgeIdempotencyKeysError")
+	} else if n > 0 {
+		log.Logger.Debug().Int64("count", n).Msg("PurgedIdempotencyKeys")
+	}
+}
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+)
+
+// DenyImpersonation block sensitive endpoints for requests made on behalf of an user
+func DenyImpersonation(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.FromBaseContext(r.Context())
+		if ctx.Impersonator() != nil {
+			base.ResponseError(w, r, base.NewForbiddenError("the endpoint is not available while impersonating"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
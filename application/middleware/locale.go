@@ -0,0 +1,19 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/validator"
+)
+
+// Locale tag the request with the translated locale the most preferred by its Accept-Language header,
+// the locale of the user profile takes precedence once authenticated
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.FromBaseContext(r.Context())
+		ctx.WithLocale(validator.NegotiateLocale(r.Header.Get("Accept-Language")))
+		w.Header().Add("Vary", "Accept-Language")
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
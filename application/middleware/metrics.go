@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi"
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/metrics"
+)
+
+// unmatchedRoute the route of the requests matching none, their paths would make the cardinality of the metrics
+// unbounded
+const unmatchedRoute = "unmatched"
+
+// metricsToken the token a scraper presents to read /metrics, they are not served when it is empty
+var metricsToken atomic.Value
+
+// SetMetricsToken set the bearer token required to read /metrics, they are not served when token is empty
+func SetMetricsToken(token string) {
+	metricsToken.Store(token)
+}
+
+// Metrics record the count and the duration of the requests by method, route pattern and status
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		metrics.ObserveRequest(r.Method, route, status, time.Since(start))
+	})
+}
+
+// MetricsAuth require the token of SetMetricsToken, sent as Authorization: Bearer <token>. Without token the
+// metrics are not found, they are never served to anyone.
+func MetricsAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, _ := metricsToken.Load().(string)
+		if token == "" {
+			base.ResponseError(w, r, base.ErrorNotFound)
+			return
+		}
+		sent := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(sent), []byte(token)) != 1 {
+			base.ResponseError(w, r, base.NewUnauthorizedError("the metrics token is missing or invalid"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/metrics"
+)
+
+func TestMetrics(t *testing.T) {
+	t.Cleanup(func() { SetMetricsToken("") })
+	router := chi.NewRouter()
+	router.Use(Metrics)
+	router.Get("/probe/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	router.With(MetricsAuth).Handle("/metrics", metrics.Handler())
+	serve := func(path, token string) *http.Response {
+		r := httptest.NewRequest(http.MethodGet, path, nil)
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, r)
+		return w.Result()
+	}
+
+	serve("/probe/1", "")
+	serve("/probe/2", "")
+	serve("/probe/unknown", "")
+
+	require.EqualValues(t, http.StatusNotFound, serve("/metrics", "").StatusCode)
+	SetMetricsToken("scraper")
+	require.EqualValues(t, http.StatusUnauthorized, serve("/metrics", "").StatusCode)
+	require.EqualValues(t, http.StatusUnauthorized, serve("/metrics", "intruder").StatusCode)
+	resp := serve("/metrics", "scraper")
+	require.EqualValues(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	lines := strings.Split(string(body), "\n")
+	require.Contains(t, lines, `msf_http_requests_total{method="GET",route="/probe/{id:[0-9]+}",status="418"} 2`)
+	require.Contains(t, lines, `msf_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	require.Contains(t, lines, `msf_http_requests_total{method="GET",route="/metrics",status="404"} 1`)
+	require.Contains(t, lines, `msf_http_requests_total{method="GET",route="/metrics",status="401"} 2`)
+	require.Contains(t, lines, `msf_http_request_duration_seconds_count{method="GET",route="/probe/{id:[0-9]+}",status="418"} 2`)
+}
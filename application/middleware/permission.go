@@ -2,9 +2,12 @@ package middleware
 
 import (
 	"net/http"
+	"strings"
 
 	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
 	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/metrics"
 )
 
 func Permit(permissions []string) func(http.Handler) http.Handler {
@@ -14,9 +17,10 @@ func Permit(permissions []string) func(http.Handler) http.Handler {
 			ctx := context.FromBaseContext(r.Context())
 			user := ctx.User()
 			if user == nil {
-				responseUnauthorizedError(w)
+				base.ResponseError(w, r, base.NewUnauthorizedError("the request is not authenticated"))
 			} else if !hasPermisson(user, permissions) {
-				responseForbiddenError(w)
+				metrics.CountPermissionDenied(permissionLabel(permissions))
+				base.ResponseError(w, r, base.NewForbiddenError("the user does not have the permission"))
 			} else {
 				next.ServeHTTP(w, r)
 			}
@@ -25,12 +29,13 @@ func Permit(permissions []string) func(http.Handler) http.Handler {
 	}
 }
 
-func responseUnauthorizedError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusUnauthorized)
-}
-
-func responseForbiddenError(w http.ResponseWriter) {
-	w.WriteHeader(http.StatusForbidden)
+// permissionLabel the permissions of the metrics, admin for the endpoints of the administrators only
+func permissionLabel(permissions []string) string {
+	label := strings.Join(permissions, "|")
+	if label == "" {
+		return "admin"
+	}
+	return label
 }
 
 func hasPermisson(user *model.User, permissions []string) bool {
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedProxies the []*net.IPNet of the proxies whose X-Forwarded-For and X-Real-IP headers are honoured
+var trustedProxies atomic.Value
+
+// SetTrustedProxies set the proxies, IPs or CIDRs, whose X-Forwarded-For and X-Real-IP headers give the IP of the
+// client, the headers of the others are ignored. The invalid entries, refused by the validation of the
+// configuration, are skipped.
+func SetTrustedProxies(proxies []string) {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil {
+				bits := 8 * len(ip)
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 8*net.IPv4len
+				}
+				nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			}
+			continue
+		}
+		if _, ipNet, err := net.ParseCIDR(proxy); err == nil {
+			nets = append(nets, ipNet)
+		}
+	}
+	trustedProxies.Store(nets)
+}
+
+func trusted(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	nets, _ := trustedProxies.Load().([]*net.IPNet)
+	for _, n := range nets {
+		if n.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RealIP set the remote address of the requests sent through a trusted proxy to the IP of the client: the last
+// address of X-Forwarded-For which is not a trusted proxy, else X-Real-IP
+func RealIP(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		if trusted(host) {
+			if ip := clientIP(r); ip != "" {
+				r.RemoteAddr = ip
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIP the IP of the client given by the headers of a trusted proxy, empty when they give none
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		hops := strings.Split(forwarded, ",")
+		// the hops are appended by each proxy, the ones before the first untrusted one can be forged by the client
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				return ""
+			}
+			if !trusted(hop) || i == 0 {
+				return hop
+			}
+		}
+	}
+	if ip := strings.TrimSpace(r.Header.Get("X-Real-IP")); net.ParseIP(ip) != nil {
+		return ip
+	}
+	return ""
+}
@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealIP(t *testing.T) {
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+	var remoteAddr string
+	handler := RealIP(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		remoteAddr = r.RemoteAddr
+	}))
+	clientIP := func(from string, headers map[string]string) string {
+		r := httptest.NewRequest(http.MethodGet, "/users", nil)
+		r.RemoteAddr = from
+		for k, v := range headers {
+			r.Header.Set(k, v)
+		}
+		handler.ServeHTTP(httptest.NewRecorder(), r)
+		return remoteAddr
+	}
+
+	// without a trusted proxy the headers are forged by the client
+	require.EqualValues(t, "203.0.113.7:4711", clientIP("203.0.113.7:4711", map[string]string{"X-Forwarded-For": "198.51.100.1"}))
+
+	SetTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"})
+	require.EqualValues(t, "198.51.100.1", clientIP("10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.1"}))
+	// the hops before the first untrusted one can be forged
+	require.EqualValues(t, "198.51.100.1", clientIP("10.1.2.3:80", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 192.0.2.1"}))
+	require.EqualValues(t, "198.51.100.2", clientIP("192.0.2.1:80", map[string]string{"X-Real-IP": "198.51.100.2"}))
+	require.EqualValues(t, "192.0.2.1:80", clientIP("192.0.2.1:80", map[string]string{"X-Forwarded-For": "not an ip"}))
+	require.EqualValues(t, "203.0.113.7:4711", clientIP("203.0.113.7:4711", map[string]string{"X-Real-IP": "198.51.100.2"}))
+}
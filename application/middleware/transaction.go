@@ -0,0 +1,99 @@
+package middleware
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+)
+
+// Transaction run the request in an unit of work: a transaction committed when the response is a 2xx, rolled
+// back on any other status or a panic. The response is held until the commit so the client is never told of
+// changes which are not durable, then the AfterCommit hooks of the request run.
+func Transaction(next http.Handler) http.Handler {
+	return unitOfWork(db.GetDBInstance(), next)
+}
+
+func unitOfWork(gDB *gorm.DB, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tx := gDB.Begin()
+		if tx.Error != nil {
+			base.ResponseError(w, r, fmt.Errorf("begin transaction: %w", tx.Error))
+			return
+		}
+
+		ctx := context.WithUnitOfWork(context.FromBaseContext(r.Context()), tx)
+		header := w.Header().Clone()
+		buf := &bufferedResponse{ResponseWriter: w}
+		committed := false
+		defer func() {
+			if !committed {
+				tx.Rollback()
+			}
+		}()
+		next.ServeHTTP(buf, r.WithContext(ctx))
+
+		if buf.status() < http.StatusOK || buf.status() >= http.StatusMultipleChoices {
+			// the version claimed by the handler, if any, is rolled back along with its changes
+			w.Header().Del("ETag")
+			if etag := header.Values("ETag"); len(etag) > 0 {
+				w.Header()["Etag"] = etag
+			}
+			buf.flush()
+			return
+		}
+		if err := tx.Commit().Error; err != nil {
+			// the response of the handler is discarded along with its headers
+			for key := range w.Header() {
+				w.Header().Del(key)
+			}
+			for key, values := range header {
+				w.Header()[key] = values
+			}
+			base.ResponseError(w, r, fmt.Errorf("commit transaction: %w", err))
+			return
+		}
+		committed = true
+		if user := ctx.User(); user != nil {
+			db.MarkWrite(user.ID)
+		}
+		buf.flush()
+		context.RunAfterCommit(ctx)
+	})
+}
+
+// bufferedResponse hold the response until flush, its headers are the ones of the underlying writer
+type bufferedResponse struct {
+	http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (b *bufferedResponse) WriteHeader(code int) {
+	if b.code == 0 {
+		b.code = code
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.code == 0 {
+		b.code = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) status() int {
+	if b.code == 0 {
+		return http.StatusOK
+	}
+	return b.code
+}
+
+func (b *bufferedResponse) flush() {
+	b.ResponseWriter.WriteHeader(b.status())
+	b.ResponseWriter.Write(b.body.Bytes())
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/utils"
+)
+
+func TestUnitOfWork(t *testing.T) {
+	tests := []struct {
+		name      string
+		handler   base.HandlerFunc
+		commitErr error
+		status    int
+		etag      string
+		hookRan   bool
+		panics    bool
+	}{
+		{
+			name: "committed",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("ETag", `"2"`)
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			},
+			status:  http.StatusNoContent,
+			etag:    `"2"`,
+			hookRan: true,
+		},
+		{
+			name: "rolled back on error",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				return base.ErrVersionConflict
+			},
+			status: http.StatusPreconditionFailed,
+		},
+		{
+			name: "rolled back after the version is claimed",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("ETag", `"2"`)
+				return base.NewForbiddenError("the role cannot be assigned")
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "rolled back on panic",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				panic("boom")
+			},
+			panics: true,
+		},
+		{
+			name: "commit failed",
+			handler: func(w http.ResponseWriter, r *http.Request) error {
+				w.Header().Set("ETag", `"2"`)
+				w.WriteHeader(http.StatusNoContent)
+				return nil
+			},
+			commitErr: errors.New("serialization failure"),
+			status:    http.StatusInternalServerError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gDB, mock, cnl, err := utils.NewDBMock()
+			require.NoError(t, err)
+			defer cnl()
+
+			mock.ExpectBegin()
+			switch {
+			case tt.commitErr != nil:
+				mock.ExpectCommit().WillReturnError(tt.commitErr)
+			case tt.hookRan:
+				mock.ExpectCommit()
+			default:
+				mock.ExpectRollback()
+			}
+
+			hookRan := false
+			next := base.Handle(func(w http.ResponseWriter, r *http.Request) error {
+				ctx := context.FromBaseContext(r.Context())
+				require.NotNil(t, context.DBTxFromContext(ctx))
+				ctx.AfterCommit(func() { hookRan = true })
+				return tt.handler(w, r)
+			})
+
+			w := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodPut, "/users/name", nil)
+			if tt.panics {
+				require.Panics(t, func() { unitOfWork(gDB, next).ServeHTTP(w, r) })
+			} else {
+				unitOfWork(gDB, next).ServeHTTP(w, r)
+				require.EqualValues(t, tt.status, w.Code)
+				require.EqualValues(t, tt.etag, w.Header().Get("ETag"))
+			}
+			require.EqualValues(t, tt.hookRan, hookRan)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
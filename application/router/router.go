@@ -7,6 +7,8 @@ import (
 	chimiddleware "github.com/go-chi/chi/middleware"
 	"github.com/tpp/msf/application/handler"
 	"github.com/tpp/msf/application/middleware"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/metrics"
 )
 
 var Router *chi.Mux
@@ -19,40 +21,98 @@ var (
 	cors   = middleware.CORS
 	auth   = middleware.Auth
 	reqID  = middleware.RequestID
+	locale = middleware.Locale
 	permit = middleware.Permit
+	// sensitive endpoints are not available while impersonating an user
+	noImp = middleware.DenyImpersonation
+	// idem replay the retries of a mutating request sent with the same Idempotency-Key
+	idem = middleware.Idempotency
+	// tx run the handlers modifying data in a transaction, committed on success only
+	tx = middleware.Transaction
+	// handle respond the error returned by a handler as a problem
+	handle = base.Handle
+	// instrument record the requests in the metrics, read by the scrapers holding the metrics token
+	instrument  = middleware.Metrics
+	metricsAuth = middleware.MetricsAuth
+	// realIP the IP of the client given by the trusted proxies only, the other clients could forge it
+	realIP = middleware.RealIP
 )
 
 func SetupHandler(h handler.Handler) {
-	Router.Use(cors, chimiddleware.Logger, reqID)
+	Router.Use(instrument, cors, realIP, chimiddleware.Logger, reqID, locale)
+	Router.NotFound(handle(func(w http.ResponseWriter, r *http.Request) error {
+		return base.NewNotFoundError("the route does not exist")
+	}))
+	Router.MethodNotAllowed(handle(func(w http.ResponseWriter, r *http.Request) error {
+		return base.NewError(http.StatusMethodNotAllowed, base.CodeMethodNotAllowed, "the method is not allowed on the route")
+	}))
 	apidocsHTTPHandler(Router)
 
+	// the probes of the orchestrator and of the load balancers, out of authentication
+	Router.Get("/healthz", handle(h.Health().Live))
+	Router.Get("/readyz", handle(h.Health().Ready))
+	Router.With(metricsAuth).Handle("/metrics", metrics.Handler())
+
 	Router.Route("/users", func(r chi.Router) {
 
-		r.With(auth, permit([]string{"VIEW_LIST_USER"})).Get("/", h.Users().List)
-		r.With(auth, permit([]string{"VIEW_CURRENT_USER"})).Get("/{id:[0-9]+}", h.Users().Get)
-		r.With(auth, permit([]string{""})).Post("/create", h.Users().Create)
-		r.With(auth, permit([]string{""})).Post("/create-role", h.Users().CreateRole)
-		r.With(auth, permit([]string{""})).Put("/assign-role", h.Users().AssignRole)
-		r.With(auth, permit([]string{""})).Put("/is-active", h.Users().UpdateActive)
-		r.With(auth).Put("/reset-password", h.Users().UpdatePassWord)
-		r.With(auth).Put("/name", h.Users().UpdateName)
-		r.With(auth, permit([]string{""})).Put("/admin-reset-password", h.Users().AdminResetPWForUser)
+		r.With(auth, permit([]string{"VIEW_LIST_USER"})).Get("/", handle(h.Users().List))
+		r.With(auth, permit([]string{"VIEW_CURRENT_USER"})).Get("/{id:[0-9]+}", handle(h.Users().Get))
+		r.With(auth, permit([]string{""}), idem, tx).Post("/create", handle(h.Users().Create))
+		r.With(auth, permit([]string{""}), idem, tx).Post("/create-role", handle(h.Users().CreateRole))
+		r.With(auth, permit([]string{""}), idem, tx).Put("/assign-role", handle(h.Users().AssignRole))
+		r.With(auth, permit([]string{""}), idem, tx).Put("/is-active", handle(h.Users().UpdateActive))
+		r.With(auth, noImp, idem, tx).Put("/reset-password", handle(h.Users().UpdatePassWord))
+		r.With(auth, idem, tx).Put("/name", handle(h.Users().UpdateName))
+		r.With(auth, idem, tx).Put("/locale", handle(h.Users().UpdateLocale))
+		r.With(auth, noImp, permit([]string{""}), idem, tx).Put("/admin-reset-password", handle(h.Users().AdminResetPWForUser))
+		r.With(auth, noImp, permit([]string{"IMPERSONATE_USER"}), idem, tx).Post("/{id:[0-9]+}/impersonate", handle(h.Users().Impersonate))
+		r.With(auth).Get("/sessions", handle(h.Users().ListSessions))
+		r.With(auth, tx).Delete("/sessions", handle(h.Users().RevokeSessions))
+		r.With(auth, tx).Delete("/sessions/{session_id:[0-9]+}", handle(h.Users().RevokeSession))
+		r.With(auth).Get("/login-history", handle(h.Users().ListLoginHistory))
+		r.With(auth, permit([]string{""})).Get("/{id:[0-9]+}/login-history", handle(h.Users().ListLoginHistory))
+		r.With(auth, permit([]string{""})).Get("/{id:[0-9]+}/sessions", handle(h.Users().ListSessions))
+		r.With(auth, permit([]string{""}), tx).Delete("/{id:[0-9]+}/sessions", handle(h.Users().RevokeSessions))
+		r.With(auth, permit([]string{""}), tx).Delete("/{id:[0-9]+}/sessions/{session_id:[0-9]+}", handle(h.Users().RevokeSession))
 	})
 
 	Router.Route("/contracts", func(r chi.Router) {
 
-		r.With(auth, permit([]string{"VIEW_ALL_CONTRACT_LIST", "VIEW_CONTRACT_LIST"})).Get("/", h.Contracts().List)
-		r.With(auth, permit([]string{"VIEW_ALL_CONTRACT_LIST", "VIEW_CONTRACT_LIST"})).Get("/{id:[0-9]+}", h.Contracts().Get)
+		r.With(auth, permit([]string{"VIEW_ALL_CONTRACT_LIST", "VIEW_CONTRACT_LIST"})).Get("/", handle(h.Contracts().List))
+		r.With(auth, permit([]string{"VIEW_ALL_CONTRACT_LIST", "VIEW_CONTRACT_LIST"})).Get("/{id:[0-9]+}", handle(h.Contracts().Get))
+		r.With(auth, permit([]string{"MANAGE_CONTRACT_ATTACHMENT"}), idem, tx).Post("/{id:[0-9]+}/attachments", handle(h.Contracts().UploadAttachments))
+		r.With(auth, permit([]string{"VIEW_ALL_CONTRACT_LIST", "VIEW_CONTRACT_LIST"})).Get("/{id:[0-9]+}/attachments", handle(h.Contracts().ListAttachments))
+		r.With(auth, permit([]string{"VIEW_ALL_CONTRACT_LIST", "VIEW_CONTRACT_LIST"})).Get("/{id:[0-9]+}/attachments/{attachment_id:[0-9]+}", handle(h.Contracts().DownloadAttachment))
+		r.With(auth, permit([]string{"MANAGE_CONTRACT_ATTACHMENT"}), tx).Delete("/{id:[0-9]+}/attachments/{attachment_id:[0-9]+}", handle(h.Contracts().DeleteAttachment))
 
 	})
 
 	Router.Route("/auth", func(r chi.Router) {
 
-		r.Post("/login", h.Auth().Login)
-		r.Post("/forgot-password", h.Auth().ForgotPassword)
-		r.With(auth, permit([]string{"VIEW_LIST_USER"})).Get("/roles", h.Auth().ListRoles)
-		r.With(auth, permit([]string{"VIEW_LIST_USER"})).Get("/roles/{id:[0-9]+}", h.Auth().Get)
-		r.With(auth, permit([]string{""})).Get("/permissions", h.Auth().ListPermissions)
+		// the failed logins are recorded whatever the outcome, login runs out of a transaction. Login and
+		// forgot password are not idempotent, their replayed responses would store access tokens shared
+		// by every anonymous client
+		r.Post("/login", handle(h.Auth().Login))
+		r.With(auth, idem, tx).Post("/logout", handle(h.Auth().Logout))
+		r.With(idem, tx).Post("/revoke-session", handle(h.Auth().RevokeSession))
+		r.Post("/forgot-password", handle(h.Auth().ForgotPassword))
+		r.With(idem, tx).Post("/reset-password", handle(h.Auth().ResetPassword))
+		r.With(idem, tx).Post("/activate", handle(h.Auth().ActivateAccount))
+		r.With(auth, permit([]string{"VIEW_LIST_USER"})).Get("/roles", handle(h.Auth().ListRoles))
+		r.With(auth, permit([]string{"VIEW_LIST_USER"})).Get("/roles/{id:[0-9]+}", handle(h.Auth().Get))
+		r.With(auth, permit([]string{""})).Get("/permissions", handle(h.Auth().ListPermissions))
+
+	})
+
+	Router.Route("/admin", func(r chi.Router) {
+
+		r.With(auth, permit([]string{""})).Get("/email-templates", handle(h.Admin().ListEmailTemplates))
+		r.With(auth, permit([]string{""})).Get("/email-templates/{name}/preview", handle(h.Admin().PreviewEmailTemplate))
+		r.With(auth, permit([]string{""})).Get("/rbac", handle(h.Admin().ExportRBAC))
+		r.With(auth, permit([]string{""})).Post("/rbac/plan", handle(h.Admin().PlanRBAC))
+		r.With(auth, noImp, permit([]string{""}), idem, tx).Put("/rbac", handle(h.Admin().ApplyRBAC))
+		r.With(auth, noImp, permit([]string{""})).Post("/config/reload", handle(h.Admin().ReloadConfig))
+		r.With(auth, permit([]string{""})).Get("/health", handle(h.Health().Health))
 
 	})
 
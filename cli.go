@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/validator"
+)
+
+// the exit codes of the commands
+const (
+	exitOK      = 0
+	exitFailure = 1
+	exitUsage   = 2
+)
+
+const usage = `usage: main [command]
+
+commands:
+  serve                  run the http server, the default command
+  migrate                migrate the schema of the database
+  user create            create an user, an administrator with --admin
+  user reset-password    set the password of an user
+  role grant             grant roles to an user
+  rbac export            write the roles and the permissions as a YAML policy
+  rbac import            plan, and apply with --apply, the changes importing a YAML policy
+  token issue            issue an access token of an user, for debugging
+  config validate        check the configuration
+
+Run main <command> -h for the flags of a command, all of them print their result as JSON with --json.
+`
+
+// the outputs of the commands
+var stdout, stderr io.Writer = os.Stdout, os.Stderr
+
+// command a command of the binary, returns the exit code
+type command func(cfg *Config, args []string) int
+
+var commands = map[string]command{
+	"serve": func(cfg *Config, args []string) int {
+		return serve(cfg)
+	},
+	"migrate": runMigrate,
+	"user": subcommands("user", map[string]command{
+		"create":         runUserCreate,
+		"reset-password": runUserResetPassword,
+	}),
+	"role": subcommands("role", map[string]command{
+		"grant": runRoleGrant,
+	}),
+	"rbac": subcommands("rbac", map[string]command{
+		"export": runRBACExport,
+		"import": runRBACImport,
+	}),
+	"token": subcommands("token", map[string]command{
+		"issue": runTokenIssue,
+	}),
+	"config": subcommands("config", map[string]command{
+		"validate": runConfigValidate,
+	}),
+}
+
+// run the command of args, serve when there is none
+func run(cfg *Config, args []string) int {
+	if len(args) == 0 {
+		args = []string{"serve"}
+	}
+	switch args[0] {
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return exitOK
+	}
+	cmd := commands[args[0]]
+	if cmd == nil {
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	return cmd(cfg, args[1:])
+}
+
+// subcommands a command dispatching to the subcommand named by its first argument
+func subcommands(name string, subs map[string]command) command {
+	return func(cfg *Config, args []string) int {
+		if len(args) == 0 || subs[args[0]] == nil {
+			var names []string
+			for sub := range subs {
+				names = append(names, sub)
+			}
+			sort.Strings(names)
+			fmt.Fprintf(stderr, "usage: main %s <%s>\n", name, strings.Join(names, "|"))
+			return exitUsage
+		}
+		return subs[args[0]](cfg, args[1:])
+	}
+}
+
+// newFlagSet the flags of the command name along with --json, the result of the command is printed by the printer
+func newFlagSet(name, synopsis string) (*flag.FlagSet, *printer) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: main %s %s\n\n", name, synopsis)
+		fs.PrintDefaults()
+	}
+	p := &printer{out: stdout, errOut: stderr}
+	fs.SetOutput(stderr)
+	fs.BoolVar(&p.json, "json", false, "print the result as JSON")
+	return fs, p
+}
+
+// parseFlags parse args, the exit code is returned when the command must stop: -h or an usage error
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintf(fs.Output(), "unexpected argument %q\n", fs.Arg(0))
+		fs.Usage()
+		return exitUsage, false
+	}
+	return exitOK, true
+}
+
+// printer print the result of a command, as JSON with --json
+type printer struct {
+	out    io.Writer
+	errOut io.Writer
+	json   bool
+}
+
+// result print v as JSON, otherwise the text of format, returns the exit code of a success
+func (p *printer) result(v any, format string, a ...any) int {
+	if p.json {
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+	} else {
+		fmt.Fprintf(p.out, format+"\n", a...)
+	}
+	return exitOK
+}
+
+// fail print err, as {"error": ...} with --json, returns the exit code of a failure
+func (p *printer) fail(err error) int {
+	var apiErrors base.APIErrors
+	isAPIErrors := errors.As(err, &apiErrors)
+	if p.json {
+		res := map[string]any{"error": err.Error()}
+		if isAPIErrors {
+			res["error"] = "invalid arguments"
+			res["errors"] = []*base.APIError(apiErrors)
+		}
+		enc := json.NewEncoder(p.out)
+		enc.SetIndent("", "  ")
+		enc.Encode(res)
+		return exitFailure
+	}
+	if isAPIErrors {
+		for _, e := range apiErrors {
+			fmt.Fprintf(p.errOut, "error: %s: %s\n", e.Field, e.Message)
+		}
+		return exitFailure
+	}
+	fmt.Fprintf(p.errOut, "error: %s\n", err)
+	return exitFailure
+}
+
+// validate args with the validate tags of its fields, the field of the errors are the flags named by their schema tag
+func validate(args any) error {
+	err := validator.Get().Struct(args)
+	var ve validator.ValidationErrors
+	if !errors.As(err, &ve) {
+		return err
+	}
+
+	var apiErrors = base.APIErrors{}
+	for _, fe := range ve {
+		apiErrors = append(apiErrors, &base.APIError{
+			Field:   fe.Field(),
+			Message: validator.Message(validator.DefaultLocale, fe),
+		})
+	}
+	return apiErrors
+}
+
+// setup run the inits of the adapters a command needs, their panics are returned as errors
+func setup(inits ...func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	for _, init := range inits {
+		init()
+	}
+	return nil
+}
+
+// inUnitOfWork run fn in a transaction as the Transaction middleware runs the requests: committed when fn succeeds,
+// then the AfterCommit hooks run
+func inUnitOfWork(fn func(ctx context.Context) error) error {
+	tx := db.GetDBInstance().Begin()
+	if tx.Error != nil {
+		return fmt.Errorf("begin transaction: %w", tx.Error)
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			tx.Rollback()
+		}
+	}()
+
+	ctx := context.WithUnitOfWork(context.Background(), tx)
+	if err := fn(ctx); err != nil {
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("commit transaction: %w", err)
+	}
+	committed = true
+	context.RunAfterCommit(ctx)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/application"
+	"github.com/tpp/msf/external-adapter/blob"
+	"github.com/tpp/msf/external-adapter/db"
+	mailer "github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/shared/auth"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/log"
+)
+
+func captureOutput(t *testing.T) (*bytes.Buffer, *bytes.Buffer) {
+	var out, errOut bytes.Buffer
+	previousOut, previousErr := stdout, stderr
+	stdout, stderr = &out, &errOut
+	t.Cleanup(func() { stdout, stderr = previousOut, previousErr })
+	return &out, &errOut
+}
+
+func TestRun_usage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		code int
+	}{
+		{"help", []string{"help"}, exitOK},
+		{"unknown command", []string{"launch"}, exitUsage},
+		{"missing subcommand", []string{"user"}, exitUsage},
+		{"unknown subcommand", []string{"role", "revoke"}, exitUsage},
+		{"unknown flag", []string{"user", "create", "--superuser"}, exitUsage},
+		{"unexpected argument", []string{"token", "issue", "7"}, exitUsage},
+		{"flags help", []string{"config", "validate", "-h"}, exitOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			captureOutput(t)
+			require.EqualValues(t, tt.code, run(&Config{}, tt.args))
+		})
+	}
+}
+
+func TestRun_invalidArguments(t *testing.T) {
+	out, _ := captureOutput(t)
+	require.EqualValues(t, exitFailure, run(&Config{}, []string{"user", "create", "--email", "nope", "--json"}))
+
+	var res struct {
+		Error  string           `json:"error"`
+		Errors []*base.APIError `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+	require.EqualValues(t, "invalid arguments", res.Error)
+	require.EqualValues(t, []*base.APIError{
+		{Field: "email", Message: "invalid email format"},
+		{Field: "name", Message: "This field is required"},
+	}, res.Errors)
+}
+
+func TestRun_configValidate(t *testing.T) {
+	valid := &Config{
+		Service: &application.Config{Port: 9080},
+		Logger:  &log.Config{Level: "info"},
+		DB:      &db.Config{Host: "localhost", Port: 5432, DBName: "auth"},
+		Auth:    &auth.Config{Secret: "secret", Claim: &auth.ClaimConfig{ExpireIn: 3600}},
+		Mailer:  &mailer.Config{Kind: "memory"},
+		Blob:    &blob.Config{Kind: "memory"},
+	}
+	out, _ := captureOutput(t)
+	require.EqualValues(t, exitOK, run(valid, []string{"config", "validate"}))
+	require.EqualValues(t, "the configuration is valid\n", out.String())
+
+	invalid := &Config{
+		Service: &application.Config{},
+		Logger:  &log.Config{Level: "loud"},
+		Mailer:  &mailer.Config{Kind: "pigeon"},
+		Blob:    &blob.Config{Kind: "memory"},
+	}
+	out.Reset()
+	require.EqualValues(t, exitFailure, run(invalid, []string{"config", "validate", "--json"}))
+	var res configValidateResult
+	require.NoError(t, json.Unmarshal(out.Bytes(), &res))
+	require.False(t, res.Valid)
+	require.EqualValues(t, []string{
+		"service.port: This field value must be at least 1",
+		"logger.level: This field must be one of 'trace debug info warn error fatal'",
+		"db: This field is required",
+		"auth: This field is required",
+		`mailer: unsupported mailer kind "pigeon"`,
+	}, res.Problems)
+}
+
+func TestPrinter_fail(t *testing.T) {
+	var out, errOut bytes.Buffer
+	p := &printer{out: &out, errOut: &errOut}
+	require.EqualValues(t, exitFailure, p.fail(errors.New("user 7 does not exist")))
+	require.EqualValues(t, "error: user 7 does not exist\n", errOut.String())
+	require.Empty(t, out.String())
+
+	errOut.Reset()
+	p.fail(base.NewApiErrors("email", "email already existed"))
+	require.EqualValues(t, "error: email: email already existed\n", errOut.String())
+}
+
+func TestIdsFlag(t *testing.T) {
+	var ids idsFlag
+	require.NoError(t, ids.Set("1,2"))
+	require.NoError(t, ids.Set("5"))
+	require.EqualValues(t, idsFlag{1, 2, 5}, ids)
+	require.EqualValues(t, "1,2,5", ids.String())
+	require.Error(t, ids.Set("admin"))
+	require.Error(t, ids.Set("0"))
+}
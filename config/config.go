@@ -1,16 +1,57 @@
 package config
 
 import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
 	"github.com/fsnotify/fsnotify"
 	"github.com/mitchellh/mapstructure"
 	"github.com/spf13/viper"
 	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/validator"
 )
 
 const (
 	configCommonFile = "config"
+	// envPrefix the prefix of the environment variables overriding the keys, e.g. CONFIG_DB_PASS for db.pass
+	envPrefix = "CONFIG"
+	// fileSuffix the suffix of the key naming the file holding a secret, e.g. db.pass_file or CONFIG_DB_PASS_FILE
+	fileSuffix = "_file"
+	// redacted the value of the secrets in the logs
+	redacted = "******"
+)
+
+var validate = validator.New("config")
+
+var (
+	// mu serialize the loads of the configuration
+	mu sync.Mutex
+	// loaded the configuration of Load, replaced by the reloads
+	loaded      any
+	subscribers []subscriber
 )
 
+// subscriber a component applying the reloaded configuration, see Subscribe
+type subscriber struct {
+	name    string
+	prepare func(c any) (swap func(), err error)
+}
+
+// InvalidError the problems of a configuration rejected by Reload
+type InvalidError struct {
+	Problems []string
+}
+
+func (e *InvalidError) Error() string {
+	return "invalid configuration: " + strings.Join(e.Problems, "; ")
+}
+
 func init() {
 	viper.SetConfigName(configCommonFile)
 	viper.SetConfigType("yaml")
@@ -20,20 +61,240 @@ func init() {
 
 	viper.OnConfigChange(func(e fsnotify.Event) {
 		log.Logger.Warn().Msg("Config file changed: " + e.Name)
+		if _, err := Reload(); err != nil {
+			log.Logger.Error().Err(err).Msg("ReloadConfigError")
+		}
 	})
 	viper.WatchConfig()
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
 	viper.AutomaticEnv()
-	viper.SetEnvPrefix("CONFIG")
 }
 
+// Load unmarshal the configuration into c, a pointer to a struct. The fields are tagged with:
+//   - config: the key of the field, the keys of the fields of a nested struct are prefixed by its key
+//   - default: the value of the key when neither the file nor the environment sets it
+//   - secret:"true": the value is redacted from the logs and can be read from the file named by <key>_file
+//   - validate: the rules checked by Validate
+//
+// The environment variable CONFIG_<KEY>, the dots of the key replaced by _, overrides the key of the file, e.g.
+// CONFIG_DB_PASS for db.pass. The file of a secret, e.g. CONFIG_DB_PASS_FILE=/run/secrets/db_pass, overrides both.
 func Load(c any) {
-	if err := viper.Unmarshal(&c, func(dc *mapstructure.DecoderConfig) {
-		dc.TagName = "config"
-	}); err != nil {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := load(c); err != nil {
 		panic(err)
 	}
+	loaded = c
+
+	log.Logger.Info().Interface("config", Redact(c)).Msg("")
+}
 
-	log.Logger.Info().Interface("config", c).Msg("")
+// Subscribe register the component name to apply the reloaded configuration: prepare builds the component from c, of
+// the type of the configuration of Load, and returns the swap installing it. The swaps run once all the subscribers
+// prepared c, none of them runs when one fails.
+func Subscribe(name string, prepare func(c any) (swap func(), err error)) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers = append(subscribers, subscriber{name: name, prepare: prepare})
+}
+
+// Reload read the file, the environment and the secret files again into a new configuration, which replaces the one
+// of Load once the subscribers swapped their components. An invalid configuration, or one a subscriber fails to
+// prepare, is rejected with an InvalidError, the components keep the current one.
+func Reload() (any, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if loaded == nil {
+		return nil, errors.New("the configuration is not loaded")
+	}
+
+	var notFound viper.ConfigFileNotFoundError
+	if err := viper.ReadInConfig(); err != nil && !errors.As(err, &notFound) {
+		return nil, &InvalidError{Problems: []string{err.Error()}}
+	}
+	next := reflect.New(reflect.TypeOf(loaded).Elem()).Interface()
+	if err := load(next); err != nil {
+		return nil, &InvalidError{Problems: []string{err.Error()}}
+	}
+	if problems := Validate(next); len(problems) > 0 {
+		return nil, &InvalidError{Problems: problems}
+	}
+
+	var problems []string
+	swaps := make([]func(), 0, len(subscribers))
+	for _, s := range subscribers {
+		swap, err := s.prepare(next)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", s.name, err))
+			continue
+		}
+		swaps = append(swaps, swap)
+	}
+	if len(problems) > 0 {
+		return nil, &InvalidError{Problems: problems}
+	}
+	for _, swap := range swaps {
+		if swap != nil {
+			swap()
+		}
+	}
+	loaded = next
+
+	log.Logger.Info().Interface("config", Redact(next)).Msg("ConfigReloaded")
+	return next, nil
+}
+
+// load the configuration into c, see Load
+func load(c any) error {
+	fields := fieldsOf(reflect.TypeOf(c), "")
+	for _, f := range fields {
+		viper.BindEnv(f.key)
+		if f.def != "" {
+			viper.SetDefault(f.key, f.def)
+		}
+		if f.secret {
+			viper.BindEnv(f.key + fileSuffix)
+		}
+	}
+	for _, f := range fields {
+		if !f.secret {
+			continue
+		}
+		if err := readSecretFile(f.key); err != nil {
+			return err
+		}
+	}
+
+	return viper.Unmarshal(c, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "config"
+		dc.DecodeHook = mapstructure.ComposeDecodeHookFunc(
+			stringToSecondsHookFunc,
+			mapstructure.StringToSliceHookFunc(","),
+		)
+	})
+}
+
+// Validate the problems of c, a pointer to a struct loaded by Load, e.g. "db.port: This field value must be at
+// most 65535"
+func Validate(c any) []string {
+	err := validate.Struct(c)
+	if err == nil {
+		return nil
+	}
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return []string{err.Error()}
+	}
+	problems := make([]string, 0, len(errs))
+	for _, fe := range errs {
+		// the namespace starts with the name of the type of c
+		key := fe.Namespace()
+		if i := strings.IndexByte(key, '.'); i >= 0 {
+			key = key[i+1:]
+		}
+		problems = append(problems, fmt.Sprintf("%s: %s", key, validator.Message(validator.DefaultLocale, fe)))
+	}
+	return problems
+}
+
+// Redact the keys and the values of c, the secrets replaced, to be logged
+func Redact(c any) any {
+	return redact(reflect.ValueOf(c))
+}
+
+func redact(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redact(v.Elem())
+	case reflect.Struct:
+		values := map[string]any{}
+		for i := 0; i < v.NumField(); i++ {
+			sf := v.Type().Field(i)
+			if !sf.IsExported() {
+				continue
+			}
+			key := keyOf(sf)
+			if sf.Tag.Get("secret") == "true" && !v.Field(i).IsZero() {
+				values[key] = redacted
+				continue
+			}
+			values[key] = redact(v.Field(i))
+		}
+		return values
+	default:
+		return v.Interface()
+	}
+}
+
+// field a key of the configuration
+type field struct {
+	key    string
+	def    string
+	secret bool
+}
+
+// fieldsOf the keys of the fields of t, the fields of the nested structs included
+func fieldsOf(t reflect.Type, prefix string) []field {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var fields []field
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+		key := prefix + keyOf(sf)
+		ft := sf.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+		if ft.Kind() == reflect.Struct {
+			fields = append(fields, fieldsOf(ft, key+".")...)
+			continue
+		}
+		fields = append(fields, field{key: key, def: sf.Tag.Get("default"), secret: sf.Tag.Get("secret") == "true"})
+	}
+	return fields
+}
+
+func keyOf(sf reflect.StructField) string {
+	if key := sf.Tag.Get("config"); key != "" {
+		return key
+	}
+	return strings.ToLower(sf.Name)
+}
+
+// readSecretFile set key to the content of the file named by <key>_file, if any
+func readSecretFile(key string) error {
+	path := viper.GetString(key + fileSuffix)
+	if path == "" {
+		return nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("%s%s: %w", key, fileSuffix, err)
+	}
+	viper.Set(key, strings.TrimRight(string(content), "\r\n"))
+	return nil
+}
+
+// stringToSecondsHookFunc decode the durations of the environment as the ones of the file, a number of seconds
+func stringToSecondsHookFunc(f reflect.Type, t reflect.Type, data any) (any, error) {
+	if f.Kind() != reflect.String || t != reflect.TypeOf(time.Duration(0)) {
+		return data, nil
+	}
+	seconds, err := strconv.ParseInt(strings.TrimSpace(data.(string)), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of seconds %q", data)
+	}
+	return seconds, nil
 }
 
 func GetConfig[V int64 | string | float64](key string) V {
@@ -50,8 +311,3 @@ func GetConfig[V int64 | string | float64](key string) V {
 
 	return ret.(V)
 }
-
-// GetConfigByte get []byte from config file.
-func GetConfigByte(key string) []byte {
-	return []byte(viper.GetString(key))
-}
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testDB struct {
+	Host     string        `config:"host" validate:"required"`
+	Port     int           `config:"port" default:"5432" validate:"min=1,max=65535"`
+	Password string        `config:"pass" secret:"true"`
+	Timeout  time.Duration `config:"timeout" default:"10"`
+	Replicas []string      `config:"replicas" secret:"true"`
+}
+
+type testConfig struct {
+	Name string  `config:"name" default:"auth"`
+	DB   *testDB `config:"db" validate:"required"`
+}
+
+func TestLoad(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "db_pass")
+	require.NoError(t, os.WriteFile(secret, []byte("s3cret\n"), 0o600))
+	t.Setenv("CONFIG_DB_HOST", "db.internal")
+	t.Setenv("CONFIG_DB_TIMEOUT", "30")
+	t.Setenv("CONFIG_DB_PASS", "overridden by the file")
+	t.Setenv("CONFIG_DB_PASS_FILE", secret)
+
+	cfg := &testConfig{}
+	Load(cfg)
+	require.EqualValues(t, &testConfig{
+		Name: "auth",
+		DB:   &testDB{Host: "db.internal", Port: 5432, Password: "s3cret", Timeout: 30},
+	}, cfg)
+	require.Empty(t, Validate(cfg))
+
+	require.EqualValues(t, map[string]any{
+		"name": "auth",
+		"db": map[string]any{
+			"host":     "db.internal",
+			"port":     5432,
+			"pass":     redacted,
+			"timeout":  time.Duration(30),
+			"replicas": []string(nil),
+		},
+	}, Redact(cfg))
+}
+
+func TestValidate(t *testing.T) {
+	require.EqualValues(t, []string{"db: This field is required"}, Validate(&testConfig{}))
+	require.EqualValues(t, []string{
+		"db.host: This field is required",
+		"db.port: This field value must be at most 65535",
+	}, Validate(&testConfig{DB: &testDB{Port: 70000}}))
+}
+
+func TestReload(t *testing.T) {
+	t.Setenv("CONFIG_DB_HOST", "db.internal")
+	cfg := &testConfig{}
+	Load(cfg)
+
+	var applied *testConfig
+	Subscribe("db", func(c any) (func(), error) {
+		next := c.(*testConfig)
+		if next.DB.Host == "unreachable" {
+			return nil, errors.New("could not connect")
+		}
+		return func() { applied = next }, nil
+	})
+
+	t.Setenv("CONFIG_DB_PORT", "70000")
+	_, err := Reload()
+	require.EqualValues(t, &InvalidError{Problems: []string{"db.port: This field value must be at most 65535"}}, err)
+	require.Nil(t, applied)
+
+	t.Setenv("CONFIG_DB_PORT", "6432")
+	t.Setenv("CONFIG_DB_HOST", "unreachable")
+	_, err = Reload()
+	require.EqualValues(t, &InvalidError{Problems: []string{"db: could not connect"}}, err)
+	require.Nil(t, applied)
+
+	t.Setenv("CONFIG_DB_HOST", "db.replica")
+	reloaded, err := Reload()
+	require.NoError(t, err)
+	require.Same(t, reloaded, applied)
+	require.EqualValues(t, "db.replica", applied.DB.Host)
+	require.EqualValues(t, 6432, applied.DB.Port)
+	require.EqualValues(t, "db.internal", cfg.DB.Host)
+}
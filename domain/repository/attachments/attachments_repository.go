@@ -0,0 +1,75 @@
+//go:generate mockery --name=Repository
+package attachments
+
+import (
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, attachments []*model.ContractAttachment) error
+	ListByContractID(ctx context.Context, contractID uint64, params *base.ListParams) ([]*model.ContractAttachment, *base.Page, error)
+	Get(ctx context.Context, contractID, attachmentID uint64) (*model.ContractAttachment, error)
+	Delete(ctx context.Context, attachment *model.ContractAttachment) error
+}
+
+type repo struct {
+	base.Repository
+}
+
+func (r *repo) Create(ctx context.Context, attachments []*model.ContractAttachment) error {
+	if err := r.DB(ctx).Create(attachments).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("StoreContractAttachmentError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) ListByContractID(ctx context.Context, contractID uint64, params *base.ListParams) ([]*model.ContractAttachment, *base.Page, error) {
+	var attachments []*model.ContractAttachment
+
+	page, err := r.FindPage(r.DB(ctx).Model(&model.ContractAttachment{}).Where("contract_id = ?", contractID), params, &attachments)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		r.Error(ctx).Err(err).Msg("ListContractAttachmentError")
+		return []*model.ContractAttachment{}, nil, err
+	}
+	return attachments, page, nil
+}
+
+func (r *repo) Get(ctx context.Context, contractID, attachmentID uint64) (*model.ContractAttachment, error) {
+	var attachment *model.ContractAttachment
+	err := r.DB(ctx).
+		Where("contract_id = ?", contractID).
+		Take(&attachment, attachmentID).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, base.ErrorNotFound
+		}
+		r.Error(ctx).Err(err).Msg("GetContractAttachmentError")
+		return nil, err
+	}
+	return attachment, nil
+}
+
+func (r *repo) Delete(ctx context.Context, attachment *model.ContractAttachment) error {
+	if err := r.DB(ctx).Delete(attachment).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeleteContractAttachmentError")
+		return err
+	}
+	return nil
+}
+
+func New() Repository {
+	baseRepo := base.NewBaseRepository("attachments")
+
+	baseRepo.RegisterKeyType("size", base.KeyTypeNumber)
+	baseRepo.RegisterKeyType("created_at", base.KeyTypeDate)
+
+	baseRepo.RegisterSortKeys("name", "content_type", "size", "created_at")
+
+	baseRepo.RegisterFields("contract_id", "name", "content_type", "size", "checksum", "uploaded_by", "created_at")
+
+	return &repo{baseRepo}
+}
@@ -10,12 +10,13 @@ import (
 )
 
 type Repository interface {
-	List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Role, error)
+	List(ctx context.Context, params *base.ListParams) ([]*model.Role, *base.Page, error)
 	GetUserByEmail(ctx context.Context, email string) (user *model.User, pass string, err error)
-	GetUserId(ctx context.Context, email string) (id uint64, err error)
+	GetUserPassword(ctx context.Context, userID uint64) (user *model.User, pass string, err error)
 	UpdateTimeLastLogin(ctx context.Context, UserId int64) error
 	CountRoleByUserID(ctx context.Context, roleId int64) (int64, error)
-	GetRole(ctx context.Context, roleID uint64) (*model.Role, error)
+	// GetRole the active role with the fields and the expansions of fs
+	GetRole(ctx context.Context, roleID uint64, fs *base.Fieldset) (*model.Role, error)
 	ListPermission(ctx context.Context) ([]*model.Permission, error)
 	GetPermissionByRoleId(ctx context.Context, permissionId int64) (*model.Permission, error)
 }
@@ -70,12 +71,11 @@ func (r *repo) ListPermission(ctx context.Context) ([]*model.Permission, error)
 	}
 	return permissions, nil
 }
-func (r *repo) GetRole(ctx context.Context, roleID uint64) (*model.Role, error) {
+func (r *repo) GetRole(ctx context.Context, roleID uint64, fs *base.Fieldset) (*model.Role, error) {
 	var role *entity.Role
 	err := r.DB(ctx).
 		Model(&entity.Role{}).Where("status= true").
-		Preload("Permissions").
-		//Preload( " id IN (SELECT role_id FROM user_role WHERE status = true AND user_id = ?)", roleID).
+		Scopes(r.Select(fs, "version")).
 		Take(&role, roleID).Error
 	if err != nil {
 		r.Error(ctx).Err(err).Msg("Get Role ID Error")
@@ -103,52 +103,52 @@ func (r *repo) GetUserByEmail(ctx context.Context, email string) (*model.User, s
 	return user.User, user.Password, nil
 }
 
-// compare email received vs email in database and return email
-func (r *repo) GetUserId(ctx context.Context, mail string) (uint64, error) {
-	var users *entity.User
+// GetUserPassword the user userID, active or not, with its hashed password, nil when there is none
+func (r *repo) GetUserPassword(ctx context.Context, userID uint64) (*model.User, string, error) {
+	var user *entity.User
 	if err := r.DB(ctx).
-		Where("email = ?", mail).Where("status = ?", true).
-		/*Preload("Org").
-		Preload("Roles.Permissions").*/
-		Take(&users).
+		Where("id = ?", userID).
+		Take(&user).
 		Error; err != nil {
-		r.Error(ctx).Err(err).Msg("GetUserError")
 		if err == gorm.ErrRecordNotFound {
-			return 0, base.NewApiErrors("email", "This email is not registered")
+			return nil, "", nil
 		}
-		return 0, err
+		r.Error(ctx).Err(err).Msg("GetUserError")
+		return nil, "", err
 	}
-	return users.ID, nil
+	return user.User, user.Password, nil
 }
-func (r *repo) List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Role, error) {
+func (r *repo) List(ctx context.Context, params *base.ListParams) ([]*model.Role, *base.Page, error) {
 	var roles []*entity.Role
-	var total int64
-	offset = offset * limit
-	err := r.DB(ctx).
-		Model(&entity.Role{}).
-		Scopes(r.Filter(filters)).
-		Count(&total).
-		Scopes(r.Paginate(limit, offset, sort)).
-		Preload("Permissions").
-		Find(&roles).Error
+	page, err := r.FindPage(r.DB(ctx).Model(&entity.Role{}), params, &roles)
 
 	if err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return []*model.Role{}, nil
+			return []*model.Role{}, page, nil
 		}
 		r.Error(ctx).Err(err).Msg("List Roles Error")
-		return nil, err
+		return nil, nil, err
 	}
-	return roles, nil
+	return roles, page, nil
 }
 
 func New() Repository {
-	return &repo{base.NewBaseRepository("auth")}
+	baseRepo := base.NewBaseRepository("auth")
+
+	baseRepo.RegisterSortKeys("name", "created_at")
+
+	baseRepo.RegisterFields("name", "status", "created_at", "updated_at")
+	baseRepo.RegisterExpansion("permissions", "Permissions")
+	baseRepo.RegisterDefaultExpand("permissions")
+
+	return &repo{baseRepo}
 }
 func (r *repo) UpdateTimeLastLogin(ctx context.Context, UserId int64) error {
 	time := time.Now().Format("2006-01-02 15:04:05")
 	if err := r.DB(ctx).Model(&entity.User{}).
-		Where("id = ?", UserId).Update("last_login", time).
+		Where("id = ?", UserId).
+		// the last login is part of the user, its ETag changes along
+		Updates(map[string]any{"last_login": time, "version": gorm.Expr("version + 1")}).
 		Error; err != nil {
 		r.Error(ctx).Err(err).Msg("update fail")
 		if err == gorm.ErrRecordNotFound {
@@ -10,42 +10,37 @@ import (
 )
 
 type Repository interface {
-	List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Contract, int64, error)
-	Get(ctx context.Context, contractID uint64) (*model.Contract, error)
+	List(ctx context.Context, params *base.ListParams) ([]*model.Contract, *base.Page, error)
+	// Get the contract with the fields and the expansions of fs
+	Get(ctx context.Context, contractID uint64, fs *base.Fieldset) (*model.Contract, error)
+	// BumpContractVersion increment the version of the contract when it is still version, 0 matches any version
+	BumpContractVersion(ctx context.Context, contractID, version uint64) (uint64, error)
 }
 
 type repo struct {
 	base.Repository
 }
 
-func (r *repo) List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Contract, int64, error) {
+func (r *repo) List(ctx context.Context, params *base.ListParams) ([]*model.Contract, *base.Page, error) {
 	var contracts []*entity.Contract
-	var total int64
-
-	err := r.DB(ctx).
-		Model(&entity.Contract{}).
-		Scopes(r.Filter(filters)).
-		Count(&total).
-		Scopes(r.Paginate(limit, offset, sort)).
-		Preload("SuplyVendor").
-		Find(&contracts).Error
 
+	page, err := r.FindPage(r.DB(ctx).Model(&entity.Contract{}), params, &contracts)
 	if err != nil && err != gorm.ErrRecordNotFound {
-		return nil, 0, err
+		return nil, nil, err
 	}
 
 	for _, c := range contracts {
 		c.UpdateVendorName()
 	}
 
-	return contracts, total, nil
+	return contracts, page, nil
 }
 
-func (r *repo) Get(ctx context.Context, contractID uint64) (*model.Contract, error) {
+func (r *repo) Get(ctx context.Context, contractID uint64, fs *base.Fieldset) (*model.Contract, error) {
 	var contract *entity.Contract
 	err := r.DB(ctx).
 		Model(&entity.Contract{}).
-		Preload("SuplyVendor").
+		Scopes(r.Select(fs, "version")).
 		Take(&contract, contractID).Error
 
 	if err != nil {
@@ -60,10 +55,26 @@ func (r *repo) Get(ctx context.Context, contractID uint64) (*model.Contract, err
 	return contract, nil
 }
 
+func (r *repo) BumpContractVersion(ctx context.Context, contractID, version uint64) (uint64, error) {
+	return r.BumpVersion(ctx, "contracts", contractID, version)
+}
+
 func New() Repository {
 	baseRepo := base.NewBaseRepository("contracts")
 
 	baseRepo.RegisterActualKey("supply_vendor_name", "orgs.name")
+
+	baseRepo.RegisterKeyType("supply_vendor_id", base.KeyTypeNumber)
+	baseRepo.RegisterKeyType("base_amount", base.KeyTypeNumber)
+	baseRepo.RegisterKeyType("actual_amount", base.KeyTypeNumber)
+	baseRepo.RegisterKeyType("start_date", base.KeyTypeDate)
+	baseRepo.RegisterKeyType("end_date", base.KeyTypeDate)
+
+	baseRepo.RegisterSortKeys("code", "start_date", "end_date", "base_amount", "actual_amount", "supply_vendor_name")
+	baseRepo.RegisterFields("code", "start_date", "end_date", "base_amount", "actual_amount")
+	baseRepo.RegisterExpansion("supply_vendor", "SuplyVendor", "supply_vendor_id")
+	baseRepo.RegisterDefaultExpand("supply_vendor")
+
 	baseRepo.RegisterJoinScope("supply_vendor_name", func(d *gorm.DB) *gorm.DB { return d.Joins(`INNER JOIN "orgs" ON "supply_vendor_id" = "orgs"."id"`) })
 
 	return &repo{baseRepo}
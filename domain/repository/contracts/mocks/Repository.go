@@ -3,12 +3,10 @@
 package mocks
 
 import (
-	base "github.com/tpp/msf/shared/base"
-	context "github.com/tpp/msf/shared/context"
-
 	mock "github.com/stretchr/testify/mock"
-
 	model "github.com/tpp/msf/model"
+	base "github.com/tpp/msf/shared/base"
+	context "github.com/tpp/msf/shared/context"
 )
 
 // Repository is an autogenerated mock type for the Repository type
@@ -16,13 +14,34 @@ type Repository struct {
 	mock.Mock
 }
 
-// Get provides a mock function with given fields: ctx, contractID
-func (_m *Repository) Get(ctx context.Context, contractID uint64) (*model.Contract, error) {
-	ret := _m.Called(ctx, contractID)
+// BumpContractVersion provides a mock function with given fields: ctx, contractID, version
+func (_m *Repository) BumpContractVersion(ctx context.Context, contractID uint64, version uint64) (uint64, error) {
+	ret := _m.Called(ctx, contractID, version)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) uint64); ok {
+		r0 = rf(ctx, contractID, version)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64) error); ok {
+		r1 = rf(ctx, contractID, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Get provides a mock function with given fields: ctx, contractID, fs
+func (_m *Repository) Get(ctx context.Context, contractID uint64, fs *base.Fieldset) (*model.Contract, error) {
+	ret := _m.Called(ctx, contractID, fs)
 
 	var r0 *model.Contract
-	if rf, ok := ret.Get(0).(func(context.Context, uint64) *model.Contract); ok {
-		r0 = rf(ctx, contractID)
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, *base.Fieldset) *model.Contract); ok {
+		r0 = rf(ctx, contractID, fs)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).(*model.Contract)
@@ -30,8 +49,8 @@ func (_m *Repository) Get(ctx context.Context, contractID uint64) (*model.Contra
 	}
 
 	var r1 error
-	if rf, ok := ret.Get(1).(func(context.Context, uint64) error); ok {
-		r1 = rf(ctx, contractID)
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, *base.Fieldset) error); ok {
+		r1 = rf(ctx, contractID, fs)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -39,29 +58,31 @@ func (_m *Repository) Get(ctx context.Context, contractID uint64) (*model.Contra
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx, limit, offset, filters
-func (_m *Repository) List(ctx context.Context, limit int, offset int, filters []*base.Filter) ([]*model.Contract, int64, error) {
-	ret := _m.Called(ctx, limit, offset, filters)
+// List provides a mock function with given fields: ctx, params
+func (_m *Repository) List(ctx context.Context, params *base.ListParams) ([]*model.Contract, *base.Page, error) {
+	ret := _m.Called(ctx, params)
 
 	var r0 []*model.Contract
-	if rf, ok := ret.Get(0).(func(context.Context, int, int, []*base.Filter) []*model.Contract); ok {
-		r0 = rf(ctx, limit, offset, filters)
+	if rf, ok := ret.Get(0).(func(context.Context, *base.ListParams) []*model.Contract); ok {
+		r0 = rf(ctx, params)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*model.Contract)
 		}
 	}
 
-	var r1 int64
-	if rf, ok := ret.Get(1).(func(context.Context, int, int, []*base.Filter) int64); ok {
-		r1 = rf(ctx, limit, offset, filters)
+	var r1 *base.Page
+	if rf, ok := ret.Get(1).(func(context.Context, *base.ListParams) *base.Page); ok {
+		r1 = rf(ctx, params)
 	} else {
-		r1 = ret.Get(1).(int64)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*base.Page)
+		}
 	}
 
 	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, int, int, []*base.Filter) error); ok {
-		r2 = rf(ctx, limit, offset, filters)
+	if rf, ok := ret.Get(2).(func(context.Context, *base.ListParams) error); ok {
+		r2 = rf(ctx, params)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -0,0 +1,86 @@
+//go:generate mockery --name=Repository
+package idempotency
+
+import (
+	"time"
+
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Repository interface {
+	// Reserve insert key unless an unexpired key of the user already has its name, reserved tells which
+	Reserve(ctx context.Context, key *model.IdempotencyKey) (reserved bool, err error)
+	Get(ctx context.Context, userID uint64, name string) (*model.IdempotencyKey, error)
+	Complete(ctx context.Context, key *model.IdempotencyKey) error
+	Delete(ctx context.Context, key *model.IdempotencyKey) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type repo struct {
+	base.Repository
+}
+
+func (r *repo) Reserve(ctx context.Context, key *model.IdempotencyKey) (bool, error) {
+	db := r.DB(ctx)
+	if err := db.Where("user_id = ? AND key = ? AND expires_at <= ?", key.UserID, key.Key, key.CreateAt).
+		Delete(&model.IdempotencyKey{}).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeleteExpiredIdempotencyKeyError")
+		return false, err
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(key)
+	if result.Error != nil {
+		r.Error(ctx).Err(result.Error).Msg("ReserveIdempotencyKeyError")
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r *repo) Get(ctx context.Context, userID uint64, name string) (*model.IdempotencyKey, error) {
+	var key *model.IdempotencyKey
+	err := r.DB(ctx).Where("user_id = ? AND key = ?", userID, name).Take(&key).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, base.ErrorNotFound
+		}
+		r.Error(ctx).Err(err).Msg("GetIdempotencyKeyError")
+		return nil, err
+	}
+	return key, nil
+}
+
+func (r *repo) Complete(ctx context.Context, key *model.IdempotencyKey) error {
+	err := r.DB(ctx).Model(key).
+		Select("Status", "ContentType", "Body").
+		Updates(key).Error
+	if err != nil {
+		r.Error(ctx).Err(err).Msg("CompleteIdempotencyKeyError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) Delete(ctx context.Context, key *model.IdempotencyKey) error {
+	if err := r.DB(ctx).Delete(key).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeleteIdempotencyKeyError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.DB(ctx).Where("expires_at <= ?", now).Delete(&model.IdempotencyKey{})
+	if result.Error != nil {
+		r.Error(ctx).Err(result.Error).Msg("DeleteExpiredIdempotencyKeysError")
+		return 0, result.Error
+	}
+	return result.RowsAffected, nil
+}
+
+func New() Repository {
+	return &repo{base.NewBaseRepository("idempotency")}
+}
@@ -0,0 +1,66 @@
+//go:generate mockery --name=Repository
+package logins
+
+import (
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, event *model.LoginEvent) error
+	ListByUserID(ctx context.Context, userID uint64, params *base.ListParams) ([]*model.LoginEvent, *base.Page, error)
+	ClientHistory(ctx context.Context, userID uint64, ip, device string) (*model.ClientHistory, error)
+}
+
+type repo struct {
+	base.Repository
+}
+
+func (r *repo) Create(ctx context.Context, event *model.LoginEvent) error {
+	if err := r.DB(ctx).Create(event).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("StoreLoginEventError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) ListByUserID(ctx context.Context, userID uint64, params *base.ListParams) ([]*model.LoginEvent, *base.Page, error) {
+	var events []*model.LoginEvent
+
+	page, err := r.FindPage(r.DB(ctx).Model(&model.LoginEvent{}).Where("user_id = ?", userID), params, &events)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		r.Error(ctx).Err(err).Msg("ListLoginEventError")
+		return []*model.LoginEvent{}, nil, err
+	}
+	return events, page, nil
+}
+
+// ClientHistory count the successful logins of userID and whether ip or device were used by one of them
+func (r *repo) ClientHistory(ctx context.Context, userID uint64, ip, device string) (*model.ClientHistory, error) {
+	var history model.ClientHistory
+	err := r.DB(ctx).
+		Model(&model.LoginEvent{}).
+		Select("count(*) AS logins, coalesce(bool_or(ip = ?), false) AS known_ip, coalesce(bool_or(device = ?), false) AS known_device", ip, device).
+		Where("user_id = ? AND success", userID).
+		Scan(&history).Error
+	if err != nil {
+		r.Error(ctx).Err(err).Msg("GetClientHistoryError")
+		return nil, err
+	}
+	return &history, nil
+}
+
+func New() Repository {
+	baseRepo := base.NewBaseRepository("logins")
+
+	baseRepo.RegisterKeyType("success", base.KeyTypeBool)
+	baseRepo.RegisterKeyType("created_at", base.KeyTypeDate)
+
+	baseRepo.RegisterSortKeys("created_at", "ip", "device", "success", "reason")
+
+	baseRepo.RegisterFields("email", "session_id", "ip", "user_agent", "device", "success", "reason", "created_at")
+
+	return &repo{baseRepo}
+}
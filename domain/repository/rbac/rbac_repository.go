@@ -0,0 +1,197 @@
+//go:generate mockery --name=Repository
+package rbac
+
+import (
+	"time"
+
+	entity "github.com/tpp/msf/domain/repository"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+)
+
+// lockKey the key of the advisory lock serializing the imports of policies
+const lockKey = 4_542_281_175
+
+type Repository interface {
+	// Lock wait for the other imports until the end of the transaction
+	Lock(ctx context.Context) error
+	ListPermissions(ctx context.Context) ([]*model.Permission, error)
+	// ListRoles the active roles, without their permissions
+	ListRoles(ctx context.Context) ([]*model.Role, error)
+	// ListBindings the permissions granted to the active roles
+	ListBindings(ctx context.Context) ([]*model.RBACBinding, error)
+	// CountUsers the number of users holding the role
+	CountUsers(ctx context.Context, roleID uint64) (int64, error)
+	CreatePermission(ctx context.Context, permission *model.Permission) error
+	RenamePermission(ctx context.Context, permissionID uint64, name string) error
+	// DeletePermission delete the permission along with its grants
+	DeletePermission(ctx context.Context, permissionID uint64) error
+	CreateRole(ctx context.Context, role *model.Role) error
+	RenameRole(ctx context.Context, roleID uint64, name string) error
+	// DeactivateRole deactivate the role, its permissions are revoked and its users lose it
+	DeactivateRole(ctx context.Context, roleID uint64) error
+	Grant(ctx context.Context, roleID, permissionID uint64) error
+	Revoke(ctx context.Context, roleID, permissionID uint64) error
+	// BumpRoleVersion increment the version of a changed role and of its users, see base.ETag
+	BumpRoleVersion(ctx context.Context, roleID uint64) error
+}
+
+type repo struct {
+	base.Repository
+}
+
+func (r *repo) Lock(ctx context.Context) error {
+	if err := r.DB(ctx).Exec("SELECT pg_advisory_xact_lock(?)", lockKey).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("LockRBACError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) ListPermissions(ctx context.Context) ([]*model.Permission, error) {
+	var permissions []*entity.Permission
+	if err := r.DB(ctx).Order("id").Find(&permissions).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("ListPermissionsError")
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *repo) ListRoles(ctx context.Context) ([]*model.Role, error) {
+	var roles []*entity.Role
+	if err := r.DB(ctx).Where("status = ?", true).Order("id").Find(&roles).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("ListRolesError")
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *repo) ListBindings(ctx context.Context) ([]*model.RBACBinding, error) {
+	var bindings []*model.RBACBinding
+	if err := r.DB(ctx).Table("permision_role").
+		Select("permision_role.role_id, permision_role.permission_id").
+		Joins(`INNER JOIN "roles" ON "roles"."id" = "permision_role"."role_id" AND "roles"."status" = true`).
+		Where("permision_role.status = ?", true).
+		Order("permision_role.role_id, permision_role.permission_id").
+		Find(&bindings).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("ListBindingsError")
+		return nil, err
+	}
+	return bindings, nil
+}
+
+func (r *repo) CountUsers(ctx context.Context, roleID uint64) (int64, error) {
+	var total int64
+	if err := r.DB(ctx).Table("user_role").Where("status = ?", true).Where("role_id = ?", roleID).Count(&total).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("CountRoleUsersError")
+		return 0, err
+	}
+	return total, nil
+}
+
+func (r *repo) CreatePermission(ctx context.Context, permission *model.Permission) error {
+	if err := r.DB(ctx).Select("Name").Create(permission).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("CreatePermissionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) RenamePermission(ctx context.Context, permissionID uint64, name string) error {
+	if err := r.DB(ctx).Table("permissions").Where("id = ?", permissionID).Update("name", name).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("RenamePermissionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) DeletePermission(ctx context.Context, permissionID uint64) error {
+	db := r.DB(ctx)
+	if err := db.Exec("DELETE FROM permision_role WHERE permission_id = ?", permissionID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeletePermissionError")
+		return err
+	}
+	if err := db.Exec("DELETE FROM permissions WHERE id = ?", permissionID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeletePermissionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) CreateRole(ctx context.Context, role *model.Role) error {
+	now := time.Now()
+	role.Status, role.CreateAt, role.UpdateAt = true, now, now
+	if err := r.DB(ctx).Select("Name", "Status", "CreateAt", "UpdateAt").Create(role).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("CreateRoleError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) RenameRole(ctx context.Context, roleID uint64, name string) error {
+	if err := r.DB(ctx).Table("roles").Where("id = ?", roleID).
+		Updates(map[string]any{"name": name, "updated_at": time.Now()}).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("RenameRoleError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) DeactivateRole(ctx context.Context, roleID uint64) error {
+	db := r.DB(ctx)
+	if err := db.Table("roles").Where("id = ?", roleID).
+		Updates(map[string]any{"status": false, "updated_at": time.Now()}).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeactivateRoleError")
+		return err
+	}
+	if err := db.Exec("DELETE FROM permision_role WHERE role_id = ?", roleID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeactivateRoleError")
+		return err
+	}
+	if err := db.Table("user_role").Where("role_id = ?", roleID).Update("status", false).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("DeactivateRoleError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) Grant(ctx context.Context, roleID, permissionID uint64) error {
+	db := r.DB(ctx)
+	// a revoked grant is replaced rather than reactivated, the table has no key
+	if err := db.Exec("DELETE FROM permision_role WHERE role_id = ? AND permission_id = ?", roleID, permissionID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("GrantPermissionError")
+		return err
+	}
+	if err := db.Exec("INSERT INTO permision_role (role_id, permission_id, status) VALUES (?, ?, true)", roleID, permissionID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("GrantPermissionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) Revoke(ctx context.Context, roleID, permissionID uint64) error {
+	if err := r.DB(ctx).Exec("DELETE FROM permision_role WHERE role_id = ? AND permission_id = ?", roleID, permissionID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("RevokePermissionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) BumpRoleVersion(ctx context.Context, roleID uint64) error {
+	if _, err := r.BumpVersion(ctx, "roles", roleID, 0); err != nil {
+		return err
+	}
+	// the role and its permissions are part of its users, their ETags change along
+	if err := r.DB(ctx).Exec("UPDATE users SET version = version + 1 WHERE id IN (SELECT user_id FROM user_role WHERE role_id = ?)", roleID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("BumpRoleUsersVersionError")
+		return err
+	}
+	return nil
+}
+
+func New() Repository {
+	return &repo{
+		Repository: base.NewBaseRepository("rbac"),
+	}
+}
@@ -0,0 +1,99 @@
+//go:generate mockery --name=Repository
+package sessions
+
+import (
+	"time"
+
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+)
+
+type Repository interface {
+	Create(ctx context.Context, session *model.Session) error
+	GetByTokenID(ctx context.Context, tokenID string) (*model.Session, error)
+	ListActiveByUserID(ctx context.Context, userID uint64) ([]*model.Session, error)
+	Touch(ctx context.Context, sessionID uint64) error
+	Revoke(ctx context.Context, userID, sessionID uint64) error
+	RevokeAllByUserID(ctx context.Context, userID uint64) error
+}
+
+type repo struct {
+	base.Repository
+}
+
+func (r *repo) Create(ctx context.Context, session *model.Session) error {
+	if err := r.DB(ctx).Create(session).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("StoreSessionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) GetByTokenID(ctx context.Context, tokenID string) (*model.Session, error) {
+	var session *model.Session
+	if err := r.DB(ctx).Where("token_id = ?", tokenID).Take(&session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, base.ErrorNotFound
+		}
+		r.Error(ctx).Err(err).Msg("GetSessionError")
+		return nil, err
+	}
+	return session, nil
+}
+
+func (r *repo) ListActiveByUserID(ctx context.Context, userID uint64) ([]*model.Session, error) {
+	var sessions []*model.Session
+	err := r.DB(ctx).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Where("expires_at > ?", time.Now()).
+		Order("last_seen_at desc").
+		Find(&sessions).Error
+	if err != nil {
+		r.Error(ctx).Err(err).Msg("ListSessionError")
+		return nil, err
+	}
+	return sessions, nil
+}
+
+func (r *repo) Touch(ctx context.Context, sessionID uint64) error {
+	if err := r.DB(ctx).Model(&model.Session{}).Where("id = ?", sessionID).Update("last_seen_at", time.Now()).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("TouchSessionError")
+		return err
+	}
+	return nil
+}
+
+func (r *repo) Revoke(ctx context.Context, userID, sessionID uint64) error {
+	result := r.DB(ctx).
+		Model(&model.Session{}).
+		Where("id = ? AND user_id = ?", sessionID, userID).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		r.Error(ctx).Err(result.Error).Msg("RevokeSessionError")
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return base.ErrorNotFound
+	}
+	return nil
+}
+
+func (r *repo) RevokeAllByUserID(ctx context.Context, userID uint64) error {
+	if err := r.DB(ctx).
+		Model(&model.Session{}).
+		Where("user_id = ?", userID).
+		Where("revoked_at IS NULL").
+		Update("revoked_at", time.Now()).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("RevokeSessionsError")
+		return err
+	}
+	return nil
+}
+
+func New() Repository {
+	return &repo{base.NewBaseRepository("sessions")}
+}
@@ -3,12 +3,11 @@
 package mocks
 
 import (
-	base "github.com/tpp/msf/shared/base"
-	context "github.com/tpp/msf/shared/context"
-
 	mock "github.com/stretchr/testify/mock"
-
+	repository "github.com/tpp/msf/domain/repository"
 	model "github.com/tpp/msf/model"
+	base "github.com/tpp/msf/shared/base"
+	context "github.com/tpp/msf/shared/context"
 )
 
 // Repository is an autogenerated mock type for the Repository type
@@ -16,27 +15,61 @@ type Repository struct {
 	mock.Mock
 }
 
-func (_m *Repository) UpdateName(ctx context.Context, name string, userID int64) error {
-	//TODO implement me
-	panic("implement me")
+// ActiveRolesByUserID provides a mock function with given fields: ctx, roleIds, userId
+func (_m *Repository) ActiveRolesByUserID(ctx context.Context, roleIds []int64, userId int64) error {
+	ret := _m.Called(ctx, roleIds, userId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, int64) error); ok {
+		r0 = rf(ctx, roleIds, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
-func (_m *Repository) UpdateActive(ctx context.Context, userID int64, active bool) error {
-	//TODO implement me
-	panic("implement me")
+// AssignMultipleRole provides a mock function with given fields: ctx, userRoles
+func (_m *Repository) AssignMultipleRole(ctx context.Context, userRoles []repository.UserRole) error {
+	ret := _m.Called(ctx, userRoles)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []repository.UserRole) error); ok {
+		r0 = rf(ctx, userRoles)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
 }
 
-func (_m *Repository) UpdatePassWord(ctx context.Context, userID int64, passWord string) error {
-	//TODO implement me
-	panic("implement me")
+// BumpUserVersion provides a mock function with given fields: ctx, userID, version
+func (_m *Repository) BumpUserVersion(ctx context.Context, userID uint64, version uint64) (uint64, error) {
+	ret := _m.Called(ctx, userID, version)
+
+	var r0 uint64
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, uint64) uint64); ok {
+		r0 = rf(ctx, userID, version)
+	} else {
+		r0 = ret.Get(0).(uint64)
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, uint64) error); ok {
+		r1 = rf(ctx, userID, version)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
 }
 
 // Create provides a mock function with given fields: ctx, user
-func (_m *Repository) Create(ctx context.Context, user *model.User) error {
+func (_m *Repository) Create(ctx context.Context, user *repository.User) error {
 	ret := _m.Called(ctx, user)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, *model.User) error); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, *repository.User) error); ok {
 		r0 = rf(ctx, user)
 	} else {
 		r0 = ret.Error(0)
@@ -45,6 +78,57 @@ func (_m *Repository) Create(ctx context.Context, user *model.User) error {
 	return r0
 }
 
+// CreateRole provides a mock function with given fields: ctx, role
+func (_m *Repository) CreateRole(ctx context.Context, role *repository.Role) error {
+	ret := _m.Called(ctx, role)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, *repository.Role) error); ok {
+		r0 = rf(ctx, role)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteRolesByUserID provides a mock function with given fields: ctx, roleIds, userId
+func (_m *Repository) DeleteRolesByUserID(ctx context.Context, roleIds []int64, userId int64) error {
+	ret := _m.Called(ctx, roleIds, userId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, []int64, int64) error); ok {
+		r0 = rf(ctx, roleIds, userId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// Find provides a mock function with given fields: ctx, userID, fs
+func (_m *Repository) Find(ctx context.Context, userID uint64, fs *base.Fieldset) (*model.User, error) {
+	ret := _m.Called(ctx, userID, fs)
+
+	var r0 *model.User
+	if rf, ok := ret.Get(0).(func(context.Context, uint64, *base.Fieldset) *model.User); ok {
+		r0 = rf(ctx, userID, fs)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*model.User)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, uint64, *base.Fieldset) error); ok {
+		r1 = rf(ctx, userID, fs)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // Get provides a mock function with given fields: ctx, userID
 func (_m *Repository) Get(ctx context.Context, userID uint64) (*model.User, error) {
 	ret := _m.Called(ctx, userID)
@@ -114,29 +198,77 @@ func (_m *Repository) GetRole(ctx context.Context, roleID uint64) (*model.Role,
 	return r0, r1
 }
 
-// List provides a mock function with given fields: ctx, limit, offset, filters
-func (_m *Repository) List(ctx context.Context, limit int, offset int, filters []*base.Filter) ([]*model.User, int64, error) {
-	ret := _m.Called(ctx, limit, offset, filters)
+// GetRolesByUserID provides a mock function with given fields: ctx, userID
+func (_m *Repository) GetRolesByUserID(ctx context.Context, userID int64) ([]*repository.UserRole, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*repository.UserRole
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*repository.UserRole); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*repository.UserRole)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetRolesByUserIDtoUpdate provides a mock function with given fields: ctx, userID
+func (_m *Repository) GetRolesByUserIDtoUpdate(ctx context.Context, userID int64) ([]*repository.UserRole, error) {
+	ret := _m.Called(ctx, userID)
+
+	var r0 []*repository.UserRole
+	if rf, ok := ret.Get(0).(func(context.Context, int64) []*repository.UserRole); ok {
+		r0 = rf(ctx, userID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]*repository.UserRole)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, int64) error); ok {
+		r1 = rf(ctx, userID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// List provides a mock function with given fields: ctx, params
+func (_m *Repository) List(ctx context.Context, params *base.ListParams) ([]*model.User, *base.Page, error) {
+	ret := _m.Called(ctx, params)
 
 	var r0 []*model.User
-	if rf, ok := ret.Get(0).(func(context.Context, int, int, []*base.Filter) []*model.User); ok {
-		r0 = rf(ctx, limit, offset, filters)
+	if rf, ok := ret.Get(0).(func(context.Context, *base.ListParams) []*model.User); ok {
+		r0 = rf(ctx, params)
 	} else {
 		if ret.Get(0) != nil {
 			r0 = ret.Get(0).([]*model.User)
 		}
 	}
 
-	var r1 int64
-	if rf, ok := ret.Get(1).(func(context.Context, int, int, []*base.Filter) int64); ok {
-		r1 = rf(ctx, limit, offset, filters)
+	var r1 *base.Page
+	if rf, ok := ret.Get(1).(func(context.Context, *base.ListParams) *base.Page); ok {
+		r1 = rf(ctx, params)
 	} else {
-		r1 = ret.Get(1).(int64)
+		if ret.Get(1) != nil {
+			r1 = ret.Get(1).(*base.Page)
+		}
 	}
 
 	var r2 error
-	if rf, ok := ret.Get(2).(func(context.Context, int, int, []*base.Filter) error); ok {
-		r2 = rf(ctx, limit, offset, filters)
+	if rf, ok := ret.Get(2).(func(context.Context, *base.ListParams) error); ok {
+		r2 = rf(ctx, params)
 	} else {
 		r2 = ret.Error(2)
 	}
@@ -144,6 +276,90 @@ func (_m *Repository) List(ctx context.Context, limit int, offset int, filters [
 	return r0, r1, r2
 }
 
+// TimeCreateRole provides a mock function with given fields: ctx, RoleId
+func (_m *Repository) TimeCreateRole(ctx context.Context, RoleId int64) error {
+	ret := _m.Called(ctx, RoleId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, RoleId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// TimeCreateUser provides a mock function with given fields: ctx, UserId
+func (_m *Repository) TimeCreateUser(ctx context.Context, UserId int64) error {
+	ret := _m.Called(ctx, UserId)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64) error); ok {
+		r0 = rf(ctx, UserId)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateActive provides a mock function with given fields: ctx, userID, active
+func (_m *Repository) UpdateActive(ctx context.Context, userID int64, active bool) error {
+	ret := _m.Called(ctx, userID, active)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, bool) error); ok {
+		r0 = rf(ctx, userID, active)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateLocale provides a mock function with given fields: ctx, locale, userID
+func (_m *Repository) UpdateLocale(ctx context.Context, locale string, userID int64) error {
+	ret := _m.Called(ctx, locale, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, locale, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateName provides a mock function with given fields: ctx, name, userID
+func (_m *Repository) UpdateName(ctx context.Context, name string, userID int64) error {
+	ret := _m.Called(ctx, name, userID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, int64) error); ok {
+		r0 = rf(ctx, name, userID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdatePassWord provides a mock function with given fields: ctx, userID, passWord
+func (_m *Repository) UpdatePassWord(ctx context.Context, userID int64, passWord string) error {
+	ret := _m.Called(ctx, userID, passWord)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, int64, string) error); ok {
+		r0 = rf(ctx, userID, passWord)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateUserRole provides a mock function with given fields: ctx, userID, roleID
 func (_m *Repository) UpdateUserRole(ctx context.Context, userID uint64, roleID uint64) error {
 	ret := _m.Called(ctx, userID, roleID)
@@ -11,15 +11,18 @@ import (
 )
 
 type Repository interface {
-	List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.User, int64, error)
+	List(ctx context.Context, params *base.ListParams) ([]*model.User, *base.Page, error)
 	Create(ctx context.Context, user *entity.User) error
 	CreateRole(ctx context.Context, role *entity.Role) error
 	Get(ctx context.Context, userID uint64) (*model.User, error)
+	// Find the user with the fields and the expansions of fs, its active roles only as Get
+	Find(ctx context.Context, userID uint64, fs *base.Fieldset) (*model.User, error)
 	GetOrg(ctx context.Context, orgID uint64) (*model.Org, error)
 	GetRole(ctx context.Context, roleID uint64) (*model.Role, error)
 	UpdateUserRole(ctx context.Context, userID, roleID uint64) error
 	UpdatePassWord(ctx context.Context, userID int64, passWord string) error
 	UpdateName(ctx context.Context, name string, userID int64) error
+	UpdateLocale(ctx context.Context, locale string, userID int64) error
 	UpdateActive(ctx context.Context, userID int64, active bool) error
 	AssignMultipleRole(ctx context.Context, userRoles []entity.UserRole) error
 	GetRolesByUserID(ctx context.Context, userID int64) ([]*entity.UserRole, error)
@@ -28,31 +31,23 @@ type Repository interface {
 	GetRolesByUserIDtoUpdate(ctx context.Context, userID int64) ([]*entity.UserRole, error)
 	TimeCreateUser(ctx context.Context, UserId int64) error
 	TimeCreateRole(ctx context.Context, RoleId int64) error
+	// BumpUserVersion increment the version of the user when it is still version, 0 matches any version
+	BumpUserVersion(ctx context.Context, userID, version uint64) (uint64, error)
 }
 
 type repo struct {
 	base.Repository
 }
 
-func (r *repo) List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.User, int64, error) {
+func (r *repo) List(ctx context.Context, params *base.ListParams) ([]*model.User, *base.Page, error) {
 	var users []*entity.User
-	var total int64
-	offset = offset * limit
-
-	err := r.DB(ctx).
-		Model(&entity.User{}).
-		Scopes(r.Filter(filters)).
-		Count(&total).
-		Scopes(r.Paginate(limit, offset, sort)).
-		Preload("Roles.Permissions").
-		Preload("Org").
-		Find(&users).Error
 
+	page, err := r.FindPage(r.DB(ctx).Model(&entity.User{}), params, &users)
 	if err != nil && err != gorm.ErrRecordNotFound {
 		r.Error(ctx).Err(err).Msg("ListUserError")
-		return []*model.User{}, 0, err
+		return []*model.User{}, nil, err
 	}
-	return entity.MapUserModels(users), total, nil
+	return entity.MapUserModels(users), page, nil
 }
 
 func (r *repo) Create(ctx context.Context, user *entity.User) error {
@@ -102,6 +97,23 @@ func (r *repo) Get(ctx context.Context, userID uint64) (*model.User, error) {
 	return user.User, nil
 }
 
+func (r *repo) Find(ctx context.Context, userID uint64, fs *base.Fieldset) (*model.User, error) {
+	db := r.DB(ctx).Model(&entity.User{}).Scopes(r.Select(fs, "version"))
+	if r.Expands(fs, "roles") {
+		// after the scope, which preloads the roles without condition
+		db = db.Scopes(func(d *gorm.DB) *gorm.DB {
+			return d.Preload("Roles", " id IN (SELECT role_id FROM user_role WHERE status = true AND user_id = ?)", userID)
+		})
+	}
+
+	var user *entity.User
+	if err := db.Take(&user, userID).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("FindUserError")
+		return nil, err
+	}
+	return user.User, nil
+}
+
 func (r *repo) GetOrg(ctx context.Context, orgID uint64) (*model.Org, error) {
 	var org *entity.Org
 	if err := r.DB(ctx).Preload("Role").Take(&org, orgID).Error; err != nil {
@@ -165,6 +177,14 @@ func (r *repo) UpdateName(ctx context.Context, name string, userID int64) error
 	}
 	return nil
 }
+func (r *repo) UpdateLocale(ctx context.Context, locale string, userID int64) error {
+
+	if err := r.DB(ctx).Table("users").Where("id = ?", userID).Update("locale", locale).Error; err != nil {
+		r.Error(ctx).Err(err).Msg("update locale error")
+		return err
+	}
+	return nil
+}
 func (r *repo) UpdateActive(ctx context.Context, userID int64, active bool) error {
 
 	if err := r.DB(ctx).Table("users").Where("id = ?", userID).Update("status", active).Error; err != nil {
@@ -225,6 +245,10 @@ func (r *repo) TimeCreateRole(ctx context.Context, RoleId int64) error {
 	}
 	return nil
 }
+func (r *repo) BumpUserVersion(ctx context.Context, userID, version uint64) (uint64, error) {
+	return r.BumpVersion(ctx, "users", userID, version)
+}
+
 func New() Repository {
 	baseRepo := base.NewBaseRepository("users")
 
@@ -233,6 +257,16 @@ func New() Repository {
 	baseRepo.RegisterActualKey("role_name", "roles.name")
 	baseRepo.RegisterActualKey("org_name", "orgs.name")
 
+	baseRepo.RegisterKeyType("role_id", base.KeyTypeNumber)
+
+	baseRepo.RegisterSortKeys("full_name", "email", "status", "last_login", "created_at", "org_name")
+
+	baseRepo.RegisterFields("full_name", "email", "is_admin", "status", "locale", "last_login", "created_at", "updated_at")
+	baseRepo.RegisterExpansion("roles", "Roles")
+	baseRepo.RegisterExpansion("roles.permissions", "Roles.Permissions")
+	baseRepo.RegisterExpansion("org", "Org", "org_id")
+	baseRepo.RegisterDefaultExpand("roles.permissions", "org")
+
 	baseRepo.RegisterJoinScope("org_name", orgsJoin)
 	baseRepo.RegisterJoinScope("role_name", orgsJoin, func(d *gorm.DB) *gorm.DB { return d.Joins(`INNER JOIN "roles" ON "orgs"."type" = "roles"."id"`) })
 
@@ -30,8 +30,18 @@ var rolesColumns = []string{
 	"name",
 }
 
+var userRoleColumns = []string{
+	"user_id",
+	"role_id",
+}
+
+var permissionRoleColumns = []string{
+	"role_id",
+	"permission_id",
+}
+
 func listUserSuccessMock(mock sqlmock.Sqlmock) {
-	mock.ExpectPrepare(`SELECT count(*) FROM "users" LIMIT 10`).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	mock.ExpectPrepare(`SELECT count(*) FROM "users"`).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
 	mock.ExpectPrepare(`SELECT * FROM "users" LIMIT 10`).ExpectQuery().WillReturnRows(
 		sqlmock.NewRows(usersColumns).
 			AddRow(1, "test full name 1", "test.email1@email.test", 1).
@@ -41,14 +51,20 @@ func listUserSuccessMock(mock sqlmock.Sqlmock) {
 		sqlmock.NewRows(orgsColumns).AddRow(1, "test org name", 1),
 	)
 	mock.ExpectPrepare(`SELECT * FROM "user_role" WHERE "user_role"."user_id" IN ($1,$2)`).ExpectQuery().WithArgs(1, 2).WillReturnRows(
-		sqlmock.NewRows(rolesColumns).AddRow(1, 1),
-		sqlmock.NewRows(rolesColumns).AddRow(2, 1),
+		sqlmock.NewRows(userRoleColumns).AddRow(1, 1).AddRow(2, 1),
+	)
+	mock.ExpectPrepare(`SELECT * FROM "roles" WHERE "roles"."id" = $1`).ExpectQuery().WithArgs(1).WillReturnRows(
+		sqlmock.NewRows(rolesColumns).AddRow(1, "test role name"),
+	)
+	mock.ExpectPrepare(`SELECT * FROM "permision_role" WHERE "permision_role"."role_id" = $1`).ExpectQuery().WithArgs(1).WillReturnRows(
+		sqlmock.NewRows(permissionRoleColumns),
 	)
 }
 
 func Test_repo_List(t *testing.T) {
 
-	baseRepo := base.NewBaseRepository("test_repository")
+	// the registered keys and expansions of the users repository
+	baseRepo := New().(*repo).Repository
 
 	type args struct {
 		limit   int
@@ -105,9 +121,40 @@ func Test_repo_List(t *testing.T) {
 				defer td()
 			}
 
-			users, total, err := r.List(ctx, tt.args.limit, tt.args.offset, tt.args.filters)
+			users, page, err := r.List(ctx, &base.ListParams{Limit: tt.args.limit, Offset: tt.args.offset, Filters: tt.args.filters})
+			var total int64
+			if page != nil && page.Total != nil {
+				total = *page.Total
+			}
 
 			tt.expect(assertion, users, total, err)
 		})
 	}
 }
+
+func Test_repo_Find(t *testing.T) {
+	r := New().(*repo)
+
+	ctx, mock, cncl, err := utils.NewContextWithDB()
+	require.NoError(t, err)
+	defer cncl()
+
+	mock.ExpectPrepare(`SELECT "users"."id","users"."version","users"."email" FROM "users" WHERE "users"."id" = $1 LIMIT 1`).ExpectQuery().WithArgs(1).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "version", "email"}).AddRow(1, 3, "test.email1@email.test"),
+	)
+	mock.ExpectPrepare(`SELECT * FROM "user_role" WHERE "user_role"."user_id" = $1`).ExpectQuery().WithArgs(1).WillReturnRows(
+		sqlmock.NewRows(userRoleColumns).AddRow(1, 1).AddRow(1, 2),
+	)
+	// only the active roles of the user
+	mock.ExpectPrepare(`SELECT * FROM "roles" WHERE "roles"."id" IN ($1,$2) AND ( id IN (SELECT role_id FROM user_role WHERE status = true AND user_id = $3))`).ExpectQuery().WithArgs(1, 2, 1).WillReturnRows(
+		sqlmock.NewRows(rolesColumns).AddRow(1, "test role name"),
+	)
+
+	user, err := r.Find(ctx, 1, &base.Fieldset{Fields: "email", Expand: "roles"})
+	require.NoError(t, err)
+	require.EqualValues(t, 3, user.Version)
+	require.EqualValues(t, "test.email1@email.test", user.Email)
+	require.Len(t, user.Roles, 1)
+	require.Nil(t, user.Org)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
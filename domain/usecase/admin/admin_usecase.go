@@ -0,0 +1,21 @@
+package admin
+
+import (
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/mailtemplate"
+)
+
+type Usecase interface {
+	EmailTemplates() []string
+	PreviewEmail(name string, locale string) (*mailtemplate.Message, error)
+}
+
+type usecase struct {
+	base.Usecase
+}
+
+func New() Usecase {
+	return &usecase{
+		Usecase: base.NewBaseUsecase("admin"),
+	}
+}
@@ -1,25 +1,47 @@
 package auth
 
 import (
+	"time"
+
+	"github.com/rs/xid"
 	"github.com/tpp/msf/domain/repository/auth"
+	"github.com/tpp/msf/domain/repository/logins"
+	"github.com/tpp/msf/domain/repository/sessions"
+	"github.com/tpp/msf/domain/repository/users"
 	"github.com/tpp/msf/model"
 	helper "github.com/tpp/msf/shared/auth"
 	"github.com/tpp/msf/shared/base"
 	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/mailtemplate"
+	"github.com/tpp/msf/shared/metrics"
 	"github.com/tpp/msf/shared/utils"
 )
 
 type Usecase interface {
-	ListRoles(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Role, error)
-	Login(ctx context.Context, email, password string) (user *model.User, accessToken string, err error)
+	ListRoles(ctx context.Context, params *base.ListParams) ([]*model.Role, *base.Page, error)
+	Login(ctx context.Context, email, password string, client model.ClientInfo) (user *model.User, accessToken string, err error)
+	Logout(ctx context.Context) error
+	RevokeSessionByToken(ctx context.Context, token string) error
 	ForgotPassword(ctx context.Context, email string) (accessTolken string, err error)
-	GetRole(ctx context.Context, roleID uint64) (*model.Role, error)
+	SetPasswordByToken(ctx context.Context, action, token, password string) error
+	GetRole(ctx context.Context, roleID uint64, fs *base.Fieldset) (*model.Role, error)
 	ListPermission(ctx context.Context) ([]*model.Permission, error)
 }
 
 type usecase struct {
 	base.Usecase
-	repo auth.Repository
+	repo        auth.Repository
+	sessionRepo sessions.Repository
+	loginRepo   logins.Repository
+	userRepo    users.Repository
+}
+
+// revokeSessionAction audience of the token sent in the new sign-in email
+const revokeSessionAction = "revoke_session"
+
+type revokeSessionClaim struct {
+	UserID    uint64 `json:"user_id"`
+	SessionID uint64 `json:"session_id"`
 }
 
 func (u *usecase) ListPermission(ctx context.Context) ([]*model.Permission, error) {
@@ -30,24 +52,24 @@ func (u *usecase) ListPermission(ctx context.Context) ([]*model.Permission, erro
 	return permissions, err
 
 }
-func (u *usecase) ListRoles(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Role, error) {
-	roles, err := u.repo.List(ctx, limit, offset, sort, filters)
+func (u *usecase) ListRoles(ctx context.Context, params *base.ListParams) ([]*model.Role, *base.Page, error) {
+	roles, page, err := u.repo.List(ctx, params)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	for _, role := range roles {
 		total, err := u.repo.CountRoleByUserID(ctx, int64(role.ID))
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		role.SetCount(total)
 	}
-	return roles, err
+	return roles, page, err
 }
-func (u *usecase) GetRole(ctx context.Context, roleID uint64) (*model.Role, error) {
+func (u *usecase) GetRole(ctx context.Context, roleID uint64, fs *base.Fieldset) (*model.Role, error) {
 	var roles *model.Role
 	var err error
-	roles, err = u.repo.GetRole(ctx, roleID)
+	roles, err = u.repo.GetRole(ctx, roleID, fs)
 	if err != nil {
 		return nil, err
 	}
@@ -57,24 +79,66 @@ func (u *usecase) GetRole(ctx context.Context, roleID uint64) (*model.Role, erro
 	return roles, err
 }
 
-func (u *usecase) Login(ctx context.Context, email, password string) (*model.User, string, error) {
+func (u *usecase) Login(ctx context.Context, email, password string, client model.ClientInfo) (*model.User, string, error) {
+	event := &model.LoginEvent{
+		Email:     email,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Device:    utils.DeviceFromUserAgent(client.UserAgent),
+	}
+
 	user, hashedPassword, err := u.repo.GetUserByEmail(ctx, email)
 	if err != nil {
 		return nil, "", err
 	}
 
 	if user == nil {
+		u.recordLogin(ctx, event, model.LoginUnknownEmail)
 		return nil, "", base.NewApiErrors("email", "This email is not registered")
 	}
+	event.UserID = &user.ID
 
 	if !utils.CompareHashAndPassword(hashedPassword, password) {
+		u.recordLogin(ctx, event, model.LoginWrongPassword)
 		return nil, "", base.NewApiErrors("password", "wrong password")
 	}
 
-	accessToken, err := helper.GenerateJWTToken(user)
+	// must be read before this login is recorded
+	history, err := u.loginRepo.ClientHistory(ctx, user.ID, event.IP, event.Device)
+	if err != nil {
+		return nil, "", err
+	}
+
+	tokenID := xid.New().String()
+	accessToken, err := helper.GenerateJWTTokenWithID(user, tokenID)
 	if err != nil {
 		u.Error(ctx).Err(err).Msg("LoginError")
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &model.Session{
+		TokenID:    tokenID,
+		UserID:     user.ID,
+		Device:     event.Device,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreateAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(helper.TokenTTL()),
+	}
+	if err = u.sessionRepo.Create(ctx, session); err != nil {
+		return nil, "", err
 	}
+
+	event.SessionID = &session.ID
+	u.recordLogin(ctx, event, model.LoginSucceeded)
+
+	// the very first login of an account has nothing to compare with
+	if history.Logins > 0 && (!history.KnownIP || !history.KnownDevice) {
+		u.notifyNewSignIn(ctx, user, session)
+	}
+
 	err = u.repo.UpdateTimeLastLogin(ctx, int64(user.ID))
 	if err != nil {
 		return user, accessToken, err
@@ -82,26 +146,115 @@ func (u *usecase) Login(ctx context.Context, email, password string) (*model.Use
 	return user, accessToken, nil
 }
 
+// recordLogin store the login attempt, a failure to do so does not fail the login
+func (u *usecase) recordLogin(ctx context.Context, event *model.LoginEvent, outcome model.LoginOutcome) {
+	event.Success = outcome == model.LoginSucceeded
+	event.Reason = outcome
+	event.CreateAt = time.Now()
+	if err := u.loginRepo.Create(ctx, event); err != nil {
+		u.Error(ctx).Err(err).Str("email", event.Email).Str("reason", string(outcome)).Msg("RecordLoginError")
+	}
+	metrics.CountLogin(event.Success, string(outcome))
+}
+
+// notifyNewSignIn email the user about a login from an unknown device or IP with a link to revoke the session
+func (u *usecase) notifyNewSignIn(ctx context.Context, user *model.User, session *model.Session) {
+	token, err := helper.GenerateActionToken(revokeSessionAction, revokeSessionClaim{
+		UserID:    user.ID,
+		SessionID: session.ID,
+	}, helper.TokenTTL())
+	if err != nil {
+		u.Error(ctx).Err(err).Msg("GenerateRevokeSessionTokenError")
+		return
+	}
+
+	err = u.SendEmail(user, mailtemplate.NewSignIn, token, map[string]string{
+		"Device": session.Device,
+		"IP":     session.IP,
+		"Time":   session.CreateAt.UTC().Format("2006-01-02 15:04:05 UTC"),
+	})
+	if err != nil {
+		u.Info(ctx).Err(err).Msg("Can not send mail")
+	}
+}
+
+// Logout revoke the session of the current token
+func (u *usecase) Logout(ctx context.Context) error {
+	// GetByTokenID would not find the session of a token without one, which is not a missing resource
+	if ctx.TokenID() == "" {
+		return base.NewUnauthorizedError("the access token is not tied to a session")
+	}
+	session, err := u.sessionRepo.GetByTokenID(ctx, ctx.TokenID())
+	if err != nil {
+		return err
+	}
+	return u.sessionRepo.Revoke(ctx, session.UserID, session.ID)
+}
+
+// RevokeSessionByToken revoke the session given in the link of the new sign-in email
+func (u *usecase) RevokeSessionByToken(ctx context.Context, token string) error {
+	var claim revokeSessionClaim
+	if err := helper.ParseActionToken(token, revokeSessionAction, &claim); err != nil {
+		return base.NewApiErrors("token", "invalid or expired token")
+	}
+	return u.sessionRepo.Revoke(ctx, claim.UserID, claim.SessionID)
+}
+
 // forgot password
 func (u *usecase) ForgotPassword(ctx context.Context, mail string) (string, error) {
-	userId, err := u.repo.GetUserId(ctx, mail)
+	user, hashedPassword, err := u.repo.GetUserByEmail(ctx, mail)
 	if err != nil {
 		return "", err
 	}
-	if userId != 0 {
-		accessToken, err := helper.GenerateJWTToken(userId)
-		err = u.SendEmail(accessToken, mail, "email_template.html", "Reset Password")
-		if err != nil {
-			u.Info(ctx).Err(err).Msg("Can not send mail")
-			return "", err
-		}
+	if user == nil {
+		return "", base.NewApiErrors("email", "This email is not registered")
+	}
+
+	token, err := helper.GenerateActionToken(helper.ActionResetPassword, helper.PasswordClaim{
+		UserID: user.ID,
+		Stamp:  helper.PasswordStamp(hashedPassword),
+	}, helper.TokenTTL())
+	if err == nil {
+		err = u.SendEmail(user, mailtemplate.ResetPassword, token, nil)
+	}
+	if err != nil {
+		u.Info(ctx).Err(err).Msg("Can not send mail")
+		return "", err
 	}
 
 	return "Send Mail Successfully", nil
 }
+
+// SetPasswordByToken set the password of the user of the token of the reset password or of the activation email,
+// action. The token is spent once the password is set.
+func (u *usecase) SetPasswordByToken(ctx context.Context, action, token, password string) error {
+	var claim helper.PasswordClaim
+	if err := helper.ParseActionToken(token, action, &claim); err != nil {
+		return base.NewApiErrors("token", "invalid or expired token")
+	}
+	user, hashedPassword, err := u.repo.GetUserPassword(ctx, claim.UserID)
+	if err != nil {
+		return err
+	}
+	// the users are inactive until their activation sets their password, only the reset password
+	// requires an active user. A password changed since the token was sent spends it.
+	if user == nil || (action == helper.ActionResetPassword && !user.Status) ||
+		helper.PasswordStamp(hashedPassword) != claim.Stamp {
+		return base.NewApiErrors("token", "invalid or expired token")
+	}
+	if err = u.userRepo.UpdatePassWord(ctx, int64(user.ID), utils.HashAndSaltPassword(password)); err != nil {
+		return err
+	}
+	u.Info(ctx).Uint64("user_id", user.ID).Str("action", action).Msg("PasswordSet")
+	return nil
+}
+
 func New() Usecase {
 	return &usecase{
-		Usecase: base.NewBaseUsecase("auth"),
-		repo:    auth.New(),
+		Usecase:     base.NewBaseUsecase("auth"),
+		repo:        auth.New(),
+		sessionRepo: sessions.New(),
+		loginRepo:   logins.New(),
+		userRepo:    users.New(),
 	}
 }
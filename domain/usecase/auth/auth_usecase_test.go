@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/domain/repository/auth/mocks"
+	usermocks "github.com/tpp/msf/domain/repository/users/mocks"
+	"github.com/tpp/msf/model"
+	helper "github.com/tpp/msf/shared/auth"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+)
+
+func Test_usecase_SetPasswordByToken(t *testing.T) {
+
+	helper.Configure(&helper.Config{Secret: "test"})
+	baseUsecase := base.NewBaseUsecase("test_auth_usecase")
+	const hashedPassword = "$2a$04$hashed"
+	tests := []struct {
+		name   string
+		action string
+		stamp  string
+		setup  func(repo *mocks.Repository, userRepo *usermocks.Repository)
+		expect func(r *require.Assertions, err error)
+	}{
+		{
+			name:   "activate an inactive user",
+			action: helper.ActionActivateAccount,
+			stamp:  helper.PasswordStamp(hashedPassword),
+			setup: func(repo *mocks.Repository, userRepo *usermocks.Repository) {
+				repo.On("GetUserPassword", mock.Anything, uint64(7)).Return(&model.User{ID: 7}, hashedPassword, nil)
+				userRepo.On("UpdatePassWord", mock.Anything, int64(7), mock.AnythingOfType("string")).Return(nil)
+			},
+			expect: func(r *require.Assertions, err error) {
+				r.NoError(err)
+			},
+		},
+		{
+			name:   "reset the password of an inactive user",
+			action: helper.ActionResetPassword,
+			stamp:  helper.PasswordStamp(hashedPassword),
+			setup: func(repo *mocks.Repository, userRepo *usermocks.Repository) {
+				repo.On("GetUserPassword", mock.Anything, uint64(7)).Return(&model.User{ID: 7}, hashedPassword, nil)
+			},
+			expect: func(r *require.Assertions, err error) {
+				r.IsType(base.APIErrors{}, err)
+			},
+		},
+		{
+			name:   "password changed since the token was sent",
+			action: helper.ActionActivateAccount,
+			stamp:  helper.PasswordStamp("$2a$04$previous"),
+			setup: func(repo *mocks.Repository, userRepo *usermocks.Repository) {
+				repo.On("GetUserPassword", mock.Anything, uint64(7)).Return(&model.User{ID: 7, Status: true}, hashedPassword, nil)
+			},
+			expect: func(r *require.Assertions, err error) {
+				r.IsType(base.APIErrors{}, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			repo := mocks.NewRepository(t)
+			userRepo := usermocks.NewRepository(t)
+			tt.setup(repo, userRepo)
+
+			token, err := helper.GenerateActionToken(tt.action, helper.PasswordClaim{UserID: 7, Stamp: tt.stamp}, time.Hour)
+			require.NoError(t, err)
+
+			u := &usecase{
+				Usecase:  baseUsecase,
+				repo:     repo,
+				userRepo: userRepo,
+			}
+			tt.expect(require.New(t), u.SetPasswordByToken(context.Background(), tt.action, token, "Secret@123"))
+
+		})
+	}
+}
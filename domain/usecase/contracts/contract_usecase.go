@@ -1,33 +1,98 @@
 package contracts
 
 import (
+	"io"
+	"time"
+
+	"github.com/tpp/msf/domain/repository/attachments"
 	"github.com/tpp/msf/domain/repository/contracts"
+	"github.com/tpp/msf/external-adapter/blob"
 	"github.com/tpp/msf/model"
 	"github.com/tpp/msf/shared/base"
 	"github.com/tpp/msf/shared/context"
 )
 
 type Usecase interface {
-	List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Contract, int64, error)
-	GetContract(ctx context.Context, contractId uint64) (*model.Contract, error)
+	List(ctx context.Context, params *base.ListParams) ([]*model.Contract, *base.Page, error)
+	GetContract(ctx context.Context, contractId uint64, fs *base.Fieldset) (*model.Contract, error)
+	// ClaimVersion check the version of the If-Match of a mutation of the contract, its attachments included,
+	// then increment it
+	ClaimVersion(ctx context.Context, contractID, version uint64) (uint64, error)
+	ListAttachments(ctx context.Context, contractID uint64, params *base.ListParams) ([]*model.ContractAttachment, *base.Page, error)
+	GetAttachment(ctx context.Context, contractID, attachmentID uint64) (*model.ContractAttachment, error)
+	OpenAttachment(ctx context.Context, attachment *model.ContractAttachment) (io.ReadCloser, error)
+	CreateAttachments(ctx context.Context, attachments []*model.ContractAttachment) error
+	DeleteAttachment(ctx context.Context, attachment *model.ContractAttachment) error
+	// DiscardBlobs delete the blobs of keys, the failures are only logged as nothing refers to the blobs anymore
+	DiscardBlobs(ctx context.Context, keys ...string)
 }
 
 type usecase struct {
 	base.Usecase
-	repo contracts.Repository
+	repo            contracts.Repository
+	attachmentsRepo attachments.Repository
+	store           blob.BlobStore
+}
+
+func (u *usecase) List(ctx context.Context, params *base.ListParams) ([]*model.Contract, *base.Page, error) {
+	return u.repo.List(ctx, params)
+}
+
+func (u *usecase) GetContract(ctx context.Context, contractId uint64, fs *base.Fieldset) (*model.Contract, error) {
+	return u.repo.Get(ctx, contractId, fs)
 }
 
-func (u *usecase) List(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.Contract, int64, error) {
-	return u.repo.List(ctx, limit, offset, sort, filters)
+func (u *usecase) ClaimVersion(ctx context.Context, contractID, version uint64) (uint64, error) {
+	return u.repo.BumpContractVersion(ctx, contractID, version)
 }
 
-func (u *usecase) GetContract(ctx context.Context, contractId uint64) (*model.Contract, error) {
-	return u.repo.Get(ctx, contractId)
+func (u *usecase) ListAttachments(ctx context.Context, contractID uint64, params *base.ListParams) ([]*model.ContractAttachment, *base.Page, error) {
+	return u.attachmentsRepo.ListByContractID(ctx, contractID, params)
+}
+
+func (u *usecase) GetAttachment(ctx context.Context, contractID, attachmentID uint64) (*model.ContractAttachment, error) {
+	return u.attachmentsRepo.Get(ctx, contractID, attachmentID)
+}
+
+func (u *usecase) OpenAttachment(ctx context.Context, attachment *model.ContractAttachment) (io.ReadCloser, error) {
+	rc, err := u.store.Get(ctx, attachment.BlobKey)
+	if err == blob.ErrNotFound {
+		u.Error(ctx).Str("key", attachment.BlobKey).Msg("MissingAttachmentBlob")
+		return nil, base.ErrorNotFound
+	}
+	return rc, err
+}
+
+func (u *usecase) CreateAttachments(ctx context.Context, attachments []*model.ContractAttachment) error {
+	now := time.Now()
+	for _, a := range attachments {
+		a.CreateAt = now
+	}
+	return u.attachmentsRepo.Create(ctx, attachments)
+}
+
+func (u *usecase) DeleteAttachment(ctx context.Context, attachment *model.ContractAttachment) error {
+	if err := u.attachmentsRepo.Delete(ctx, attachment); err != nil {
+		return err
+	}
+	// the content is deleted once the row deletion is committed
+	ctx.AfterCommit(func() { u.DiscardBlobs(ctx, attachment.BlobKey) })
+	return nil
+}
+
+func (u *usecase) DiscardBlobs(ctx context.Context, keys ...string) {
+	for _, key := range keys {
+		if err := u.store.Delete(ctx, key); err != nil {
+			u.Error(ctx).Err(err).Str("key", key).Msg("DeleteBlobError")
+		}
+	}
 }
 
 func New() Usecase {
 	return &usecase{
-		Usecase: base.NewBaseUsecase("contracts"),
-		repo:    contracts.New(),
+		Usecase:         base.NewBaseUsecase("contracts"),
+		repo:            contracts.New(),
+		attachmentsRepo: attachments.New(),
+		store:           blob.GetBlobStoreInstance(),
 	}
 }
@@ -0,0 +1,99 @@
+package idempotency
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/tpp/msf/config"
+	"github.com/tpp/msf/domain/repository/idempotency"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+)
+
+// defaultTTL how long the responses are replayed, in seconds
+const defaultTTL = 24 * 60 * 60
+
+type Usecase interface {
+	// Begin reserve name for the request of fingerprint, or the key of an earlier request with the same
+	// fingerprint to replay. A key of another request or of a request in progress is an error.
+	Begin(ctx context.Context, userID uint64, name, fingerprint string) (key *model.IdempotencyKey, replay bool, err error)
+	// Complete store the response of the request of key to replay it
+	Complete(ctx context.Context, key *model.IdempotencyKey, status int, contentType string, body []byte) error
+	// Release delete key, the request can be retried with it
+	Release(ctx context.Context, key *model.IdempotencyKey) error
+	// Purge delete the expired keys
+	Purge(ctx context.Context) (int64, error)
+}
+
+type usecase struct {
+	base.Usecase
+	repo idempotency.Repository
+	ttl  time.Duration
+}
+
+func (u *usecase) Begin(ctx context.Context, userID uint64, name, fingerprint string) (*model.IdempotencyKey, bool, error) {
+	now := time.Now()
+	key := &model.IdempotencyKey{
+		UserID:      userID,
+		Key:         name,
+		Fingerprint: fingerprint,
+		CreateAt:    now,
+		ExpiresAt:   now.Add(u.ttl),
+	}
+	reserved, err := u.repo.Reserve(ctx, key)
+	if err != nil {
+		return nil, false, err
+	}
+	if reserved {
+		return key, false, nil
+	}
+
+	earlier, err := u.repo.Get(ctx, userID, name)
+	if err == base.ErrorNotFound {
+		// released by a failed request meanwhile
+		return nil, false, errInProgress
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	if earlier.Fingerprint != fingerprint {
+		u.Debug(ctx).Uint64("user_id", userID).Str("key", name).Msg("IdempotencyKeyReused")
+		return nil, false, base.NewError(http.StatusUnprocessableEntity, base.CodeIdempotencyKeyReused,
+			"the idempotency key was used by a different request")
+	}
+	if earlier.InProgress() {
+		return nil, false, errInProgress
+	}
+	return earlier, true, nil
+}
+
+var errInProgress = base.NewError(http.StatusConflict, base.CodeIdempotencyInProgress,
+	"a request with the idempotency key is in progress")
+
+func (u *usecase) Complete(ctx context.Context, key *model.IdempotencyKey, status int, contentType string, body []byte) error {
+	key.Status = status
+	key.ContentType = contentType
+	key.Body = body
+	return u.repo.Complete(ctx, key)
+}
+
+func (u *usecase) Release(ctx context.Context, key *model.IdempotencyKey) error {
+	return u.repo.Delete(ctx, key)
+}
+
+func (u *usecase) Purge(ctx context.Context) (int64, error) {
+	return u.repo.DeleteExpired(ctx, time.Now())
+}
+
+func New() Usecase {
+	ttl := config.GetConfig[int64]("service.idempotency_ttl")
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	return &usecase{
+		Usecase: base.NewBaseUsecase("idempotency"),
+		repo:    idempotency.New(),
+		ttl:     time.Duration(ttl) * time.Second,
+	}
+}
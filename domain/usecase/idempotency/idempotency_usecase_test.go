@@ -0,0 +1,121 @@
+package idempotency
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/domain/repository/idempotency"
+	"github.com/tpp/msf/domain/repository/idempotency/mocks"
+	"github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/utils"
+)
+
+func Test_usecase_Begin(t *testing.T) {
+
+	mailer.NewMailer(&mailer.Config{Kind: "memory"})
+	baseUsecase := base.NewBaseUsecase("test_idempotency_usecase")
+	tests := []struct {
+		name   string
+		setup  utils.TestSetup[*idempotency.Repository]
+		expect func(r *require.Assertions, key *model.IdempotencyKey, replay bool, err error)
+	}{
+		{
+			name: "reserved",
+			setup: func(t *testing.T, _ *require.Assertions, r *idempotency.Repository) utils.TestTeardown {
+				repo := mocks.NewRepository(t)
+				repo.On("Reserve", mock.Anything, mock.MatchedBy(func(k *model.IdempotencyKey) bool {
+					return k.UserID == 1 && k.Key == "k1" && k.Fingerprint == "f1" && k.ExpiresAt.Sub(k.CreateAt) == time.Hour
+				})).Return(true, nil)
+				*r = repo
+				return nil
+			},
+			expect: func(r *require.Assertions, key *model.IdempotencyKey, replay bool, err error) {
+				r.NoError(err)
+				r.False(replay)
+				r.True(key.InProgress())
+			},
+		},
+		{
+			name: "replay",
+			setup: func(t *testing.T, _ *require.Assertions, r *idempotency.Repository) utils.TestTeardown {
+				repo := mocks.NewRepository(t)
+				repo.On("Reserve", mock.Anything, mock.Anything).Return(false, nil)
+				repo.On("Get", mock.Anything, uint64(1), "k1").Return(&model.IdempotencyKey{ID: 7, Fingerprint: "f1", Status: 201}, nil)
+				*r = repo
+				return nil
+			},
+			expect: func(r *require.Assertions, key *model.IdempotencyKey, replay bool, err error) {
+				r.NoError(err)
+				r.True(replay)
+				r.EqualValues(7, key.ID)
+			},
+		},
+		{
+			name: "reused by another request",
+			setup: func(t *testing.T, _ *require.Assertions, r *idempotency.Repository) utils.TestTeardown {
+				repo := mocks.NewRepository(t)
+				repo.On("Reserve", mock.Anything, mock.Anything).Return(false, nil)
+				repo.On("Get", mock.Anything, uint64(1), "k1").Return(&model.IdempotencyKey{Fingerprint: "f2", Status: 201}, nil)
+				*r = repo
+				return nil
+			},
+			expect: func(r *require.Assertions, key *model.IdempotencyKey, replay bool, err error) {
+				r.EqualValues(http.StatusUnprocessableEntity, base.AsError(err).Status)
+				r.EqualValues(base.CodeIdempotencyKeyReused, base.AsError(err).Code)
+			},
+		},
+		{
+			name: "in progress",
+			setup: func(t *testing.T, _ *require.Assertions, r *idempotency.Repository) utils.TestTeardown {
+				repo := mocks.NewRepository(t)
+				repo.On("Reserve", mock.Anything, mock.Anything).Return(false, nil)
+				repo.On("Get", mock.Anything, uint64(1), "k1").Return(&model.IdempotencyKey{Fingerprint: "f1"}, nil)
+				*r = repo
+				return nil
+			},
+			expect: func(r *require.Assertions, key *model.IdempotencyKey, replay bool, err error) {
+				r.EqualValues(http.StatusConflict, base.AsError(err).Status)
+				r.EqualValues(base.CodeIdempotencyInProgress, base.AsError(err).Code)
+			},
+		},
+		{
+			name: "released meanwhile",
+			setup: func(t *testing.T, _ *require.Assertions, r *idempotency.Repository) utils.TestTeardown {
+				repo := mocks.NewRepository(t)
+				repo.On("Reserve", mock.Anything, mock.Anything).Return(false, nil)
+				repo.On("Get", mock.Anything, uint64(1), "k1").Return(nil, base.ErrorNotFound)
+				*r = repo
+				return nil
+			},
+			expect: func(r *require.Assertions, key *model.IdempotencyKey, replay bool, err error) {
+				r.EqualValues(http.StatusConflict, base.AsError(err).Status)
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			var repo idempotency.Repository
+			var assertion = require.New(t)
+			td := tt.setup(t, assertion, &repo)
+			if td != nil {
+				defer td()
+			}
+			u := &usecase{
+				Usecase: baseUsecase,
+				repo:    repo,
+				ttl:     time.Hour,
+			}
+			key, replay, err := u.Begin(context.Background(), 1, "k1", "f1")
+			tt.expect(assertion, key, replay, err)
+
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"gopkg.in/yaml.v3"
+)
+
+// ParsePolicy decode the YAML policy of r, the unknown fields are errors so that a typo is not silently ignored
+func ParsePolicy(r io.Reader) (*model.RBACPolicy, error) {
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	var policy model.RBACPolicy
+	if err := dec.Decode(&policy); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = errors.New("the policy is empty")
+		}
+		return nil, base.NewBadRequestError(base.CodeMalformedRequest, fmt.Sprintf("invalid policy: %s", err))
+	}
+	return &policy, nil
+}
+
+// WritePolicy encode policy as YAML into w
+func WritePolicy(w io.Writer, policy *model.RBACPolicy) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(policy); err != nil {
+		return err
+	}
+	return enc.Close()
+}
@@ -0,0 +1,378 @@
+package rbac
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tpp/msf/domain/repository/rbac"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+)
+
+type Usecase interface {
+	// Export the active roles and the permissions as a policy, sorted by name so that it diffs well in git
+	Export(ctx context.Context) (*model.RBACPolicy, error)
+	// Plan the changes importing policy, nothing is changed
+	Plan(ctx context.Context, policy *model.RBACPolicy) (*model.RBACPlan, error)
+	// Apply import policy, the changes of its plan are made in the transaction of ctx
+	Apply(ctx context.Context, policy *model.RBACPolicy) (*model.RBACPlan, error)
+}
+
+type usecase struct {
+	base.Usecase
+	repo rbac.Repository
+}
+
+// state the roles and the permissions in the database
+type state struct {
+	permissions map[string]*model.Permission
+	roles       map[string]*model.Role
+	// granted the ids of the permissions granted to each role
+	granted map[uint64]map[uint64]bool
+}
+
+func (u *usecase) load(ctx context.Context) (*state, error) {
+	permissions, err := u.repo.ListPermissions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := u.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	bindings, err := u.repo.ListBindings(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &state{
+		permissions: map[string]*model.Permission{},
+		roles:       map[string]*model.Role{},
+		granted:     map[uint64]map[uint64]bool{},
+	}
+	for _, permission := range permissions {
+		s.permissions[permission.Name] = permission
+	}
+	for _, role := range roles {
+		// nothing tells which one of the roles of the same name a policy is about
+		if other, ok := s.roles[string(role.Name)]; ok {
+			return nil, base.NewApiErrors("roles", fmt.Sprintf("the roles %d and %d are both named %q, rename one of them", other.ID, role.ID, role.Name))
+		}
+		s.roles[string(role.Name)] = role
+		s.granted[role.ID] = map[uint64]bool{}
+	}
+	for _, binding := range bindings {
+		if granted, ok := s.granted[binding.RoleID]; ok {
+			granted[binding.PermissionID] = true
+		}
+	}
+	return s, nil
+}
+
+func (u *usecase) Export(ctx context.Context) (*model.RBACPolicy, error) {
+	s, err := u.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	policy := &model.RBACPolicy{Permissions: []*model.PolicyPermission{}, Roles: []*model.PolicyRole{}}
+	permissionNames := map[uint64]string{}
+	for name, permission := range s.permissions {
+		permissionNames[permission.ID] = name
+		policy.Permissions = append(policy.Permissions, &model.PolicyPermission{Name: name})
+	}
+	sort.Slice(policy.Permissions, func(i, j int) bool { return policy.Permissions[i].Name < policy.Permissions[j].Name })
+
+	for name, role := range s.roles {
+		policyRole := &model.PolicyRole{Name: name, Permissions: []string{}}
+		for permissionID := range s.granted[role.ID] {
+			policyRole.Permissions = append(policyRole.Permissions, permissionNames[permissionID])
+		}
+		sort.Strings(policyRole.Permissions)
+		policy.Roles = append(policy.Roles, policyRole)
+	}
+	sort.Slice(policy.Roles, func(i, j int) bool { return policy.Roles[i].Name < policy.Roles[j].Name })
+	return policy, nil
+}
+
+func (u *usecase) Plan(ctx context.Context, policy *model.RBACPolicy) (*model.RBACPlan, error) {
+	if err := validatePolicy(policy); err != nil {
+		return nil, err
+	}
+	s, err := u.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return u.plan(ctx, s, policy)
+}
+
+func (u *usecase) Apply(ctx context.Context, policy *model.RBACPolicy) (*model.RBACPlan, error) {
+	if err := validatePolicy(policy); err != nil {
+		return nil, err
+	}
+	// the plan must be the one of the state it is applied to
+	if err := u.repo.Lock(ctx); err != nil {
+		return nil, err
+	}
+	s, err := u.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := u.plan(ctx, s, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	permissionIDs, roleIDs := map[string]uint64{}, map[string]uint64{}
+	for name, permission := range s.permissions {
+		permissionIDs[name] = permission.ID
+	}
+	for name, role := range s.roles {
+		roleIDs[name] = role.ID
+	}
+	changedRoles := map[uint64]bool{}
+	for _, change := range plan.Changes {
+		if err = u.apply(ctx, change, permissionIDs, roleIDs, changedRoles); err != nil {
+			return nil, err
+		}
+	}
+	for roleID := range changedRoles {
+		if err = u.repo.BumpRoleVersion(ctx, roleID); err != nil {
+			return nil, err
+		}
+	}
+
+	plan.Applied = true
+	u.Info(ctx).Int("changes", len(plan.Changes)).Msg("RBACPolicyApplied")
+	return plan, nil
+}
+
+// apply make change, the ids of the permissions and the roles by name are updated along
+func (u *usecase) apply(ctx context.Context, change *model.RBACChange, permissionIDs, roleIDs map[string]uint64, changedRoles map[uint64]bool) error {
+	switch {
+	case change.Action == model.RBACRename && change.Role == "":
+		permissionIDs[change.Permission] = change.PermissionID
+		return u.repo.RenamePermission(ctx, change.PermissionID, change.Permission)
+	case change.Action == model.RBACAdd && change.Role == "":
+		permission := &model.Permission{Name: change.Permission}
+		if err := u.repo.CreatePermission(ctx, permission); err != nil {
+			return err
+		}
+		permissionIDs[permission.Name] = permission.ID
+	case change.Action == model.RBACRemove && change.Role == "":
+		return u.repo.DeletePermission(ctx, change.PermissionID)
+	case change.Action == model.RBACRename:
+		roleIDs[change.Role] = change.RoleID
+		changedRoles[change.RoleID] = true
+		return u.repo.RenameRole(ctx, change.RoleID, change.Role)
+	case change.Action == model.RBACAdd:
+		role := &model.Role{Name: model.RoleName(change.Role)}
+		if err := u.repo.CreateRole(ctx, role); err != nil {
+			return err
+		}
+		roleIDs[change.Role] = role.ID
+	case change.Action == model.RBACRemove:
+		return u.repo.DeactivateRole(ctx, change.RoleID)
+	case change.Action == model.RBACGrant:
+		// the new roles are at their first version
+		if change.RoleID != 0 {
+			changedRoles[change.RoleID] = true
+		}
+		return u.repo.Grant(ctx, roleIDs[change.Role], permissionIDs[change.Permission])
+	case change.Action == model.RBACRevoke:
+		changedRoles[change.RoleID] = true
+		return u.repo.Revoke(ctx, change.RoleID, change.PermissionID)
+	}
+	return nil
+}
+
+// plan the changes from s to policy: the renames, the additions, the grants, the revocations then the removals
+func (u *usecase) plan(ctx context.Context, s *state, policy *model.RBACPolicy) (*model.RBACPlan, error) {
+	var renames, adds, grants, revokes, removes []*model.RBACChange
+
+	// the permission of the database each permission of the policy is, nil for the new ones
+	permissions := map[string]*model.Permission{}
+	keptPermissions := map[uint64]bool{}
+	for i, p := range policy.Permissions {
+		existing := s.permissions[p.Name]
+		if previous := s.permissions[p.RenamedFrom]; previous != nil {
+			if existing != nil {
+				return nil, base.NewApiErrors(fmt.Sprintf("permissions[%d].renamed_from", i), fmt.Sprintf("both %q and %q exist", p.RenamedFrom, p.Name))
+			}
+			existing = previous
+			renames = append(renames, &model.RBACChange{Action: model.RBACRename, Permission: p.Name, From: p.RenamedFrom, PermissionID: previous.ID})
+		} else if existing == nil {
+			adds = append(adds, &model.RBACChange{Action: model.RBACAdd, Permission: p.Name})
+		}
+		permissions[p.Name] = existing
+		if existing != nil {
+			keptPermissions[existing.ID] = true
+		}
+	}
+
+	var roleAdds []*model.RBACChange
+	keptRoles := map[uint64]bool{}
+	for i, r := range policy.Roles {
+		existing := s.roles[r.Name]
+		if previous := s.roles[r.RenamedFrom]; previous != nil {
+			if existing != nil {
+				return nil, base.NewApiErrors(fmt.Sprintf("roles[%d].renamed_from", i), fmt.Sprintf("both %q and %q exist", r.RenamedFrom, r.Name))
+			}
+			existing = previous
+			renames = append(renames, &model.RBACChange{Action: model.RBACRename, Role: r.Name, From: r.RenamedFrom, RoleID: previous.ID})
+		} else if existing == nil {
+			roleAdds = append(roleAdds, &model.RBACChange{Action: model.RBACAdd, Role: r.Name})
+		}
+
+		granted := map[uint64]bool{}
+		if existing != nil {
+			keptRoles[existing.ID] = true
+			granted = s.granted[existing.ID]
+		}
+		wanted := map[uint64]bool{}
+		for _, name := range r.Permissions {
+			permission := permissions[name]
+			if permission != nil {
+				wanted[permission.ID] = true
+			}
+			if permission == nil || !granted[permission.ID] {
+				grant := &model.RBACChange{Action: model.RBACGrant, Role: r.Name, Permission: name}
+				if existing != nil {
+					grant.RoleID = existing.ID
+				}
+				grants = append(grants, grant)
+			}
+		}
+		for _, p := range s.permissions {
+			// the grants of the removed permissions go along with them
+			if granted[p.ID] && !wanted[p.ID] && keptPermissions[p.ID] {
+				revokes = append(revokes, &model.RBACChange{Action: model.RBACRevoke, Role: r.Name, Permission: policyName(policy, p), RoleID: existing.ID, PermissionID: p.ID})
+			}
+		}
+	}
+	adds = append(adds, roleAdds...)
+
+	for _, role := range s.roles {
+		if keptRoles[role.ID] {
+			continue
+		}
+		users, err := u.repo.CountUsers(ctx, role.ID)
+		if err != nil {
+			return nil, err
+		}
+		removes = append(removes, &model.RBACChange{Action: model.RBACRemove, Role: string(role.Name), RoleID: role.ID, Users: users})
+	}
+	for _, permission := range s.permissions {
+		if !keptPermissions[permission.ID] {
+			removes = append(removes, &model.RBACChange{Action: model.RBACRemove, Permission: permission.Name, PermissionID: permission.ID})
+		}
+	}
+
+	// the changes of the same kind are sorted so that the plans of the same state are the same
+	sortChanges(revokes)
+	sortChanges(removes)
+	changes := []*model.RBACChange{}
+	for _, group := range [][]*model.RBACChange{renames, adds, grants, revokes, removes} {
+		changes = append(changes, group...)
+	}
+	return &model.RBACPlan{Changes: changes}, nil
+}
+
+// policyName the name of the permission p in policy, renamed or not
+func policyName(policy *model.RBACPolicy, p *model.Permission) string {
+	for _, pp := range policy.Permissions {
+		if pp.RenamedFrom == p.Name {
+			return pp.Name
+		}
+	}
+	return p.Name
+}
+
+func sortChanges(changes []*model.RBACChange) {
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Role != changes[j].Role {
+			return changes[i].Role < changes[j].Role
+		}
+		return changes[i].Permission < changes[j].Permission
+	})
+}
+
+// validatePolicy check the names of policy are set and unique, and its roles are granted its permissions only
+func validatePolicy(policy *model.RBACPolicy) error {
+	var errs base.APIErrors
+	invalid := func(field, msg string) { errs = append(errs, &base.APIError{Field: field, Message: msg}) }
+
+	permissions := map[string]bool{}
+	for i, p := range policy.Permissions {
+		if p == nil {
+			invalid(fmt.Sprintf("permissions[%d]", i), "This field is required")
+			continue
+		}
+		p.Name, p.RenamedFrom = strings.TrimSpace(p.Name), strings.TrimSpace(p.RenamedFrom)
+		if p.Name == "" {
+			invalid(fmt.Sprintf("permissions[%d].name", i), "This field is required")
+		} else if permissions[p.Name] {
+			invalid(fmt.Sprintf("permissions[%d].name", i), fmt.Sprintf("the permission %q is declared twice", p.Name))
+		}
+		permissions[p.Name] = true
+	}
+	roles := map[string]bool{}
+	for i, r := range policy.Roles {
+		if r == nil {
+			invalid(fmt.Sprintf("roles[%d]", i), "This field is required")
+			continue
+		}
+		r.Name, r.RenamedFrom = strings.TrimSpace(r.Name), strings.TrimSpace(r.RenamedFrom)
+		if r.Name == "" {
+			invalid(fmt.Sprintf("roles[%d].name", i), "This field is required")
+		} else if roles[r.Name] {
+			invalid(fmt.Sprintf("roles[%d].name", i), fmt.Sprintf("the role %q is declared twice", r.Name))
+		}
+		roles[r.Name] = true
+
+		granted := map[string]bool{}
+		for j, name := range r.Permissions {
+			field := fmt.Sprintf("roles[%d].permissions[%d]", i, j)
+			if !permissions[name] {
+				invalid(field, fmt.Sprintf("the permission %q is not declared", name))
+			} else if granted[name] {
+				invalid(field, fmt.Sprintf("the permission %q is granted twice", name))
+			}
+			granted[name] = true
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	// an entity renamed into another one would be both
+	renamed := map[string]bool{}
+	for i, p := range policy.Permissions {
+		if p.RenamedFrom != "" && (permissions[p.RenamedFrom] || renamed[p.RenamedFrom]) {
+			invalid(fmt.Sprintf("permissions[%d].renamed_from", i), fmt.Sprintf("the permission %q is declared or renamed by another permission", p.RenamedFrom))
+		}
+		renamed[p.RenamedFrom] = true
+	}
+	renamed = map[string]bool{}
+	for i, r := range policy.Roles {
+		if r.RenamedFrom != "" && (roles[r.RenamedFrom] || renamed[r.RenamedFrom]) {
+			invalid(fmt.Sprintf("roles[%d].renamed_from", i), fmt.Sprintf("the role %q is declared or renamed by another role", r.RenamedFrom))
+		}
+		renamed[r.RenamedFrom] = true
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func New() Usecase {
+	return &usecase{
+		Usecase: base.NewBaseUsecase("rbac"),
+		repo:    rbac.New(),
+	}
+}
@@ -0,0 +1,216 @@
+package rbac
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/domain/repository/rbac/mocks"
+	"github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+)
+
+// setupState the permissions Users (1), Roles (2) and Services (3), the roles Planner Team (1) granted Users and
+// Services, and Supply Vendor (3) granted Roles
+func setupState(repo *mocks.Repository) {
+	repo.On("ListPermissions", mock.Anything).Return([]*model.Permission{
+		{ID: 1, Name: "Users"}, {ID: 2, Name: "Roles"}, {ID: 3, Name: "Services"},
+	}, nil)
+	repo.On("ListRoles", mock.Anything).Return([]*model.Role{
+		{ID: 1, Name: "Planner Team"}, {ID: 3, Name: "Supply Vendor"},
+	}, nil)
+	repo.On("ListBindings", mock.Anything).Return([]*model.RBACBinding{
+		{RoleID: 1, PermissionID: 1}, {RoleID: 1, PermissionID: 3}, {RoleID: 3, PermissionID: 2},
+	}, nil)
+}
+
+func newTestUsecase(repo *mocks.Repository) *usecase {
+	mailer.NewMailer(&mailer.Config{Kind: "memory"})
+	return &usecase{Usecase: base.NewBaseUsecase("test_rbac_usecase"), repo: repo}
+}
+
+func Test_usecase_Export(t *testing.T) {
+	repo := mocks.NewRepository(t)
+	setupState(repo)
+	u := newTestUsecase(repo)
+
+	policy, err := u.Export(context.Background())
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, WritePolicy(&out, policy))
+	require.EqualValues(t, `permissions:
+  - name: Roles
+  - name: Services
+  - name: Users
+roles:
+  - name: Planner Team
+    permissions:
+      - Services
+      - Users
+  - name: Supply Vendor
+    permissions:
+      - Roles
+`, out.String())
+
+	parsed, err := ParsePolicy(&out)
+	require.NoError(t, err)
+	require.EqualValues(t, policy, parsed)
+}
+
+func Test_usecase_Plan(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		setup  func(repo *mocks.Repository)
+		expect func(r *require.Assertions, plan *model.RBACPlan, err error)
+	}{
+		{
+			name: "unchanged",
+			policy: `
+permissions: [{name: Users}, {name: Roles}, {name: Services}]
+roles:
+  - {name: Planner Team, permissions: [Users, Services]}
+  - {name: Supply Vendor, permissions: [Roles]}
+`,
+			expect: func(r *require.Assertions, plan *model.RBACPlan, err error) {
+				r.NoError(err)
+				r.Empty(plan.Changes)
+			},
+		},
+		{
+			name: "changed",
+			policy: `
+permissions:
+  - name: Users
+  - name: Accounts
+    renamed_from: Roles
+  - name: Contracts
+roles:
+  - name: Planners
+    renamed_from: Planner Team
+    permissions: [Users, Contracts]
+  - name: Auditor
+    permissions: [Accounts]
+`,
+			setup: func(repo *mocks.Repository) {
+				repo.On("CountUsers", mock.Anything, uint64(3)).Return(int64(4), nil)
+			},
+			expect: func(r *require.Assertions, plan *model.RBACPlan, err error) {
+				r.NoError(err)
+				var lines []string
+				for _, change := range plan.Changes {
+					lines = append(lines, change.String())
+				}
+				r.EqualValues([]string{
+					`~ permission "Roles" renamed "Accounts"`,
+					`~ role "Planner Team" renamed "Planners"`,
+					`+ permission "Contracts"`,
+					`+ role "Auditor"`,
+					`+ grant "Contracts" to role "Planners"`,
+					`+ grant "Accounts" to role "Auditor"`,
+					`- permission "Services"`,
+					`- role "Supply Vendor", held by 4 users`,
+				}, lines)
+				r.False(plan.Applied)
+			},
+		},
+		{
+			name: "revoked",
+			policy: `
+permissions: [{name: Users}, {name: Roles}, {name: Services}]
+roles:
+  - {name: Planner Team, permissions: [Users]}
+  - {name: Supply Vendor, permissions: [Roles]}
+`,
+			expect: func(r *require.Assertions, plan *model.RBACPlan, err error) {
+				r.NoError(err)
+				r.Len(plan.Changes, 1)
+				r.EqualValues(&model.RBACChange{Action: model.RBACRevoke, Role: "Planner Team", Permission: "Services", RoleID: 1, PermissionID: 3}, plan.Changes[0])
+			},
+		},
+		{
+			name: "renamed into an existing one",
+			policy: `
+permissions: [{name: Users, renamed_from: Roles}, {name: Services}]
+roles: []
+`,
+			expect: func(r *require.Assertions, plan *model.RBACPlan, err error) {
+				r.EqualValues(base.NewApiErrors("permissions[0].renamed_from", `both "Roles" and "Users" exist`), err)
+			},
+		},
+		{
+			name: "invalid",
+			policy: `
+permissions: [{name: Users}, {name: Users}]
+roles:
+  - {name: "", permissions: [Roles]}
+`,
+			expect: func(r *require.Assertions, plan *model.RBACPlan, err error) {
+				r.EqualValues(base.APIErrors{
+					{Field: "permissions[1].name", Message: `the permission "Users" is declared twice`},
+					{Field: "roles[0].name", Message: "This field is required"},
+					{Field: "roles[0].permissions[0]", Message: `the permission "Roles" is not declared`},
+				}, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mocks.Repository{}
+			setupState(repo)
+			if tt.setup != nil {
+				tt.setup(repo)
+			}
+			u := newTestUsecase(repo)
+
+			policy, err := ParsePolicy(strings.NewReader(tt.policy))
+			require.NoError(t, err)
+			plan, err := u.Plan(context.Background(), policy)
+			tt.expect(require.New(t), plan, err)
+		})
+	}
+}
+
+func Test_usecase_Apply(t *testing.T) {
+	repo := mocks.NewRepository(t)
+	setupState(repo)
+	repo.On("Lock", mock.Anything).Return(nil)
+	repo.On("RenameRole", mock.Anything, uint64(3), "Vendor").Return(nil)
+	repo.On("CreatePermission", mock.Anything, mock.AnythingOfType("*model.Permission")).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Permission).ID = 4
+	}).Return(nil)
+	repo.On("CreateRole", mock.Anything, mock.AnythingOfType("*model.Role")).Run(func(args mock.Arguments) {
+		args.Get(1).(*model.Role).ID = 5
+	}).Return(nil)
+	repo.On("Grant", mock.Anything, uint64(3), uint64(4)).Return(nil)
+	repo.On("Grant", mock.Anything, uint64(5), uint64(1)).Return(nil)
+	repo.On("Revoke", mock.Anything, uint64(1), uint64(3)).Return(nil)
+	repo.On("BumpRoleVersion", mock.Anything, uint64(1)).Return(nil)
+	repo.On("BumpRoleVersion", mock.Anything, uint64(3)).Return(nil)
+	u := newTestUsecase(repo)
+
+	policy, err := ParsePolicy(strings.NewReader(`
+permissions: [{name: Users}, {name: Roles}, {name: Services}, {name: Contracts}]
+roles:
+  - {name: Planner Team, permissions: [Users]}
+  - {name: Vendor, renamed_from: Supply Vendor, permissions: [Roles, Contracts]}
+  - {name: Auditor, permissions: [Users]}
+`))
+	require.NoError(t, err)
+	plan, err := u.Apply(context.Background(), policy)
+	require.NoError(t, err)
+	require.True(t, plan.Applied)
+	require.Len(t, plan.Changes, 6)
+}
+
+func TestParsePolicy(t *testing.T) {
+	_, err := ParsePolicy(strings.NewReader("permissions: [{name: Users, scope: all}]"))
+	require.ErrorContains(t, err, "field scope not found")
+	_, err = ParsePolicy(strings.NewReader(""))
+	require.ErrorContains(t, err, "the policy is empty")
+}
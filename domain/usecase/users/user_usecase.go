@@ -7,32 +7,56 @@ import (
 	"github.com/tpp/msf/shared/utils"
 	"gorm.io/gorm"
 	"strings"
+	"time"
 
+	"github.com/tpp/msf/domain/repository/logins"
+	"github.com/tpp/msf/domain/repository/sessions"
 	"github.com/tpp/msf/domain/repository/users"
 	"github.com/tpp/msf/model"
 	"github.com/tpp/msf/shared/base"
 	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/mailtemplate"
 )
 
 type Usecase interface {
-	ListUsers(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.User, int64, error)
+	ListUsers(ctx context.Context, params *base.ListParams) ([]*model.User, *base.Page, error)
 	GetUser(ctx context.Context, userID uint64) (*model.User, error)
+	// FindUser the user with the fields and the expansions of fs
+	FindUser(ctx context.Context, userID uint64, fs *base.Fieldset) (*model.User, error)
 	CreateUser(ctx context.Context, user *model.User) error
 	CreateRole(ctx context.Context, user *model.Role) error
 	AssignRole(ctx context.Context, userID int64, roleIDs []int64) error
 	UpdatePassWord(ctx context.Context, userID int64, passWord string) error
 	AssignMultipleRole(ctx context.Context, userID int64, roleIds []int64) error
 	UpdateName(ctx context.Context, name string, userID int64) error
+	UpdateLocale(ctx context.Context, locale string, userID int64) error
 	UpdateActive(ctx context.Context, userID int64, isActive bool) error
+	ValidateSession(ctx context.Context, tokenID string) (*model.Session, error)
+	ListSessions(ctx context.Context, userID uint64) ([]*model.Session, error)
+	RevokeSession(ctx context.Context, userID, sessionID uint64) error
+	RevokeSessions(ctx context.Context, userID uint64) error
+	ListLoginHistory(ctx context.Context, userID uint64, params *base.ListParams) ([]*model.LoginEvent, *base.Page, error)
+	Impersonate(ctx context.Context, userID uint64, client model.ClientInfo) (session *model.Session, accessToken string, err error)
+	// IssueToken open a session of userID without its password, for the operators debugging with the CLI, a ttl of 0
+	// is the one of the tokens of the logins
+	IssueToken(ctx context.Context, userID uint64, ttl time.Duration, client model.ClientInfo) (session *model.Session, accessToken string, err error)
+	// ClaimVersion check the version of the If-Match of a mutation of the user then increment it, the user is
+	// locked until the end of the transaction
+	ClaimVersion(ctx context.Context, userID, version uint64) (uint64, error)
 }
 
 type usecase struct {
 	base.Usecase
-	userRepo users.Repository
+	userRepo    users.Repository
+	sessionRepo sessions.Repository
+	loginRepo   logins.Repository
 }
 
-func (u *usecase) ListUsers(ctx context.Context, limit, offset int, sort string, filters base.Filters) ([]*model.User, int64, error) {
-	return u.userRepo.List(ctx, limit, offset, sort, filters)
+// sessionTouchInterval how often the last seen time of a session is refreshed
+const sessionTouchInterval = time.Minute
+
+func (u *usecase) ListUsers(ctx context.Context, params *base.ListParams) ([]*model.User, *base.Page, error) {
+	return u.userRepo.List(ctx, params)
 }
 
 func (u *usecase) GetUser(ctx context.Context, userID uint64) (*model.User, error) {
@@ -48,6 +72,10 @@ func (u *usecase) GetUser(ctx context.Context, userID uint64) (*model.User, erro
 	return user, nil
 }
 
+func (u *usecase) FindUser(ctx context.Context, userID uint64, fs *base.Fieldset) (*model.User, error) {
+	return u.userRepo.Find(ctx, userID, fs)
+}
+
 func (u *usecase) CreateUser(ctx context.Context, user *model.User) error {
 
 	autoPassword := xid.New().String()[:6]
@@ -60,17 +88,25 @@ func (u *usecase) CreateUser(ctx context.Context, user *model.User) error {
 
 			return base.NewApiErrors("email", "email already existed")
 		}
-	} else {
-		accessToken, err := helper.GenerateJWTToken(user1.ID)
-		err = u.SendEmail(accessToken, user1.Email, "active_template.html", "Active your account here")
-		if err != nil {
-			u.Info(ctx).Err(err).Msg("Can not send mail")
+		return err
+	}
+	err = u.userRepo.TimeCreateUser(ctx, int64(user1.ID))
+	if err != nil {
+		return err
+	}
+	// the activation link is useless unless the user is committed
+	ctx.AfterCommit(func() {
+		token, err := helper.GenerateActionToken(helper.ActionActivateAccount, helper.PasswordClaim{
+			UserID: user1.ID,
+			Stamp:  helper.PasswordStamp(hashPassword),
+		}, helper.TokenTTL())
+		if err == nil {
+			err = u.SendEmail(user1.User, mailtemplate.ActivateAccount, token, nil)
 		}
-		err = u.userRepo.TimeCreateUser(ctx, int64(user1.ID))
 		if err != nil {
-			return err
+			u.Info(ctx).Err(err).Msg("Can not send mail")
 		}
-	}
+	})
 	return nil
 }
 func (u *usecase) CreateRole(ctx context.Context, role *model.Role) error {
@@ -128,7 +164,9 @@ func (u *usecase) AssignRole(ctx context.Context, userID int64, roleIDs []int64)
 				UserId: userID, RoleId: role,
 			})
 		}
-		err = u.userRepo.AssignMultipleRole(ctx, userRoles)
+		if err := u.userRepo.AssignMultipleRole(ctx, userRoles); err != nil {
+			return err
+		}
 	}
 	if len(roleIdsForDelete) > 0 {
 		err1 := u.userRepo.DeleteRolesByUserID(ctx, roleIdsForDelete, userID)
@@ -170,13 +208,156 @@ func (u *usecase) AssignMultipleRole(ctx context.Context, userID int64, roleIds
 func (u *usecase) UpdateName(ctx context.Context, name string, userID int64) error {
 	return u.userRepo.UpdateName(ctx, name, userID)
 }
+func (u *usecase) UpdateLocale(ctx context.Context, locale string, userID int64) error {
+	return u.userRepo.UpdateLocale(ctx, locale, userID)
+}
 func (u *usecase) UpdateActive(ctx context.Context, userID int64, isActive bool) error {
-	return u.userRepo.UpdateActive(ctx, userID, isActive)
+	if err := u.userRepo.UpdateActive(ctx, userID, isActive); err != nil {
+		return err
+	}
+	if !isActive {
+		return u.sessionRepo.RevokeAllByUserID(ctx, uint64(userID))
+	}
+	return nil
+}
+
+// ValidateSession check the session of tokenID is still active and refresh its last seen time
+func (u *usecase) ValidateSession(ctx context.Context, tokenID string) (*model.Session, error) {
+	session, err := u.sessionRepo.GetByTokenID(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.IsActive() {
+		return nil, base.ErrorNotFound
+	}
+	if time.Since(session.LastSeenAt) > sessionTouchInterval {
+		if err = u.sessionRepo.Touch(ctx, session.ID); err != nil {
+			return nil, err
+		}
+	}
+	return session, nil
 }
+
+func (u *usecase) ListSessions(ctx context.Context, userID uint64) ([]*model.Session, error) {
+	sessions, err := u.sessionRepo.ListActiveByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, session := range sessions {
+		session.Current = session.TokenID == ctx.TokenID()
+	}
+	return sessions, nil
+}
+
+func (u *usecase) RevokeSession(ctx context.Context, userID, sessionID uint64) error {
+	return u.sessionRepo.Revoke(ctx, userID, sessionID)
+}
+
+func (u *usecase) RevokeSessions(ctx context.Context, userID uint64) error {
+	return u.sessionRepo.RevokeAllByUserID(ctx, userID)
+}
+
+func (u *usecase) ListLoginHistory(ctx context.Context, userID uint64, params *base.ListParams) ([]*model.LoginEvent, *base.Page, error) {
+	return u.loginRepo.ListByUserID(ctx, userID, params)
+}
+
+// Impersonate open a short-lived session of userID on behalf of the current user
+func (u *usecase) Impersonate(ctx context.Context, userID uint64, client model.ClientInfo) (*model.Session, string, error) {
+	impersonator := ctx.Actor()
+	if impersonator.ID == userID {
+		return nil, "", base.NewApiErrors("id", "you can not impersonate yourself")
+	}
+
+	user, err := u.GetUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if user.IsAdmin {
+		return nil, "", base.NewApiErrors("id", "an administrator can not be impersonated")
+	}
+	if !user.Status {
+		return nil, "", base.NewApiErrors("id", "this user is not active")
+	}
+
+	tokenID := xid.New().String()
+	accessToken, err := helper.GenerateImpersonationToken(user, impersonator.ID, tokenID)
+	if err != nil {
+		u.Error(ctx).Err(err).Msg("ImpersonateError")
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &model.Session{
+		TokenID:        tokenID,
+		UserID:         user.ID,
+		Device:         "Impersonated by " + impersonator.Email,
+		UserAgent:      client.UserAgent,
+		IP:             client.IP,
+		CreateAt:       now,
+		LastSeenAt:     now,
+		ExpiresAt:      now.Add(helper.ImpersonationTTL()),
+		ImpersonatorID: &impersonator.ID,
+	}
+	if err = u.sessionRepo.Create(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	u.Info(ctx).
+		Uint64("impersonator_id", impersonator.ID).
+		Uint64("user_id", user.ID).
+		Uint64("session_id", session.ID).
+		Msg("ImpersonationStarted")
+	return session, accessToken, nil
+}
+
+func (u *usecase) IssueToken(ctx context.Context, userID uint64, ttl time.Duration, client model.ClientInfo) (*model.Session, string, error) {
+	user, err := u.GetUser(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	if !user.Status {
+		return nil, "", base.NewApiErrors("id", "this user is not active")
+	}
+	if ttl <= 0 {
+		ttl = helper.TokenTTL()
+	}
+
+	tokenID := xid.New().String()
+	accessToken, err := helper.GenerateJWTTokenWithTTL(user, tokenID, ttl)
+	if err != nil {
+		u.Error(ctx).Err(err).Msg("IssueTokenError")
+		return nil, "", err
+	}
+
+	now := time.Now()
+	session := &model.Session{
+		TokenID:    tokenID,
+		UserID:     user.ID,
+		Device:     "Issued by the CLI",
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreateAt:   now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(ttl),
+	}
+	if err = u.sessionRepo.Create(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	u.Info(ctx).Uint64("user_id", user.ID).Uint64("session_id", session.ID).Msg("TokenIssued")
+	return session, accessToken, nil
+}
+
+func (u *usecase) ClaimVersion(ctx context.Context, userID, version uint64) (uint64, error) {
+	return u.userRepo.BumpUserVersion(ctx, userID, version)
+}
+
 func New() Usecase {
 	return &usecase{
-		Usecase:  base.NewBaseUsecase("users"),
-		userRepo: users.New(),
+		Usecase:     base.NewBaseUsecase("users"),
+		userRepo:    users.New(),
+		sessionRepo: sessions.New(),
+		loginRepo:   logins.New(),
 	}
 }
 func getElementNotExistedOtherArrAndTheSame(source []int64, dest []int64) ([]int64, []int64) {
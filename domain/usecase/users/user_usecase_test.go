@@ -1,43 +1,52 @@
 package users
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	entity "github.com/tpp/msf/domain/repository"
+	sessionmocks "github.com/tpp/msf/domain/repository/sessions/mocks"
 	"github.com/tpp/msf/domain/repository/users"
 	"github.com/tpp/msf/domain/repository/users/mocks"
+	"github.com/tpp/msf/external-adapter/mailer"
 	"github.com/tpp/msf/model"
+	helper "github.com/tpp/msf/shared/auth"
 	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
 	"github.com/tpp/msf/shared/utils"
 )
 
 func Test_usecase_ListUsers(t *testing.T) {
 
+	mailer.NewMailer(&mailer.Config{Kind: "memory"})
 	baseUsecase := base.NewBaseUsecase("test_user_usecase")
 	tests := []struct {
 		name   string
 		setup  utils.TestSetup[*users.Repository]
-		expect func(r *require.Assertions, users []*model.User, total int64, err error)
+		expect func(r *require.Assertions, users []*model.User, page *base.Page, err error)
 	}{
 		// TODO: Add test cases.
 		{
 			name: "success",
 			setup: func(t *testing.T, _ *require.Assertions, r *users.Repository) utils.TestTeardown {
 				repo := mocks.NewRepository(t)
-				repo.On("List", mock.Anything, 10, 20, "", base.Filters(nil)).Return(
+				total := int64(2)
+				repo.On("List", mock.Anything, &base.ListParams{Limit: 10, Offset: 2}).Return(
 					[]*model.User{{ID: 1}, {ID: 2}},
-					int64(2),
+					&base.Page{Total: &total},
 					nil,
 				)
 
 				*r = repo
 				return func() {}
 			},
-			expect: func(r *require.Assertions, users []*model.User, total int64, err error) {
+			expect: func(r *require.Assertions, users []*model.User, page *base.Page, err error) {
 				r.EqualValues(2, len(users))
 				r.EqualValues([]*model.User{{ID: 1}, {ID: 2}}, users)
-				r.EqualValues(2, total)
+				r.EqualValues(2, *page.Total)
 				r.NoError(err)
 			},
 		},
@@ -56,8 +65,224 @@ func Test_usecase_ListUsers(t *testing.T) {
 				Usecase:  baseUsecase,
 				userRepo: repo,
 			}
-			users, total, err := u.ListUsers(nil, 10, 20, "", nil)
-			tt.expect(assertion, users, total, err)
+			users, page, err := u.ListUsers(nil, &base.ListParams{Limit: 10, Offset: 2})
+			tt.expect(assertion, users, page, err)
+
+		})
+	}
+}
+
+func Test_usecase_UpdateActive(t *testing.T) {
+
+	mailer.NewMailer(&mailer.Config{Kind: "memory"})
+	baseUsecase := base.NewBaseUsecase("test_user_usecase")
+	tests := []struct {
+		name     string
+		isActive bool
+		setup    func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository)
+	}{
+		{
+			name:     "deactivate revokes sessions",
+			isActive: false,
+			setup: func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {
+				userRepo.On("UpdateActive", mock.Anything, int64(7), false).Return(nil)
+				sessionRepo.On("RevokeAllByUserID", mock.Anything, uint64(7)).Return(nil)
+			},
+		},
+		{
+			name:     "activate keeps sessions",
+			isActive: true,
+			setup: func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {
+				userRepo.On("UpdateActive", mock.Anything, int64(7), true).Return(nil)
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			userRepo := mocks.NewRepository(t)
+			sessionRepo := sessionmocks.NewRepository(t)
+			tt.setup(userRepo, sessionRepo)
+
+			u := &usecase{
+				Usecase:     baseUsecase,
+				userRepo:    userRepo,
+				sessionRepo: sessionRepo,
+			}
+			require.NoError(t, u.UpdateActive(nil, 7, tt.isActive))
+
+		})
+	}
+}
+
+func Test_usecase_AssignRole(t *testing.T) {
+
+	baseUsecase := base.NewBaseUsecase("test_user_usecase")
+	tests := []struct {
+		name   string
+		setup  func(userRepo *mocks.Repository)
+		expect func(r *require.Assertions, err error)
+	}{
+		{
+			name: "success",
+			setup: func(userRepo *mocks.Repository) {
+				userRepo.On("GetRolesByUserIDtoUpdate", mock.Anything, int64(7)).Return([]*entity.UserRole{}, nil)
+				userRepo.On("GetRole", mock.Anything, uint64(3)).Return(&model.Role{ID: 3}, nil)
+				userRepo.On("Get", mock.Anything, uint64(7)).Return(&model.User{ID: 7}, nil)
+				userRepo.On("AssignMultipleRole", mock.Anything, []entity.UserRole{{UserId: 7, RoleId: 3}}).Return(nil)
+			},
+			expect: func(r *require.Assertions, err error) {
+				r.NoError(err)
+			},
+		},
+		{
+			name: "assign error",
+			setup: func(userRepo *mocks.Repository) {
+				userRepo.On("GetRolesByUserIDtoUpdate", mock.Anything, int64(7)).Return([]*entity.UserRole{}, nil)
+				userRepo.On("GetRole", mock.Anything, uint64(3)).Return(&model.Role{ID: 3}, nil)
+				userRepo.On("Get", mock.Anything, uint64(7)).Return(&model.User{ID: 7}, nil)
+				userRepo.On("AssignMultipleRole", mock.Anything, mock.Anything).Return(errors.New("insert failed"))
+			},
+			expect: func(r *require.Assertions, err error) {
+				r.EqualError(err, "insert failed")
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			userRepo := mocks.NewRepository(t)
+			tt.setup(userRepo)
+
+			u := &usecase{
+				Usecase:  baseUsecase,
+				userRepo: userRepo,
+			}
+			tt.expect(require.New(t), u.AssignRole(context.Background(), 7, []int64{3}))
+
+		})
+	}
+}
+
+func Test_usecase_Impersonate(t *testing.T) {
+
+	mailer.NewMailer(&mailer.Config{Kind: "memory"})
+	helper.Configure(&helper.Config{Secret: "test"})
+	baseUsecase := base.NewBaseUsecase("test_user_usecase")
+	tests := []struct {
+		name   string
+		userID uint64
+		setup  func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository)
+		expect func(r *require.Assertions, session *model.Session, accessToken string, err error)
+	}{
+		{
+			name:   "success",
+			userID: 7,
+			setup: func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {
+				userRepo.On("Get", mock.Anything, uint64(7)).Return(&model.User{ID: 7, Status: true}, nil)
+				sessionRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Session")).Return(nil)
+			},
+			expect: func(r *require.Assertions, session *model.Session, accessToken string, err error) {
+				r.NoError(err)
+				r.NotEmpty(accessToken)
+				r.EqualValues(7, session.UserID)
+				r.EqualValues(1, *session.ImpersonatorID)
+				r.EqualValues("Impersonated by admin@email.test", session.Device)
+			},
+		},
+		{
+			name:   "yourself",
+			userID: 1,
+			setup:  func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {},
+			expect: func(r *require.Assertions, session *model.Session, accessToken string, err error) {
+				r.IsType(base.APIErrors{}, err)
+			},
+		},
+		{
+			name:   "administrator",
+			userID: 2,
+			setup: func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {
+				userRepo.On("Get", mock.Anything, uint64(2)).Return(&model.User{ID: 2, Status: true, IsAdmin: true}, nil)
+			},
+			expect: func(r *require.Assertions, session *model.Session, accessToken string, err error) {
+				r.IsType(base.APIErrors{}, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			userRepo := mocks.NewRepository(t)
+			sessionRepo := sessionmocks.NewRepository(t)
+			tt.setup(userRepo, sessionRepo)
+
+			u := &usecase{
+				Usecase:     baseUsecase,
+				userRepo:    userRepo,
+				sessionRepo: sessionRepo,
+			}
+			ctx := context.Background().WithUser(&model.User{ID: 1, Email: "admin@email.test", IsAdmin: true})
+			session, accessToken, err := u.Impersonate(ctx, tt.userID, model.ClientInfo{})
+			tt.expect(require.New(t), session, accessToken, err)
+
+		})
+	}
+}
+
+func Test_usecase_IssueToken(t *testing.T) {
+
+	helper.Configure(&helper.Config{Secret: "test"})
+	baseUsecase := base.NewBaseUsecase("test_user_usecase")
+	tests := []struct {
+		name   string
+		userID uint64
+		setup  func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository)
+		expect func(r *require.Assertions, session *model.Session, accessToken string, err error)
+	}{
+		{
+			name:   "success",
+			userID: 7,
+			setup: func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {
+				userRepo.On("Get", mock.Anything, uint64(7)).Return(&model.User{ID: 7, Status: true, IsAdmin: true}, nil)
+				sessionRepo.On("Create", mock.Anything, mock.AnythingOfType("*model.Session")).Return(nil)
+			},
+			expect: func(r *require.Assertions, session *model.Session, accessToken string, err error) {
+				r.NoError(err)
+				r.NotEmpty(accessToken)
+				r.EqualValues(7, session.UserID)
+				r.Nil(session.ImpersonatorID)
+				r.WithinDuration(session.CreateAt.Add(time.Hour), session.ExpiresAt, time.Second)
+			},
+		},
+		{
+			name:   "inactive",
+			userID: 8,
+			setup: func(userRepo *mocks.Repository, sessionRepo *sessionmocks.Repository) {
+				userRepo.On("Get", mock.Anything, uint64(8)).Return(&model.User{ID: 8}, nil)
+			},
+			expect: func(r *require.Assertions, session *model.Session, accessToken string, err error) {
+				r.IsType(base.APIErrors{}, err)
+			},
+		},
+	}
+	for _, tt := range tests {
+
+		t.Run(tt.name, func(t *testing.T) {
+
+			userRepo := mocks.NewRepository(t)
+			sessionRepo := sessionmocks.NewRepository(t)
+			tt.setup(userRepo, sessionRepo)
+
+			u := &usecase{
+				Usecase:     baseUsecase,
+				userRepo:    userRepo,
+				sessionRepo: sessionRepo,
+			}
+			session, accessToken, err := u.IssueToken(context.Background(), tt.userID, time.Hour, model.ClientInfo{})
+			tt.expect(require.New(t), session, accessToken, err)
 
 		})
 	}
@@ -0,0 +1,71 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ErrNotFound nothing is stored under the key
+var ErrNotFound = errors.New("blob not found")
+
+type Config struct {
+	// Kind: local | s3 | memory, default: local
+	Kind string `config:"kind" default:"local"`
+	// Dir the root directory of the local kind, default: blobs
+	Dir string `config:"dir" default:"blobs"`
+	// Endpoint the url of the s3 kind server, default: https://s3.<region>.amazonaws.com
+	Endpoint string `config:"endpoint"`
+	// Region the region of the s3 kind, default: us-east-1
+	Region    string `config:"region"`
+	Bucket    string `config:"bucket"`
+	AccessKey string `config:"access_key"`
+	SecretKey string `config:"secret_key" secret:"true"`
+	// PathStyle address the bucket by path rather than by host, as most of the local S3 stand-ins need
+	PathStyle bool `config:"path_style"`
+}
+
+// BlobStore stores the uploaded files by key, e.g. contracts/cl0s3m1f8k9f8k9f8k9g.pdf
+type BlobStore interface {
+	// Put stream r under key, replacing the content stored under key if any
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get open the content stored under key, ErrNotFound when there is none
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete remove the content stored under key, deleting a missing key is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+var mapKindStore = map[string]func(*Config) (BlobStore, error){
+	"":       newLocalStore,
+	"local":  newLocalStore,
+	"s3":     newS3Store,
+	"memory": newMemoryStore,
+}
+
+var store BlobStore
+
+func New(config *Config) BlobStore {
+	if config == nil {
+		config = &Config{Kind: "local"}
+	}
+
+	newStore := mapKindStore[config.Kind]
+	if newStore == nil {
+		panic(fmt.Sprintf("unsupported blob store kind %q", config.Kind))
+	}
+
+	s, err := newStore(config)
+	if err != nil {
+		panic(err)
+	}
+	store = s
+	return store
+}
+
+func GetBlobStoreInstance() BlobStore {
+	if store == nil {
+		panic("blob store instance is not initialized")
+	}
+	return store
+}
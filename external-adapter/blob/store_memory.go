@@ -0,0 +1,62 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+)
+
+// MemoryStore keeps the blobs in memory, it is meant for tests.
+type MemoryStore struct {
+	mu    sync.Mutex
+	blobs map[string]*MemoryBlob
+}
+
+type MemoryBlob struct {
+	ContentType string
+	Data        []byte
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.blobs[key] = &MemoryBlob{ContentType: contentType, Data: data}
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.blobs[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(b.Data)), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.blobs, key)
+	return nil
+}
+
+// Blobs returns a copy of the stored blobs by key.
+func (s *MemoryStore) Blobs() map[string]*MemoryBlob {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	blobs := make(map[string]*MemoryBlob, len(s.blobs))
+	for k, b := range s.blobs {
+		blobs[k] = b
+	}
+	return blobs
+}
+
+func newMemoryStore(c *Config) (BlobStore, error) {
+	return &MemoryStore{blobs: map[string]*MemoryBlob{}}, nil
+}
@@ -12,16 +12,26 @@ import (
 )
 
 type Config struct {
-	Host            string        `config:"host"`
-	Port            int           `config:"port"`
+	Host            string        `config:"host" validate:"required"`
+	Port            int           `config:"port" default:"5432" validate:"min=1,max=65535"`
 	User            string        `config:"user"`
-	Password        string        `config:"pass"`
-	DBName          string        `config:"db_name"`
-	MaxIdleConn     int           `config:"max_idle_conn"`
-	MaxOpenConn     int           `config:"max_open_conn"`
-	MaxConnLifeTime time.Duration `config:"max_conn_life_time"`
-	LogmodeLevel    string        `config:"logmode_level"`
+	Password        string        `config:"pass" secret:"true"`
+	DBName          string        `config:"db_name" validate:"required"`
+	MaxIdleConn     int           `config:"max_idle_conn" default:"3" validate:"gte=0"`
+	MaxOpenConn     int           `config:"max_open_conn" default:"10" validate:"gte=0"`
+	MaxConnLifeTime time.Duration `config:"max_conn_life_time" default:"3600"`
+	LogmodeLevel    string        `config:"logmode_level" default:"silent" validate:"omitempty,oneof=silent error warn info"`
 	DisableLogColor bool          `config:"disable_log_color"`
+	// MigrateOnStart apply the pending migrations before serving, see the migrate command
+	MigrateOnStart bool `config:"migrate_on_start"`
+	// Replicas the DSNs of the read replicas, the handlers which only query read them in turn
+	Replicas []string `config:"replicas" secret:"true"`
+	// ReadYourWritesWindow seconds a user reads from the primary after a write
+	ReadYourWritesWindow time.Duration `config:"read_your_writes_window" default:"5"`
+	// MaxReplicaLag seconds a replica may be behind the primary to be read
+	MaxReplicaLag time.Duration `config:"max_replica_lag" default:"10"`
+	// ReplicaCheckInterval seconds between the checks of the lag of the replicas
+	ReplicaCheckInterval time.Duration `config:"replica_check_interval" default:"5"`
 }
 
 var mapStringLogmodeLevel = map[string]logger.LogLevel{
@@ -40,6 +50,23 @@ func GetDBInstance() *gorm.DB {
 	return db
 }
 
+// Close close the connections of the primary and of the replicas, once the requests and the workers are done
+func Close() error {
+	if db == nil {
+		return nil
+	}
+	for _, r := range replicas {
+		if sqlDB, err := r.db.DB(); err == nil {
+			sqlDB.Close()
+		}
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
 func New(c *Config) *gorm.DB {
 	if db != nil {
 		return db
@@ -62,18 +89,18 @@ func New(c *Config) *gorm.DB {
 		level = logger.Silent
 	}
 
+	gormConfig := &gorm.Config{
+		PrepareStmt:            true,
+		SkipDefaultTransaction: true,
+		Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold: 200 * time.Millisecond,
+			LogLevel:      level,
+			Colorful:      !c.DisableLogColor,
+		}),
+	}
+
 	var err error
-	db, err = gorm.Open(
-		postgres.Open(dsn),
-		&gorm.Config{
-			PrepareStmt:            true,
-			SkipDefaultTransaction: true,
-			Logger: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
-				SlowThreshold: 200 * time.Millisecond,
-				LogLevel:      level,
-				Colorful:      !c.DisableLogColor,
-			}),
-		})
+	db, err = gorm.Open(postgres.Open(dsn), gormConfig)
 	if err != nil {
 		panic(err)
 	}
@@ -102,6 +129,11 @@ func New(c *Config) *gorm.DB {
 	sqlDB.SetMaxIdleConns(c.MaxIdleConn)
 	sqlDB.SetMaxOpenConns(c.MaxOpenConn)
 	sqlDB.SetConnMaxLifetime(c.MaxConnLifeTime * time.Second)
+	if err := instrument("primary", db); err != nil {
+		panic(err)
+	}
+
+	newReplicas(c, gormConfig)
 
 	return db
 }
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"time"
+
+	"github.com/tpp/msf/shared/metrics"
+	"gorm.io/gorm"
+)
+
+// startedKey the key of the start time of a statement, set by the first of its callbacks
+const startedKey = "metrics:started"
+
+// instrument record the duration of the queries of g, the database name, and the statistics of its connection pool
+func instrument(name string, g *gorm.DB) error {
+	sqlDB, err := g.DB()
+	if err != nil {
+		return err
+	}
+	metrics.RegisterDB(name, sqlDB)
+
+	cb := g.Callback()
+	for _, p := range []struct {
+		operation     string
+		before, after func(string, func(*gorm.DB)) error
+	}{
+		{"create", cb.Create().Before("*").Register, cb.Create().After("*").Register},
+		{"query", cb.Query().Before("*").Register, cb.Query().After("*").Register},
+		{"update", cb.Update().Before("*").Register, cb.Update().After("*").Register},
+		{"delete", cb.Delete().Before("*").Register, cb.Delete().After("*").Register},
+		{"row", cb.Row().Before("*").Register, cb.Row().After("*").Register},
+		{"raw", cb.Raw().Before("*").Register, cb.Raw().After("*").Register},
+	} {
+		operation := p.operation
+		if err = p.before("metrics:start", func(tx *gorm.DB) {
+			tx.InstanceSet(startedKey, time.Now())
+		}); err != nil {
+			return err
+		}
+		if err = p.after("metrics:observe", func(tx *gorm.DB) {
+			if started, ok := tx.InstanceGet(startedKey); ok {
+				metrics.ObserveQuery(name, operation, tx.Statement.Table, time.Since(started.(time.Time)))
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/metrics"
+	"github.com/tpp/msf/shared/utils"
+)
+
+func TestInstrument(t *testing.T) {
+	r := require.New(t)
+	gDB, mock, teardown, err := utils.NewDBMock()
+	r.NoError(err)
+	defer teardown()
+	r.NoError(instrument("test", gDB))
+
+	mock.ExpectPrepare(`SELECT count(*) FROM "users"`).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+	var count int64
+	r.NoError(gDB.Table("users").Count(&count).Error)
+	r.EqualValues(2, count)
+	r.NoError(mock.ExpectationsWereMet())
+
+	w := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body, err := io.ReadAll(w.Result().Body)
+	r.NoError(err)
+	lines := strings.Split(string(body), "\n")
+	r.Contains(lines, `msf_db_query_duration_seconds_count{db="test",operation="query",table="users"} 1`)
+	r.Contains(lines, `go_sql_max_open_connections{db_name="test"} 0`)
+}
@@ -0,0 +1,419 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// historyTable the table of the applied migrations, Flyway's so the databases it migrated carry on
+const historyTable = "flyway_schema_history"
+
+// lockKey the key of the advisory lock held while migrating, the same for every instance of the service
+const lockKey int64 = 7_340_613_042_512
+
+// State of a migration
+type State string
+
+const (
+	StatePending State = "pending"
+	StateApplied State = "applied"
+	// StateBaseline below the version Flyway was baselined at, never applied
+	StateBaseline State = "baseline"
+	// StateFuture applied by a newer release, ignored
+	StateFuture State = "future"
+	// StateMissing applied but its script is gone
+	StateMissing State = "missing"
+	// StateFailed failed on a previous run, the schema must be fixed by hand and its history row deleted
+	StateFailed State = "failed"
+	// StateChanged its script was changed since it is applied
+	StateChanged State = "checksum_mismatch"
+	// StateOutOfOrder pending but older than an applied one
+	StateOutOfOrder State = "out_of_order"
+)
+
+// Status of a migration in the database
+type Status struct {
+	Version     string     `json:"version"`
+	Description string     `json:"description"`
+	Script      string     `json:"script"`
+	State       State      `json:"state"`
+	Destructive bool       `json:"destructive,omitempty"`
+	InstalledOn *time.Time `json:"installed_on,omitempty"`
+}
+
+// invalid the state prevents migrating
+func (s *Status) invalid() bool {
+	return s.State == StateMissing || s.State == StateFailed || s.State == StateChanged || s.State == StateOutOfOrder
+}
+
+// applied a row of the history table
+type applied struct {
+	rank        int
+	version     sql.NullString
+	description string
+	kind        string
+	script      string
+	checksum    sql.NullInt32
+	installedOn time.Time
+	success     bool
+}
+
+// Migrator apply the migrations to a postgres database
+type Migrator struct {
+	db         *sql.DB
+	migrations []*Migration
+	// out the progress of the migrations and the SQL of the dry-runs
+	out io.Writer
+}
+
+func New(db *sql.DB, migrations []*Migration, out io.Writer) *Migrator {
+	return &Migrator{db: db, migrations: migrations, out: out}
+}
+
+// Status the status of every migration, the local ones by version then the ones only known by the database
+func (m *Migrator) Status(ctx context.Context) ([]*Status, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	history, err := readHistory(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	return m.resolve(history), nil
+}
+
+// Version the latest applied version, empty when none is
+func (m *Migrator) Version(ctx context.Context) (string, error) {
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+	var version string
+	for _, s := range statuses {
+		if s.State == StateApplied || s.State == StateFuture {
+			version = s.Version
+		}
+	}
+	return version, nil
+}
+
+// Up apply the pending migrations in order, each in its own transaction, the dry-run prints their SQL instead.
+// The instances migrating at the same time wait for each other.
+func (m *Migrator) Up(ctx context.Context, dryRun bool) (int, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if !dryRun {
+		unlock, err := lock(ctx, conn)
+		if err != nil {
+			return 0, err
+		}
+		defer unlock()
+		if err = createHistory(ctx, conn); err != nil {
+			return 0, err
+		}
+	}
+
+	history, err := readHistory(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	pending, err := m.pending(history)
+	if err != nil {
+		return 0, err
+	}
+
+	rank := nextRank(history)
+	for i, migration := range pending {
+		if dryRun {
+			m.printScript(migration, migration.Script)
+			continue
+		}
+		start := time.Now()
+		err = inTx(ctx, conn, func(tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, migration.Script.SQL); err != nil {
+				return err
+			}
+			_, err := tx.ExecContext(ctx, `INSERT INTO `+historyTable+` (installed_rank, version, description, type, script, checksum, installed_by, execution_time, success)
+VALUES ($1, $2, $3, 'SQL', $4, $5, current_user, $6, true)`,
+				rank+i, migration.Version, migration.Description, migration.Script.Name, migration.Script.Checksum, time.Since(start).Milliseconds())
+			return err
+		})
+		if err != nil {
+			return i, fmt.Errorf("%s: %w", migration.Script.Name, err)
+		}
+		fmt.Fprintf(m.out, "applied %s (%s)\n", migration.Script.Name, time.Since(start).Round(time.Millisecond))
+	}
+	return len(pending), nil
+}
+
+// Down undo the latest applied migration with its U script and forget it, the dry-run prints the script instead
+func (m *Migrator) Down(ctx context.Context, dryRun bool) (*Migration, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if !dryRun {
+		unlock, err := lock(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	history, err := readHistory(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = m.pending(history); err != nil {
+		return nil, err
+	}
+
+	var last *applied
+	for _, h := range history {
+		if h.kind == "SQL" && h.success {
+			last = h
+		}
+	}
+	if last == nil {
+		return nil, errors.New("no migration is applied")
+	}
+	migration := m.find(last.version.String)
+	if migration == nil {
+		return nil, fmt.Errorf("version %s is applied by a newer release, it cannot be undone by this one", last.version.String)
+	}
+	if migration.Undo == nil {
+		return nil, fmt.Errorf("%s has no U%s script to undo it", migration.Script.Name, strings.TrimPrefix(migration.Script.Name, "V"))
+	}
+
+	if dryRun {
+		m.printScript(migration, migration.Undo)
+		return migration, nil
+	}
+	start := time.Now()
+	err = inTx(ctx, conn, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, migration.Undo.SQL); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, `DELETE FROM `+historyTable+` WHERE installed_rank = $1`, last.rank)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", migration.Undo.Name, err)
+	}
+	fmt.Fprintf(m.out, "undone %s (%s)\n", migration.Script.Name, time.Since(start).Round(time.Millisecond))
+	return migration, nil
+}
+
+func (m *Migrator) printScript(migration *Migration, script *Script) {
+	fmt.Fprintf(m.out, "-- %s\n", script.Name)
+	if migration.Destructive() && script == migration.Script {
+		fmt.Fprintf(m.out, "-- WARNING: %s deletes or drops data\n", script.Name)
+	}
+	fmt.Fprintf(m.out, "%s\n\n", script.SQL)
+}
+
+func (m *Migrator) find(version string) *Migration {
+	parts, err := parseVersion(version)
+	if err != nil {
+		return nil
+	}
+	for _, migration := range m.migrations {
+		if compareParts(migration.parts, parts) == 0 {
+			return migration
+		}
+	}
+	return nil
+}
+
+// pending the migrations to apply, an error when a status prevents migrating
+func (m *Migrator) pending(history []*applied) ([]*Migration, error) {
+	var pending []*Migration
+	for _, s := range m.resolve(history) {
+		if s.invalid() {
+			return nil, fmt.Errorf("%s: %s, see the migrate status", s.Script, s.State)
+		}
+		if s.State == StatePending {
+			pending = append(pending, m.find(s.Version))
+		}
+	}
+	return pending, nil
+}
+
+// resolve the status of the migrations against the history
+func (m *Migrator) resolve(history []*applied) []*Status {
+	var baseline, latestApplied []int
+	succeeded, failed := map[string]*applied{}, map[string]*applied{}
+	for _, h := range history {
+		if !h.version.Valid {
+			continue
+		}
+		parts, err := parseVersion(h.version.String)
+		if err != nil {
+			continue
+		}
+		switch {
+		case h.kind == "BASELINE":
+			baseline = parts
+		case !h.success:
+			failed[canonical(parts)] = h
+		case h.kind == "SQL":
+			succeeded[canonical(parts)] = h
+			if latestApplied == nil || compareParts(parts, latestApplied) > 0 {
+				latestApplied = parts
+			}
+		}
+	}
+
+	var statuses []*Status
+	for _, migration := range m.migrations {
+		s := &Status{
+			Version:     migration.Version,
+			Description: migration.Description,
+			Script:      migration.Script.Name,
+			State:       StatePending,
+			Destructive: migration.Destructive(),
+		}
+		key := canonical(migration.parts)
+		if h, ok := succeeded[key]; ok {
+			delete(succeeded, key)
+			s.InstalledOn = &h.installedOn
+			s.State = StateApplied
+			if h.checksum.Valid && h.checksum.Int32 != migration.Script.Checksum {
+				s.State = StateChanged
+			}
+		} else if _, ok := failed[key]; ok {
+			delete(failed, key)
+			s.State = StateFailed
+		} else if baseline != nil && compareParts(migration.parts, baseline) <= 0 {
+			s.State = StateBaseline
+		} else if latestApplied != nil && compareParts(migration.parts, latestApplied) < 0 {
+			s.State = StateOutOfOrder
+		}
+		statuses = append(statuses, s)
+	}
+
+	// the versions applied but not known here
+	var latestLocal []int
+	if len(m.migrations) != 0 {
+		latestLocal = m.migrations[len(m.migrations)-1].parts
+	}
+	for _, h := range history {
+		parts, _ := parseVersion(h.version.String)
+		key := canonical(parts)
+		s := &Status{Version: h.version.String, Description: h.description, Script: h.script, InstalledOn: &h.installedOn}
+		if succeeded[key] == h {
+			s.State = StateMissing
+			if latestLocal == nil || compareParts(parts, latestLocal) > 0 {
+				s.State = StateFuture
+			}
+		} else if failed[key] == h {
+			s.State = StateFailed
+		} else {
+			continue
+		}
+		statuses = append(statuses, s)
+	}
+	return statuses
+}
+
+// canonical the version of parts without its trailing zeros, 1.0 is 1
+func canonical(parts []int) string {
+	for len(parts) > 1 && parts[len(parts)-1] == 0 {
+		parts = parts[:len(parts)-1]
+	}
+	s := make([]string, len(parts))
+	for i, p := range parts {
+		s[i] = strconv.Itoa(p)
+	}
+	return strings.Join(s, ".")
+}
+
+func nextRank(history []*applied) int {
+	rank := 1
+	for _, h := range history {
+		if h.rank >= rank {
+			rank = h.rank + 1
+		}
+	}
+	return rank
+}
+
+// lock take the advisory lock of the migrations for the session of conn, waiting for the other instances
+func lock(ctx context.Context, conn *sql.Conn) (unlock func(), err error) {
+	if _, err = conn.ExecContext(ctx, `SELECT pg_advisory_lock($1)`, lockKey); err != nil {
+		return nil, fmt.Errorf("lock the migrations: %w", err)
+	}
+	return func() {
+		conn.ExecContext(context.Background(), `SELECT pg_advisory_unlock($1)`, lockKey)
+	}, nil
+}
+
+func createHistory(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+historyTable+` (
+    installed_rank INTEGER NOT NULL PRIMARY KEY,
+    version VARCHAR(50),
+    description VARCHAR(200) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    script VARCHAR(1000) NOT NULL,
+    checksum INTEGER,
+    installed_by VARCHAR(100) NOT NULL,
+    installed_on TIMESTAMP NOT NULL DEFAULT now(),
+    execution_time INTEGER NOT NULL,
+    success BOOLEAN NOT NULL
+)`)
+	return err
+}
+
+// readHistory the rows of the history table by rank, none before the first migration
+func readHistory(ctx context.Context, conn *sql.Conn) ([]*applied, error) {
+	var exists bool
+	if err := conn.QueryRowContext(ctx, `SELECT to_regclass($1) IS NOT NULL`, historyTable).Scan(&exists); err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	rows, err := conn.QueryContext(ctx, `SELECT installed_rank, version, description, type, script, checksum, installed_on, success FROM `+historyTable+` ORDER BY installed_rank`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []*applied
+	for rows.Next() {
+		h := &applied{}
+		if err = rows.Scan(&h.rank, &h.version, &h.description, &h.kind, &h.script, &h.checksum, &h.installedOn, &h.success); err != nil {
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
+
+func inTx(ctx context.Context, conn *sql.Conn, fn func(tx *sql.Tx) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err = fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,183 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"regexp"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+var historyColumns = []string{"installed_rank", "version", "description", "type", "script", "checksum", "installed_on", "success"}
+
+func testMigrations(t *testing.T) []*Migration {
+	migrations, err := Load(fstest.MapFS{
+		"V1__Initial.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"V1.1__Second.sql":  {Data: []byte("ALTER TABLE a ADD name TEXT;")},
+		"U1.1__Second.sql":  {Data: []byte("ALTER TABLE a DROP COLUMN name;")},
+		"V1.2__Cleanup.sql": {Data: []byte("DELETE FROM a;")},
+	})
+	require.NoError(t, err)
+	return migrations
+}
+
+func TestMigrator_resolve(t *testing.T) {
+	migrations := testMigrations(t)
+	checksum := func(i int) sql.NullInt32 { return sql.NullInt32{Int32: migrations[i].Script.Checksum, Valid: true} }
+	version := func(v string) sql.NullString { return sql.NullString{String: v, Valid: true} }
+
+	tests := []struct {
+		name    string
+		history []*applied
+		states  []State
+	}{
+		{
+			name:   "fresh",
+			states: []State{StatePending, StatePending, StatePending},
+		},
+		{
+			name: "partly applied",
+			history: []*applied{
+				{rank: 1, version: version("1"), kind: "SQL", checksum: checksum(0), success: true},
+			},
+			states: []State{StateApplied, StatePending, StatePending},
+		},
+		{
+			name: "baselined",
+			history: []*applied{
+				{rank: 1, version: version("1.1"), kind: "BASELINE", success: true},
+			},
+			states: []State{StateBaseline, StateBaseline, StatePending},
+		},
+		{
+			name: "changed and out of order",
+			history: []*applied{
+				{rank: 1, version: version("1"), kind: "SQL", checksum: sql.NullInt32{Int32: 1, Valid: true}, success: true},
+				{rank: 2, version: version("1.2"), kind: "SQL", checksum: checksum(2), success: true},
+			},
+			states: []State{StateChanged, StateOutOfOrder, StateApplied},
+		},
+		{
+			name: "failed, missing and future",
+			history: []*applied{
+				{rank: 1, version: version("1"), kind: "SQL", checksum: checksum(0), success: true},
+				{rank: 2, version: version("1.0.5"), kind: "SQL", success: true},
+				{rank: 3, version: version("1.1"), kind: "SQL", checksum: checksum(1), success: false},
+				{rank: 4, version: version("2"), kind: "SQL", success: true},
+			},
+			states: []State{StateApplied, StateFailed, StateOutOfOrder, StateMissing, StateFuture},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(nil, migrations, &bytes.Buffer{})
+			var states []State
+			for _, s := range m.resolve(tt.history) {
+				states = append(states, s.State)
+			}
+			require.EqualValues(t, tt.states, states)
+		})
+	}
+}
+
+func TestMigrator_Up(t *testing.T) {
+	migrations := testMigrations(t)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS flyway_schema_history`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WithArgs(historyTable).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT installed_rank, version`).WillReturnRows(sqlmock.NewRows(historyColumns).
+		AddRow(1, "1", "Initial", "SQL", "V1__Initial.sql", migrations[0].Script.Checksum, time.Now(), true))
+	for i, m := range migrations[1:] {
+		mock.ExpectBegin()
+		mock.ExpectExec(regexp.QuoteMeta(m.Script.SQL)).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec(`INSERT INTO flyway_schema_history`).
+			WithArgs(i+2, m.Version, m.Description, m.Script.Name, m.Script.Checksum, sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WithArgs(lockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	var out bytes.Buffer
+	n, err := New(db, migrations, &out).Up(context.Background(), false)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, n)
+	require.Contains(t, out.String(), "applied V1.2__Cleanup.sql")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_checksumMismatch(t *testing.T) {
+	migrations := testMigrations(t)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`CREATE TABLE IF NOT EXISTS flyway_schema_history`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT installed_rank, version`).WillReturnRows(sqlmock.NewRows(historyColumns).
+		AddRow(1, "1", "Initial", "SQL", "V1__Initial.sql", 42, time.Now(), true))
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = New(db, migrations, &bytes.Buffer{}).Up(context.Background(), false)
+	require.EqualError(t, err, "V1__Initial.sql: checksum_mismatch, see the migrate status")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Up_dryRun(t *testing.T) {
+	migrations := testMigrations(t)
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+
+	var out bytes.Buffer
+	n, err := New(db, migrations, &out).Up(context.Background(), true)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, n)
+	require.Contains(t, out.String(), "-- V1.1__Second.sql\nALTER TABLE a ADD name TEXT;")
+	require.Contains(t, out.String(), "-- WARNING: V1.2__Cleanup.sql deletes or drops data")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrator_Down(t *testing.T) {
+	migrations := testMigrations(t)[:2]
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	history := func() *sqlmock.Rows {
+		return sqlmock.NewRows(historyColumns).
+			AddRow(1, "1", "Initial", "SQL", "V1__Initial.sql", migrations[0].Script.Checksum, time.Now(), true).
+			AddRow(2, "1.1", "Second", "SQL", "V1.1__Second.sql", migrations[1].Script.Checksum, time.Now(), true)
+	}
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_lock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT installed_rank, version`).WillReturnRows(history())
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta(`ALTER TABLE a DROP COLUMN name;`)).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(regexp.QuoteMeta(`DELETE FROM flyway_schema_history WHERE installed_rank = $1`)).WithArgs(2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta(`SELECT pg_advisory_unlock($1)`)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	undone, err := New(db, migrations, &bytes.Buffer{}).Down(context.Background(), false)
+	require.NoError(t, err)
+	require.EqualValues(t, "1.1", undone.Version)
+
+	// V1 has no undo script
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT to_regclass($1) IS NOT NULL`)).WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery(`SELECT installed_rank, version`).WillReturnRows(sqlmock.NewRows(historyColumns).
+		AddRow(1, "1", "Initial", "SQL", "V1__Initial.sql", migrations[0].Script.Checksum, time.Now(), true))
+	_, err = New(db, migrations, &bytes.Buffer{}).Down(context.Background(), true)
+	require.EqualError(t, err, "V1__Initial.sql has no U1__Initial.sql script to undo it")
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"bytes"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scriptPattern the names of the scripts, Flyway's: V<version>__<description>.sql migrates, U<version>__<description>.sql undoes
+var scriptPattern = regexp.MustCompile(`^([VU])([0-9][0-9._]*)__(.+)\.sql$`)
+
+// destructivePattern the statements losing data, the migrations having one are flagged in the status and the dry-run
+var destructivePattern = regexp.MustCompile(`(?i)\b(DELETE\s+FROM|DROP\s+(TABLE|COLUMN)|TRUNCATE)\b`)
+
+// Script a sql file
+type Script struct {
+	Name     string
+	SQL      string
+	Checksum int32
+}
+
+// Migration a version of the schema, the script undoing it is optional
+type Migration struct {
+	Version     string
+	Description string
+	Script      *Script
+	Undo        *Script
+	parts       []int
+}
+
+// Destructive the migration deletes or drops data
+func (m *Migration) Destructive() bool {
+	return destructivePattern.MatchString(m.Script.SQL)
+}
+
+// Load the migrations of the sql files at the root of fsys, ordered by version
+func Load(fsys fs.FS) ([]*Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[string]*Migration{}
+	var undos []*Script
+	for _, name := range names {
+		match := scriptPattern.FindStringSubmatch(name)
+		if match == nil {
+			return nil, fmt.Errorf("%s: not a V<version>__<description>.sql or U<version>__<description>.sql script", name)
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		script := &Script{Name: name, SQL: string(content), Checksum: Checksum(content)}
+		if match[1] == "U" {
+			undos = append(undos, script)
+			continue
+		}
+
+		version := strings.ReplaceAll(match[2], "_", ".")
+		parts, err := parseVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if other, ok := byVersion[canonical(parts)]; ok {
+			return nil, fmt.Errorf("%s: version %s is already migrated by %s", name, version, other.Script.Name)
+		}
+		byVersion[canonical(parts)] = &Migration{
+			Version:     version,
+			Description: strings.ReplaceAll(match[3], "_", " "),
+			Script:      script,
+			parts:       parts,
+		}
+	}
+
+	for _, undo := range undos {
+		version := strings.ReplaceAll(scriptPattern.FindStringSubmatch(undo.Name)[2], "_", ".")
+		parts, err := parseVersion(version)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", undo.Name, err)
+		}
+		m, ok := byVersion[canonical(parts)]
+		if !ok {
+			return nil, fmt.Errorf("%s: no migration of version %s to undo", undo.Name, version)
+		}
+		m.Undo = undo
+	}
+
+	migrations := make([]*Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return compareParts(migrations[i].parts, migrations[j].parts) < 0 })
+	return migrations, nil
+}
+
+// Checksum the checksum of a script as Flyway computes it, so the scripts applied by Flyway are verified: the CRC32
+// of its lines without their terminators, as a signed integer. The CRC of consecutive lines being the one of their
+// concatenation, the line terminators are just skipped.
+func Checksum(content []byte) int32 {
+	content = bytes.TrimPrefix(content, []byte("\ufeff"))
+	crc := crc32.NewIEEE()
+	for len(content) > 0 {
+		i := bytes.IndexAny(content, "\r\n")
+		if i < 0 {
+			i = len(content)
+		}
+		crc.Write(content[:i])
+		content = bytes.TrimLeft(content[i:], "\r\n")
+	}
+	return int32(crc.Sum32())
+}
+
+func parseVersion(version string) ([]int, error) {
+	var parts []int
+	for _, s := range strings.Split(version, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version %q", version)
+		}
+		parts = append(parts, n)
+	}
+	return parts, nil
+}
+
+// compareParts compare versions numerically, 1.2 < 1.10 and 1 = 1.0
+func compareParts(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
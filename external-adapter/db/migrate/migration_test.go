@@ -0,0 +1,65 @@
+package migrate
+
+import (
+	"hash/crc32"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/sql"
+)
+
+func TestChecksum(t *testing.T) {
+	abc := int32(crc32.ChecksumIEEE([]byte("abc")))
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"lf", "a\nb\nc\n"},
+		{"crlf", "a\r\nb\r\nc"},
+		{"cr", "a\rb\rc\r"},
+		{"blank lines", "a\n\n\r\nb\nc"},
+		{"bom", "\ufeffa\nbc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.EqualValues(t, abc, Checksum([]byte(tt.content)))
+		})
+	}
+	require.NotEqual(t, abc, Checksum([]byte("a b c")))
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"V1.10__Tenth.sql":         {Data: []byte("SELECT 10;")},
+		"V1__Initial.sql":          {Data: []byte("CREATE TABLE a (id INT);")},
+		"V1.2__Drop_Old_Table.sql": {Data: []byte("DROP TABLE old;")},
+		"U1.10__Tenth.sql":         {Data: []byte("SELECT -10;")},
+	})
+	require.NoError(t, err)
+	require.Len(t, migrations, 3)
+
+	require.EqualValues(t, "1", migrations[0].Version)
+	require.EqualValues(t, "1.2", migrations[1].Version)
+	require.EqualValues(t, "Drop Old Table", migrations[1].Description)
+	require.True(t, migrations[1].Destructive())
+	require.EqualValues(t, "1.10", migrations[2].Version)
+	require.EqualValues(t, "U1.10__Tenth.sql", migrations[2].Undo.Name)
+	require.Nil(t, migrations[0].Undo)
+
+	_, err = Load(fstest.MapFS{"U2__Orphan.sql": {Data: []byte("SELECT 1;")}})
+	require.Error(t, err)
+	_, err = Load(fstest.MapFS{"V1__A.sql": {}, "V1.0__B.sql": {}})
+	require.Error(t, err)
+	_, err = Load(fstest.MapFS{"init.sql": {}})
+	require.Error(t, err)
+}
+
+func TestLoad_Embedded(t *testing.T) {
+	migrations, err := Load(sql.Migrations)
+	require.NoError(t, err)
+	require.EqualValues(t, "1", migrations[0].Version)
+	for i := 1; i < len(migrations); i++ {
+		require.Negative(t, compareParts(migrations[i-1].parts, migrations[i].parts))
+	}
+}
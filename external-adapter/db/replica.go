@@ -0,0 +1,146 @@
+package db
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/worker"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// replicaLagQuery the seconds the replica is behind the primary, 0 when it replayed all it received so an idle
+// primary does not look like a lag
+const replicaLagQuery = `SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END`
+
+// replica a read replica, read while it answers and does not lag behind the primary
+type replica struct {
+	name    string
+	db      *gorm.DB
+	healthy int32
+}
+
+var (
+	replicas    []*replica
+	nextReplica uint32
+	// readYourWritesWindow the time a user reads from the primary after a write
+	readYourWritesWindow time.Duration
+	// lastWrites the time of the last write by user id
+	lastWrites sync.Map
+)
+
+// GetReadDBInstance a healthy replica in turn, the primary when there is none or when userID wrote within
+// the read-your-writes window, 0 is an anonymous user
+func GetReadDBInstance(userID uint64) *gorm.DB {
+	if len(replicas) == 0 || wroteRecently(userID) {
+		return GetDBInstance()
+	}
+	for range replicas {
+		r := replicas[int(atomic.AddUint32(&nextReplica, 1)-1)%len(replicas)]
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			return r.db
+		}
+	}
+	return GetDBInstance()
+}
+
+// MarkWrite start the read-your-writes window of userID, once its changes are committed
+func MarkWrite(userID uint64) {
+	if userID != 0 && len(replicas) != 0 {
+		lastWrites.Store(userID, time.Now())
+	}
+}
+
+func wroteRecently(userID uint64) bool {
+	if userID == 0 {
+		return false
+	}
+	at, ok := lastWrites.Load(userID)
+	return ok && time.Since(at.(time.Time)) < readYourWritesWindow
+}
+
+// newReplicas open the replicas of c, an unreachable replica is not fatal, it is read once it recovers
+func newReplicas(c *Config, gormConfig *gorm.Config) {
+	if c.ReadYourWritesWindow == 0 {
+		c.ReadYourWritesWindow = 5
+	}
+	if c.MaxReplicaLag == 0 {
+		c.MaxReplicaLag = 10
+	}
+	if c.ReplicaCheckInterval == 0 {
+		c.ReplicaCheckInterval = 5
+	}
+	readYourWritesWindow = c.ReadYourWritesWindow * time.Second
+
+	replicaConfig := *gormConfig
+	replicaConfig.DisableAutomaticPing = true
+	for i, dsn := range c.Replicas {
+		rdb, err := gorm.Open(postgres.Open(dsn), &replicaConfig)
+		if err != nil {
+			panic(err)
+		}
+		sqlDB, err := rdb.DB()
+		if err != nil {
+			panic(err)
+		}
+		sqlDB.SetMaxIdleConns(c.MaxIdleConn)
+		sqlDB.SetMaxOpenConns(c.MaxOpenConn)
+		sqlDB.SetConnMaxLifetime(c.MaxConnLifeTime * time.Second)
+		if err := instrument(replicaName(i), rdb); err != nil {
+			panic(err)
+		}
+
+		replicas = append(replicas, &replica{name: replicaName(i), db: rdb})
+	}
+	if len(replicas) == 0 {
+		return
+	}
+
+	maxLag := c.MaxReplicaLag * time.Second
+	checkReplicas(maxLag)
+	worker.Every("replica-check", c.ReplicaCheckInterval*time.Second, func(ctx context.Context) {
+		checkReplicas(maxLag)
+	})
+}
+
+func checkReplicas(maxLag time.Duration) {
+	for _, r := range replicas {
+		r.check(maxLag)
+	}
+	lastWrites.Range(func(userID, at any) bool {
+		if time.Since(at.(time.Time)) >= readYourWritesWindow {
+			lastWrites.Delete(userID)
+		}
+		return true
+	})
+}
+
+// check mark r healthy when it answers with a lag below maxLag, the changes of health are logged
+func (r *replica) check(maxLag time.Duration) {
+	var lag float64
+	err := r.db.Raw(replicaLagQuery).Scan(&lag).Error
+	lagging := time.Duration(lag * float64(time.Second))
+
+	var healthy int32
+	if err == nil && lagging <= maxLag {
+		healthy = 1
+	}
+	if atomic.SwapInt32(&r.healthy, healthy) == healthy {
+		return
+	}
+	if healthy == 1 {
+		log.Logger.Info().Str("replica", r.name).Dur("lag", lagging).Msg("ReplicaRecovered")
+	} else {
+		log.Logger.Warn().Err(err).Str("replica", r.name).Dur("lag", lagging).Msg("ReplicaUnavailable")
+	}
+}
+
+// replicaName the replica in the logs, not its dsn which holds the password
+func replicaName(i int) string {
+	return "replica-" + strconv.Itoa(i)
+}
@@ -0,0 +1,94 @@
+package db
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/utils"
+	"gorm.io/gorm"
+)
+
+func setupReplicas(healthy ...int32) (primary *gorm.DB, teardown func()) {
+	prevDB, prevReplicas, prevWindow := db, replicas, readYourWritesWindow
+	db, replicas, readYourWritesWindow = &gorm.DB{}, nil, time.Minute
+	for i, h := range healthy {
+		replicas = append(replicas, &replica{name: replicaName(i), db: &gorm.DB{}, healthy: h})
+	}
+	return db, func() {
+		db, replicas, readYourWritesWindow = prevDB, prevReplicas, prevWindow
+		lastWrites.Range(func(userID, _ any) bool {
+			lastWrites.Delete(userID)
+			return true
+		})
+	}
+}
+
+func TestGetReadDBInstance(t *testing.T) {
+	t.Run("without replica", func(t *testing.T) {
+		primary, teardown := setupReplicas()
+		defer teardown()
+
+		MarkWrite(1)
+		require.Same(t, primary, GetReadDBInstance(0))
+	})
+
+	t.Run("round robin over the healthy replicas", func(t *testing.T) {
+		_, teardown := setupReplicas(1, 0, 1)
+		defer teardown()
+
+		seen := map[*gorm.DB]int{}
+		for i := 0; i < 6; i++ {
+			seen[GetReadDBInstance(7)]++
+		}
+		require.Len(t, seen, 2)
+		require.EqualValues(t, 3, seen[replicas[0].db])
+		require.EqualValues(t, 3, seen[replicas[2].db])
+	})
+
+	t.Run("read your writes", func(t *testing.T) {
+		primary, teardown := setupReplicas(1)
+		defer teardown()
+
+		MarkWrite(7)
+		require.Same(t, primary, GetReadDBInstance(7))
+		require.Same(t, replicas[0].db, GetReadDBInstance(8))
+
+		readYourWritesWindow = 0
+		require.Same(t, replicas[0].db, GetReadDBInstance(7))
+	})
+
+	t.Run("no healthy replica", func(t *testing.T) {
+		primary, teardown := setupReplicas(0, 0)
+		defer teardown()
+
+		require.Same(t, primary, GetReadDBInstance(7))
+	})
+}
+
+func TestReplica_check(t *testing.T) {
+	gDB, mock, cnl, err := utils.NewDBMock()
+	require.NoError(t, err)
+	defer cnl()
+
+	r := &replica{name: replicaName(0), db: gDB}
+	mock.ExpectPrepare(replicaLagQuery).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(2.5))
+	r.check(10 * time.Second)
+	require.EqualValues(t, 1, r.healthy)
+
+	mock.ExpectQuery(replicaLagQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(30))
+	r.check(10 * time.Second)
+	require.EqualValues(t, 0, r.healthy)
+
+	mock.ExpectQuery(replicaLagQuery).WillReturnRows(sqlmock.NewRows([]string{"lag"}).AddRow(0))
+	r.check(10 * time.Second)
+	require.EqualValues(t, 1, r.healthy)
+
+	mock.ExpectQuery(replicaLagQuery).WillReturnError(errors.New("connection refused"))
+	r.check(10 * time.Second)
+	require.EqualValues(t, 0, r.healthy)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,44 +1,132 @@
 package mailer
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-mail/mail/v2"
+	"github.com/tpp/msf/shared/mailtemplate"
 )
 
 type Config struct {
-	Timeout    time.Duration `config:"timeout"`
-	Host       string        `config:"host"`
-	Port       int           `config:"port"`
-	Username   string        `config:"username"`
-	Password   string        `config:"password"`
-	Sender     string        `config:"sender"`
-	ResetPath  string        `config:"reset_path"`
-	ActivePath string        `config:"active_path"`
+	// Kind: smtp | file | maildir | memory | log, default: smtp
+	Kind              string        `config:"kind" default:"smtp"`
+	Timeout           time.Duration `config:"timeout" default:"10"`
+	Host              string        `config:"host"`
+	Port              int           `config:"port" validate:"gte=0,max=65535"`
+	Username          string        `config:"username"`
+	Password          string        `config:"password" secret:"true"`
+	TLS               string        `config:"tls"`
+	Dir               string        `config:"dir"`
+	Sender            string        `config:"sender"`
+	ResetPath         string        `config:"reset_path"`
+	ActivePath        string        `config:"active_path"`
+	RevokeSessionPath string        `config:"revoke_session_path"`
+	TemplateDir       string        `config:"template_dir"`
+	DefaultLocale     string        `config:"default_locale" default:"en"`
 }
+
+// Transport delivers a composed message.
+type Transport interface {
+	Send(msg *mail.Message) error
+}
+
+// pinger a transport checking it can deliver the messages, for the health checks
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
 type Mailer struct {
-	Dialer *mail.Dialer
-	Config *Config
-	Sender string
+	Transport Transport
+	Config    *Config
+	Sender    string
+	// Templates the templates of the messages, the embedded ones overridden by the ones of Config.TemplateDir
+	Templates *mailtemplate.Registry
+}
+
+var mapKindTransport = map[string]func(*Config) (Transport, error){
+	"":        newSMTPTransport,
+	"smtp":    newSMTPTransport,
+	"file":    newFileTransport,
+	"maildir": newMaildirTransport,
+	"memory":  newMemoryTransport,
+	"log":     newLogTransport,
 }
 
-var mailer *Mailer
+// mailer the *Mailer of GetMailInstance, replaced by SetMailInstance when the configuration is reloaded
+var mailer atomic.Value
 
+// Send delivers msg through the configured transport.
+func (m *Mailer) Send(msg *mail.Message) error {
+	return m.Transport.Send(msg)
+}
+
+// Ping check the transport can deliver the messages, e.g. the SMTP server is reachable, without sending any
+func (m *Mailer) Ping(ctx context.Context) error {
+	if p, ok := m.Transport.(pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+// NewMailer build the mailer of config and set it as the instance, it panics when config is invalid
 func NewMailer(config *Config) *Mailer {
-	dialer := mail.NewDialer(config.Host, config.Port, config.Username, config.Password)
-	dialer.Timeout = config.Timeout
+	m, err := New(config)
+	if err != nil {
+		panic(err)
+	}
+	SetMailInstance(m)
+	return m
+}
+
+// New build the mailer of config, without setting it as the instance
+func New(config *Config) (*Mailer, error) {
+	if config == nil {
+		config = &Config{Kind: "log"}
+	}
+
+	newTransport := mapKindTransport[config.Kind]
+	if newTransport == nil {
+		return nil, fmt.Errorf("unsupported mailer kind %q", config.Kind)
+	}
+
+	transport, err := newTransport(config)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Mailer{
+		Transport: transport,
+		Sender:    config.Sender,
+		Config:    config,
+		Templates: mailtemplate.New(config.TemplateDir, config.DefaultLocale),
+	}, nil
+}
 
-	mailer = &Mailer{
-		Dialer: dialer,
-		Sender: config.Sender,
-		Config: config,
+// Close close the transport of the instance when it holds resources, once the messages are sent
+func Close() error {
+	m, _ := mailer.Load().(*Mailer)
+	if m == nil {
+		return nil
 	}
-	return mailer
+	if closer, ok := m.Transport.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
 
+// SetMailInstance replace the instance, the messages being sent carry on with the previous one
+func SetMailInstance(m *Mailer) {
+	mailer.Store(m)
 }
+
 func GetMailInstance() *Mailer {
-	if mailer == nil {
+	m, _ := mailer.Load().(*Mailer)
+	if m == nil {
 		panic("mailer instance is not initialized")
 	}
-	return mailer
+	return m
 }
@@ -0,0 +1,116 @@
+package mailer
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-mail/mail/v2"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMessage() *mail.Message {
+	msg := mail.NewMessage()
+	msg.SetHeader("From", "sender@email.test")
+	msg.SetHeader("To", "receiver@email.test")
+	msg.SetHeader("Subject", "test subject")
+	msg.SetBody("text/plain", "test body")
+	return msg
+}
+
+func TestNewMailer(t *testing.T) {
+	tests := []struct {
+		name   string
+		config func(dir string) *Config
+		expect func(r *require.Assertions, m *Mailer, dir string)
+	}{
+		{
+			name:   "memory captures messages",
+			config: func(string) *Config { return &Config{Kind: "memory"} },
+			expect: func(r *require.Assertions, m *Mailer, _ string) {
+				transport, ok := m.Transport.(*MemoryTransport)
+				r.True(ok)
+				r.Len(transport.Messages(), 1)
+				r.EqualValues([]string{"receiver@email.test"}, transport.Messages()[0].GetHeader("To"))
+				transport.Reset()
+				r.Empty(transport.Messages())
+			},
+		},
+		{
+			name:   "file writes eml",
+			config: func(dir string) *Config { return &Config{Kind: "file", Dir: dir} },
+			expect: func(r *require.Assertions, _ *Mailer, dir string) {
+				files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+				r.NoError(err)
+				r.Len(files, 1)
+				bs, err := os.ReadFile(files[0])
+				r.NoError(err)
+				r.True(strings.Contains(string(bs), "Subject: test subject"))
+			},
+		},
+		{
+			name:   "maildir delivers into new",
+			config: func(dir string) *Config { return &Config{Kind: "maildir", Dir: dir} },
+			expect: func(r *require.Assertions, _ *Mailer, dir string) {
+				tmp, err := os.ReadDir(filepath.Join(dir, "tmp"))
+				r.NoError(err)
+				r.Empty(tmp)
+				delivered, err := os.ReadDir(filepath.Join(dir, "new"))
+				r.NoError(err)
+				r.Len(delivered, 1)
+			},
+		},
+		{
+			name:   "log drops messages",
+			config: func(string) *Config { return &Config{Kind: "log"} },
+			expect: func(r *require.Assertions, m *Mailer, _ string) {
+				r.IsType(&logTransport{}, m.Transport)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			dir := t.TempDir()
+
+			m := NewMailer(tt.config(dir))
+			r.NoError(m.Send(newTestMessage()))
+
+			tt.expect(r, m, dir)
+		})
+	}
+}
+
+func TestNewMailer_InvalidConfig(t *testing.T) {
+	r := require.New(t)
+	r.Panics(func() { NewMailer(&Config{Kind: "pigeon"}) })
+	r.Panics(func() { NewMailer(&Config{Kind: "smtp", TLS: "maybe"}) })
+}
+
+func TestMailer_Ping(t *testing.T) {
+	r := require.New(t)
+	ctx := context.Background()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	r.NoError(err)
+	addr := listener.Addr().(*net.TCPAddr)
+	m, err := New(&Config{Kind: "smtp", Host: "127.0.0.1", Port: addr.Port})
+	r.NoError(err)
+	r.NoError(m.Ping(ctx))
+	r.NoError(listener.Close())
+	r.Error(m.Ping(ctx))
+
+	dir := t.TempDir()
+	m, err = New(&Config{Kind: "maildir", Dir: dir})
+	r.NoError(err)
+	r.NoError(m.Ping(ctx))
+	r.NoError(os.RemoveAll(dir))
+	r.Error(m.Ping(ctx))
+
+	m, err = New(&Config{Kind: "memory"})
+	r.NoError(err)
+	r.NoError(m.Ping(ctx))
+}
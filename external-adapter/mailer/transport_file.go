@@ -0,0 +1,103 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+	"github.com/rs/xid"
+)
+
+// fileTransport writes every message as an .eml file into a directory.
+type fileTransport struct {
+	dir string
+}
+
+func (t *fileTransport) Send(msg *mail.Message) error {
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), xid.New().String())
+	return writeMessage(filepath.Join(t.dir, name), msg)
+}
+
+// Ping check the directory still exists
+func (t *fileTransport) Ping(ctx context.Context) error {
+	return checkDir(t.dir)
+}
+
+func newFileTransport(c *Config) (Transport, error) {
+	dir := c.Dir
+	if dir == "" {
+		dir = "mails"
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &fileTransport{dir: dir}, nil
+}
+
+// maildirTransport delivers messages into a maildir (tmp, new, cur), so that
+// any maildir aware mail client can be pointed to the directory.
+type maildirTransport struct {
+	dir      string
+	hostname string
+}
+
+func (t *maildirTransport) Send(msg *mail.Message) error {
+	name := fmt.Sprintf("%d.%s.%s", time.Now().Unix(), xid.New().String(), t.hostname)
+	tmp := filepath.Join(t.dir, "tmp", name)
+	if err := writeMessage(tmp, msg); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(t.dir, "new", name))
+}
+
+// Ping check the directory of the new messages still exists
+func (t *maildirTransport) Ping(ctx context.Context) error {
+	return checkDir(filepath.Join(t.dir, "new"))
+}
+
+func newMaildirTransport(c *Config) (Transport, error) {
+	dir := c.Dir
+	if dir == "" {
+		dir = "maildir"
+	}
+	for _, sub := range []string{"tmp", "new", "cur"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "localhost"
+	}
+	return &maildirTransport{dir: dir, hostname: hostname}, nil
+}
+
+func writeMessage(path string, msg *mail.Message) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = msg.WriteTo(f); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+// checkDir check dir is an existing directory
+func checkDir(dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	return nil
+}
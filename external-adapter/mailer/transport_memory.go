@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"sync"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// MemoryTransport keeps sent messages in memory, it is meant for tests.
+type MemoryTransport struct {
+	mu       sync.Mutex
+	messages []*mail.Message
+}
+
+func (t *MemoryTransport) Send(msg *mail.Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = append(t.messages, msg)
+	return nil
+}
+
+// Messages returns a copy of the captured messages in sending order.
+func (t *MemoryTransport) Messages() []*mail.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]*mail.Message(nil), t.messages...)
+}
+
+// Reset drops all captured messages.
+func (t *MemoryTransport) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = nil
+}
+
+func newMemoryTransport(c *Config) (Transport, error) {
+	return &MemoryTransport{}, nil
+}
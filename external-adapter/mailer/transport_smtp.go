@@ -0,0 +1,62 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/go-mail/mail/v2"
+)
+
+// mapStringTLSMode maps mailer.tls to the dialer settings, default: opportunistic STARTTLS
+var mapStringTLSMode = map[string]func(d *mail.Dialer){
+	"": func(d *mail.Dialer) {
+		d.StartTLSPolicy = mail.OpportunisticStartTLS
+	},
+	"none": func(d *mail.Dialer) {
+		d.SSL = false
+		d.StartTLSPolicy = mail.NoStartTLS
+	},
+	"starttls": func(d *mail.Dialer) {
+		d.SSL = false
+		d.StartTLSPolicy = mail.MandatoryStartTLS
+	},
+	"implicit": func(d *mail.Dialer) {
+		d.SSL = true
+	},
+}
+
+type smtpTransport struct {
+	dialer *mail.Dialer
+}
+
+func (t *smtpTransport) Send(msg *mail.Message) error {
+	return t.dialer.DialAndSend(msg)
+}
+
+// Ping connect to the server, without the SMTP handshake
+func (t *smtpTransport) Ping(ctx context.Context) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", net.JoinHostPort(t.dialer.Host, strconv.Itoa(t.dialer.Port)))
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+func newSMTPTransport(c *Config) (Transport, error) {
+	applyTLSMode := mapStringTLSMode[c.TLS]
+	if applyTLSMode == nil {
+		return nil, fmt.Errorf("unsupported mailer tls mode %q", c.TLS)
+	}
+
+	dialer := mail.NewDialer(c.Host, c.Port, c.Username, c.Password)
+	if c.Timeout > 0 {
+		dialer.Timeout = c.Timeout * time.Second
+	}
+	applyTLSMode(dialer)
+
+	return &smtpTransport{dialer: dialer}, nil
+}
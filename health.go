@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/external-adapter/db/migrate"
+	mailer "github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/health"
+)
+
+// registerHealthChecks register the checks of the dependencies reported by /readyz and /admin/health, once they are
+// initialized
+func registerHealthChecks() {
+	health.Register("db", func(ctx context.Context) (string, error) {
+		sqlDB, err := db.GetDBInstance().DB()
+		if err != nil {
+			return "", err
+		}
+		return "", sqlDB.PingContext(ctx)
+	})
+
+	migrator, err := newMigrator(io.Discard)
+	health.Register("migrations", func(ctx context.Context) (string, error) {
+		if err != nil {
+			return "", err
+		}
+		return checkMigrations(ctx, migrator)
+	})
+
+	health.Register("mailer", func(ctx context.Context) (string, error) {
+		m := mailer.GetMailInstance()
+		return m.Config.Kind, m.Ping(ctx)
+	})
+}
+
+// checkMigrations the applied version of the schema, the schema is down when a migration is not applied or is invalid
+func checkMigrations(ctx context.Context, migrator *migrate.Migrator) (string, error) {
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return "", err
+	}
+	var version string
+	for _, s := range statuses {
+		switch s.State {
+		case migrate.StateApplied, migrate.StateFuture:
+			version = s.Version
+		case migrate.StateBaseline:
+		default:
+			return version, fmt.Errorf("migration %s is %s", s.Version, s.State)
+		}
+	}
+	return version, nil
+}
@@ -1,34 +1,75 @@
 package main
 
 import (
+	"fmt"
+	"os"
+
 	"github.com/tpp/msf/application"
 	"github.com/tpp/msf/config"
+	"github.com/tpp/msf/external-adapter/blob"
 	"github.com/tpp/msf/external-adapter/db"
 	mailer "github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/shared/auth"
 	"github.com/tpp/msf/shared/log"
 )
 
+// Config the configuration of the service, see config.Load for the tags of the fields
 type Config struct {
-	Service    *application.Config `config:"service"`
-	Logger     *log.Config         `config:"logger"`
-	DB         *db.Config          `config:"db"`
-	AuthServer any                 `config:"auth_server"`
-	Mailer     *mailer.Config      `config:"mailer"`
+	Service *application.Config `config:"service" validate:"required"`
+	Logger  *log.Config         `config:"logger"`
+	DB      *db.Config          `config:"db" validate:"required"`
+	Auth    *auth.Config        `config:"auth" validate:"required"`
+	Mailer  *mailer.Config      `config:"mailer"`
+	Blob    *blob.Config        `config:"blob"`
 }
 
 func main() {
-	var cfg *Config
-	config.Load(&cfg)
+	cfg := &Config{}
+	config.Load(cfg)
 
 	// init logger
 	log.New(cfg.Logger)
+	auth.Configure(cfg.Auth)
+
+	os.Exit(run(cfg, os.Args[1:]))
+}
+
+// serve run the http server, the default command
+func serve(cfg *Config) int {
+	if problems := config.Validate(cfg); len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintf(stderr, "error: %s\n", problem)
+		}
+		return exitFailure
+	}
 
 	// init db
 	db.New(cfg.DB)
 
+	if cfg.DB.MigrateOnStart {
+		migrateOnStart()
+	}
+
 	//init mailer server
 	mailer.NewMailer(cfg.Mailer)
+	// init blob store of the uploaded files
+	blob.New(cfg.Blob)
+	// apply the reloaded configuration to the components
+	watchReload()
+	registerHealthChecks()
 	//err := sender.Sendmail("email_template.html", "anhtramvu97@gmail.com", "anhtramvu97@gmail.com", nil)
 
-	application.Run(cfg.Service)
+	err := application.Run(cfg.Service)
+	// the requests and the workers are done, the connections are closed
+	if closeErr := mailer.Close(); closeErr != nil {
+		log.Error().Err(closeErr).Msg("CloseMailerError")
+	}
+	if closeErr := db.Close(); closeErr != nil {
+		log.Error().Err(closeErr).Msg("CloseDBError")
+	}
+	if err != nil {
+		log.Error().Err(err).Msg("ServeError")
+		return exitFailure
+	}
+	return exitOK
 }
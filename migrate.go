@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/external-adapter/db/migrate"
+	"github.com/tpp/msf/sql"
+)
+
+const migrateUsage = `usage: main migrate <command> [--dry-run] [--json]
+
+commands:
+  up       apply the pending migrations
+  down     undo the latest applied migration with its U script
+  status   list the migrations and their state
+`
+
+// runMigrate run the migrate command of args, returns the exit code
+func runMigrate(cfg *Config, args []string) int {
+	fs, p := newFlagSet("migrate", "<up|down|status>")
+	dryRun := fs.Bool("dry-run", false, "print the SQL of the migrations instead of applying them")
+	fs.Usage = func() { fmt.Fprint(stderr, migrateUsage) }
+	if len(args) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+	if code, ok := parseFlags(fs, args[1:]); !ok {
+		return code
+	}
+
+	if err := setup(func() { db.New(cfg.DB) }); err != nil {
+		return p.fail(err)
+	}
+	// the progress and the SQL of the dry-runs must not mix with the JSON
+	progress := p.out
+	if p.json {
+		progress = p.errOut
+	}
+	migrator, err := newMigrator(progress)
+	if err != nil {
+		return p.fail(err)
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		var n int
+		if n, err = migrator.Up(ctx, *dryRun); err == nil {
+			if *dryRun && !p.json {
+				return exitOK
+			}
+			return p.result(map[string]any{"applied": n, "dry_run": *dryRun}, "%d migrations applied", n)
+		}
+	case "down":
+		var undone *migrate.Migration
+		if undone, err = migrator.Down(ctx, *dryRun); err == nil {
+			if p.json {
+				return p.result(map[string]any{"undone": undone.Version, "dry_run": *dryRun}, "")
+			}
+			return exitOK
+		}
+	case "status":
+		var statuses []*migrate.Status
+		if statuses, err = migrator.Status(ctx); err == nil {
+			if p.json {
+				return p.result(statuses, "")
+			}
+			printStatuses(statuses)
+			return exitOK
+		}
+	default:
+		fs.Usage()
+		return exitUsage
+	}
+	return p.fail(err)
+}
+
+// migrateOnStart apply the pending migrations before serving
+func migrateOnStart() {
+	migrator, err := newMigrator(os.Stdout)
+	if err == nil {
+		_, err = migrator.Up(context.Background(), false)
+	}
+	if err != nil {
+		panic(err)
+	}
+}
+
+func newMigrator(out io.Writer) (*migrate.Migrator, error) {
+	migrations, err := migrate.Load(sql.Migrations)
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.GetDBInstance().DB()
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(sqlDB, migrations, out), nil
+}
+
+func printStatuses(statuses []*migrate.Status) {
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDESCRIPTION\tSTATE\tINSTALLED ON\t")
+	for _, s := range statuses {
+		installedOn := ""
+		if s.InstalledOn != nil {
+			installedOn = s.InstalledOn.Format("2006-01-02 15:04:05")
+		}
+		state := string(s.State)
+		if s.Destructive && s.State == migrate.StatePending {
+			state += " (destructive)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", s.Version, s.Description, state, installedOn)
+	}
+	w.Flush()
+}
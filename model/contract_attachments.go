@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// ContractAttachment model, a document attached to a contract, its content is kept in the blob store
+type ContractAttachment struct {
+	ID          uint64    `json:"id"`
+	ContractID  uint64    `json:"contract_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	Checksum    string    `json:"checksum"`
+	BlobKey     string    `json:"-"`
+	UploadedBy  *uint64   `json:"uploaded_by,omitempty"`
+	CreateAt    time.Time `json:"created_at" gorm:"column:created_at"`
+}
@@ -14,6 +14,8 @@ type Contract struct {
 	Code            string `json:"code,omitempty"`
 	SuplyVendor     *Org   `json:"-" gorm:"foreignKey:VendorID;references:ID"`
 	SuplyVendorName string `json:"supply_vendor_name,omitempty"`
+	// Version incremented by every change of the contract, see base.ETag
+	Version uint64 `json:"-" gorm:"default:1"`
 }
 
 func (c *Contract) UpdateVendorName() {
@@ -0,0 +1,25 @@
+package model
+
+import "time"
+
+// IdempotencyKey model, the outcome of a mutating request sent with an Idempotency-Key header, replayed to
+// the retries of the request until it expires
+type IdempotencyKey struct {
+	ID uint64
+	// UserID the user who sent the request, 0 for the routes without authentication
+	UserID uint64
+	Key    string
+	// Fingerprint the hex encoded sha256 of the method, the path and the body of the request
+	Fingerprint string
+	// Status the status of the response, 0 while the request is in progress
+	Status      int
+	ContentType string
+	Body        []byte
+	CreateAt    time.Time `gorm:"column:created_at"`
+	ExpiresAt   time.Time
+}
+
+// InProgress whether the first request with the key is not responded yet
+func (k *IdempotencyKey) InProgress() bool {
+	return k.Status == 0
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// LoginOutcome reason of a login attempt result
+type LoginOutcome string
+
+const (
+	LoginSucceeded     LoginOutcome = "success"
+	LoginUnknownEmail  LoginOutcome = "unknown_email"
+	LoginWrongPassword LoginOutcome = "wrong_password"
+)
+
+// LoginEvent model, an authentication attempt
+type LoginEvent struct {
+	ID        uint64       `json:"id"`
+	UserID    *uint64      `json:"user_id"`
+	Email     string       `json:"email"`
+	SessionID *uint64      `json:"session_id,omitempty"`
+	IP        string       `json:"ip"`
+	UserAgent string       `json:"user_agent"`
+	Device    string       `json:"device"`
+	Success   bool         `json:"success"`
+	Reason    LoginOutcome `json:"reason"`
+	CreateAt  time.Time    `json:"created_at" gorm:"column:created_at"`
+}
+
+// ClientHistory what is known about the clients an user has logged in from
+type ClientHistory struct {
+	Logins      int64
+	KnownIP     bool
+	KnownDevice bool
+}
@@ -0,0 +1,84 @@
+package model
+
+import "fmt"
+
+// RBACPolicy the roles, the permissions and the permissions of the roles as code, kept in git and imported into
+// each environment. The entities are matched by name, RenamedFrom renames one instead of replacing it.
+type RBACPolicy struct {
+	Permissions []*PolicyPermission `json:"permissions" yaml:"permissions"`
+	Roles       []*PolicyRole       `json:"roles" yaml:"roles"`
+}
+
+type PolicyPermission struct {
+	Name        string `json:"name" yaml:"name"`
+	RenamedFrom string `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+}
+
+type PolicyRole struct {
+	Name        string   `json:"name" yaml:"name"`
+	RenamedFrom string   `json:"renamed_from,omitempty" yaml:"renamed_from,omitempty"`
+	Permissions []string `json:"permissions" yaml:"permissions"`
+}
+
+// RBACBinding a permission granted to a role
+type RBACBinding struct {
+	RoleID       uint64
+	PermissionID uint64
+}
+
+// RBACAction the kind of a change of a plan
+type RBACAction string
+
+const (
+	RBACAdd    RBACAction = "add"
+	RBACRename RBACAction = "rename"
+	RBACRemove RBACAction = "remove"
+	RBACGrant  RBACAction = "grant"
+	RBACRevoke RBACAction = "revoke"
+)
+
+// RBACChange a change of a plan, Permission and Role are the names after the change
+type RBACChange struct {
+	Action     RBACAction `json:"action"`
+	Permission string     `json:"permission,omitempty"`
+	Role       string     `json:"role,omitempty"`
+	// From the previous name of a renamed permission or role
+	From string `json:"from,omitempty"`
+	// Users the number of users holding a removed role, they lose its permissions
+	Users int64 `json:"users,omitempty"`
+	// the ids of the existing entities the change applies to
+	PermissionID uint64 `json:"-"`
+	RoleID       uint64 `json:"-"`
+}
+
+func (c *RBACChange) String() string {
+	switch c.Action {
+	case RBACAdd, RBACRemove:
+		sign := "+"
+		if c.Action == RBACRemove {
+			sign = "-"
+		}
+		if c.Role == "" {
+			return fmt.Sprintf("%s permission %q", sign, c.Permission)
+		}
+		if c.Users > 0 {
+			return fmt.Sprintf("%s role %q, held by %d users", sign, c.Role, c.Users)
+		}
+		return fmt.Sprintf("%s role %q", sign, c.Role)
+	case RBACRename:
+		if c.Role == "" {
+			return fmt.Sprintf("~ permission %q renamed %q", c.From, c.Permission)
+		}
+		return fmt.Sprintf("~ role %q renamed %q", c.From, c.Role)
+	case RBACGrant:
+		return fmt.Sprintf("+ grant %q to role %q", c.Permission, c.Role)
+	default:
+		return fmt.Sprintf("- revoke %q from role %q", c.Permission, c.Role)
+	}
+}
+
+// RBACPlan the changes importing a policy, in the order they are applied
+type RBACPlan struct {
+	Changes []*RBACChange `json:"changes"`
+	Applied bool          `json:"applied"`
+}
@@ -14,6 +14,8 @@ type Role struct {
 	UpdateAt    time.Time     `json:"updated_at" gorm:"column:updated_at"`
 	Status      bool          `json:"status"`
 	TotalUsers  int64         `json:"total_users"`
+	// Version incremented by every change of the role, see base.ETag
+	Version uint64 `json:"-" gorm:"default:1"`
 }
 
 type PermissionNew struct {
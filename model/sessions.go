@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+// Session model, a login on a device
+type Session struct {
+	ID         uint64     `json:"id"`
+	TokenID    string     `json:"-"`
+	UserID     uint64     `json:"user_id"`
+	Device     string     `json:"device"`
+	UserAgent  string     `json:"user_agent"`
+	IP         string     `json:"ip"`
+	CreateAt   time.Time  `json:"created_at" gorm:"column:created_at"`
+	LastSeenAt time.Time  `json:"last_seen_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	// ImpersonatorID the staff member who opened the session on behalf of the user
+	ImpersonatorID *uint64 `json:"impersonator_id,omitempty"`
+	Current        bool    `json:"current" gorm:"-"`
+}
+
+// IsActive session is neither revoked nor expired
+func (s *Session) IsActive() bool {
+	return s != nil && s.RevokedAt == nil && time.Now().Before(s.ExpiresAt)
+}
+
+// ClientInfo describe where a request comes from
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
@@ -14,17 +14,22 @@ type User struct {
 	OrgID      uint64    `json:"-"`
 	Org        *Org      `json:"orgs"`
 	Status     bool      `json:"status"`
+	Locale     string    `json:"locale"`
 	Last_login time.Time `json:"last_Login"`
 	CreateAt   time.Time `json:"created_at" gorm:"column:created_at"`
 	UpdateAt   time.Time `json:"updated_at" gorm:"column:updated_at"`
+	// Version incremented by every change of the user, see base.ETag
+	Version uint64 `json:"-" gorm:"default:1"`
 }
 
 type ListUserRes struct {
 	ID         uint64    `json:"id" mapstructure:"id"`
 	FullName   string    `json:"full_name"`
 	Roles      []*Role   `json:"roles" `
+	Org        *Org      `json:"orgs,omitempty"`
 	Email      string    `json:"email"`
 	Status     bool      `json:"status"`
+	Locale     string    `json:"locale"`
 	Last_login time.Time `json:"last_Login"`
 	CreateAt   time.Time `json:"created_at"`
 	UpdateAt   time.Time `json:"updated_at"`
@@ -35,8 +40,10 @@ func (r *User) MapUserModel() *ListUserRes {
 		ID:         r.ID,
 		FullName:   r.FullName,
 		Roles:      r.Roles,
+		Org:        r.Org,
 		Email:      r.Email,
 		Status:     r.Status,
+		Locale:     r.Locale,
 		Last_login: r.Last_login,
 		CreateAt:   r.CreateAt,
 		UpdateAt:   r.UpdateAt,
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/tpp/msf/domain/usecase/rbac"
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/context"
+)
+
+type rbacExportArgs struct {
+	Out string
+}
+
+// runRBACExport write the roles and the permissions as a YAML policy, to keep in git
+func runRBACExport(cfg *Config, args []string) int {
+	var a rbacExportArgs
+	fs, p := newFlagSet("rbac export", "[--out <file>]")
+	fs.StringVar(&a.Out, "out", "", "the file of the policy, default: the standard output")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := setup(func() { db.New(cfg.DB) }); err != nil {
+		return p.fail(err)
+	}
+
+	ctx := context.Background().WithDBTx(db.GetDBInstance())
+	policy, err := rbac.New().Export(ctx)
+	if err != nil {
+		return p.fail(err)
+	}
+	if p.json {
+		return p.result(policy, "")
+	}
+
+	var out io.Writer = p.out
+	if a.Out != "" {
+		f, err := os.Create(a.Out)
+		if err != nil {
+			return p.fail(err)
+		}
+		defer f.Close()
+		out = f
+	}
+	if err = rbac.WritePolicy(out, policy); err != nil {
+		return p.fail(err)
+	}
+	return exitOK
+}
+
+type rbacImportArgs struct {
+	File  string `schema:"file" validate:"required"`
+	Apply bool   `schema:"apply"`
+}
+
+// runRBACImport print the plan importing a YAML policy, the plan is applied in a transaction with --apply
+func runRBACImport(cfg *Config, args []string) int {
+	var a rbacImportArgs
+	fs, p := newFlagSet("rbac import", "--file <policy.yaml> [--apply]")
+	fs.StringVar(&a.File, "file", "", "the file of the policy, - for the standard input")
+	fs.BoolVar(&a.Apply, "apply", false, "apply the plan, it is only printed otherwise")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := validate(&a); err != nil {
+		return p.fail(err)
+	}
+
+	in := os.Stdin
+	if a.File != "-" {
+		f, err := os.Open(a.File)
+		if err != nil {
+			return p.fail(err)
+		}
+		defer f.Close()
+		in = f
+	}
+	policy, err := rbac.ParsePolicy(in)
+	if err != nil {
+		return p.fail(err)
+	}
+	if err = setup(func() { db.New(cfg.DB) }); err != nil {
+		return p.fail(err)
+	}
+
+	usecase := rbac.New()
+	var plan *model.RBACPlan
+	if a.Apply {
+		err = inUnitOfWork(func(ctx context.Context) (err error) {
+			plan, err = usecase.Apply(ctx, policy)
+			return err
+		})
+	} else {
+		plan, err = usecase.Plan(context.Background().WithDBTx(db.GetDBInstance()), policy)
+	}
+	if err != nil {
+		return p.fail(err)
+	}
+	if p.json {
+		return p.result(plan, "")
+	}
+
+	if len(plan.Changes) == 0 {
+		fmt.Fprintln(p.out, "nothing to change")
+		return exitOK
+	}
+	for _, change := range plan.Changes {
+		fmt.Fprintln(p.out, change)
+	}
+	if plan.Applied {
+		fmt.Fprintf(p.out, "\n%d changes applied\n", len(plan.Changes))
+	} else {
+		fmt.Fprintf(p.out, "\n%d changes to apply, run again with --apply to apply them\n", len(plan.Changes))
+	}
+	return exitOK
+}
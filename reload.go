@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/tpp/msf/application"
+	"github.com/tpp/msf/config"
+	mailer "github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/shared/auth"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/worker"
+)
+
+// watchReload subscribe the components applying the reloaded configuration, which is reloaded when the file changes,
+// on SIGHUP and by POST /admin/config/reload. The logger output, the server, the database and the blob store need a
+// restart.
+func watchReload() {
+	onReload("logger", func(cfg *Config) (func(), error) {
+		var level string
+		if cfg.Logger != nil {
+			level = cfg.Logger.Level
+		}
+		l, err := log.ParseLevel(level)
+		if err != nil {
+			return nil, err
+		}
+		return func() { log.SetLevel(l) }, nil
+	})
+	onReload("service", func(cfg *Config) (func(), error) {
+		return func() { application.Configure(cfg.Service) }, nil
+	})
+	onReload("auth", func(cfg *Config) (func(), error) {
+		return func() { auth.Configure(cfg.Auth) }, nil
+	})
+	onReload("mailer", func(cfg *Config) (func(), error) {
+		m, err := mailer.New(cfg.Mailer)
+		if err != nil {
+			return nil, err
+		}
+		return func() { mailer.SetMailInstance(m) }, nil
+	})
+
+	worker.Go("config-reload", func(ctx context.Context) {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		defer signal.Stop(hup)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-hup:
+				if _, err := config.Reload(); err != nil {
+					log.Error().Err(err).Msg("ReloadConfigError")
+				}
+			}
+		}
+	})
+}
+
+// onReload subscribe the component name, see config.Subscribe
+func onReload(name string, prepare func(cfg *Config) (swap func(), err error)) {
+	config.Subscribe(name, func(c any) (func(), error) {
+		return prepare(c.(*Config))
+	})
+}
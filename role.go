@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/tpp/msf/domain/usecase/auth"
+	"github.com/tpp/msf/domain/usecase/users"
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+)
+
+type roleGrantArgs struct {
+	UserID  uint64  `schema:"user" validate:"required"`
+	RoleIDs idsFlag `schema:"role" validate:"required"`
+}
+
+type roleGrantResult struct {
+	UserID uint64        `json:"user_id"`
+	Roles  []*model.Role `json:"roles"`
+}
+
+// runRoleGrant add roles to the ones of an user, unlike PUT /users/assign-role which replaces them
+func runRoleGrant(cfg *Config, args []string) int {
+	var a roleGrantArgs
+	fs, p := newFlagSet("role grant", "--user <user id> --role <id,...>")
+	fs.Uint64Var(&a.UserID, "user", 0, "the id of the user")
+	fs.Var(&a.RoleIDs, "role", "the ids of the roles granted, comma separated or repeated")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := validate(&a); err != nil {
+		return p.fail(err)
+	}
+	if err := setup(func() { db.New(cfg.DB) }); err != nil {
+		return p.fail(err)
+	}
+
+	userUsecase, authUsecase := users.New(), auth.New()
+	var granted []*model.Role
+	err := inUnitOfWork(func(ctx context.Context) error {
+		for _, roleID := range a.RoleIDs {
+			role, err := authUsecase.GetRole(ctx, uint64(roleID), &base.Fieldset{})
+			if errors.Is(err, base.ErrorNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("role %d does not exist", roleID)
+			}
+			if err != nil {
+				return err
+			}
+			granted = append(granted, role)
+		}
+
+		user, err := userUsecase.GetUser(ctx, a.UserID)
+		if err != nil {
+			return userNotFound(err, a.UserID)
+		}
+		roleIDs := append([]int64{}, a.RoleIDs...)
+		for _, role := range user.Roles {
+			if !slices.Contains(roleIDs, int64(role.ID)) {
+				roleIDs = append(roleIDs, int64(role.ID))
+			}
+		}
+		// the ETags of the user held by the clients are stale
+		if _, err = userUsecase.ClaimVersion(ctx, a.UserID, 0); err != nil {
+			return err
+		}
+		return userUsecase.AssignRole(ctx, int64(a.UserID), roleIDs)
+	})
+	if err != nil {
+		return p.fail(err)
+	}
+
+	var names []string
+	for _, role := range granted {
+		names = append(names, string(role.Name))
+	}
+	return p.result(roleGrantResult{UserID: a.UserID, Roles: granted}, "granted %s to user %d", strings.Join(names, ", "), a.UserID)
+}
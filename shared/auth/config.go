@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"errors"
+	"sync/atomic"
+
+	jwt "github.com/dgrijalva/jwt-go"
+)
+
+// errNoSecret no auth.secret is configured, the tokens are neither signed nor verified rather than by an empty key
+var errNoSecret = errors.New("auth.secret is not configured")
+
+type Config struct {
+	// Kind: jwt, default: jwt
+	Kind string `config:"kind" default:"jwt" validate:"omitempty,oneof=jwt"`
+	// Secret the key signing the tokens
+	Secret string `config:"secret" secret:"true" validate:"required"`
+	// PreviousSecrets the keys which signed the tokens before Secret, the tokens they signed are still accepted, so
+	// that the secret is rotated without logging the users out
+	PreviousSecrets []string `config:"previous_secrets" secret:"true"`
+	// TokenSchema the scheme of the tokens in Header, default: Bearer
+	TokenSchema string `config:"token_schema" default:"Bearer"`
+	// Header the header carrying the tokens, default: Authorization
+	Header string       `config:"header" default:"Authorization"`
+	Claim  *ClaimConfig `config:"claim" validate:"required"`
+}
+
+type ClaimConfig struct {
+	Issuer string `config:"issuer"`
+	// ExpireIn the lifetime in seconds of the tokens, default: 172800
+	ExpireIn int64 `config:"expire_in" default:"172800" validate:"gt=0"`
+	// ImpersonationExpireIn the lifetime in seconds of the tokens issued to impersonate an user, default: 900
+	ImpersonationExpireIn int64 `config:"impersonation_expire_in" default:"900" validate:"gte=0"`
+}
+
+// keyRing the key signing the tokens, followed by the ones accepted to verify them
+type keyRing [][]byte
+
+// state the settings of the tokens along with their keys, swapped as a whole by Configure
+type state struct {
+	settings *Config
+	keys     keyRing
+}
+
+var current atomic.Value
+
+func init() {
+	Configure(nil)
+}
+
+// Configure set the settings of the tokens, before serving and when the configuration is reloaded
+func Configure(c *Config) {
+	if c == nil {
+		c = &Config{}
+	}
+	if c.Claim == nil {
+		c.Claim = &ClaimConfig{}
+	}
+
+	keys := keyRing{[]byte(c.Secret)}
+	for _, secret := range c.PreviousSecrets {
+		if secret != "" {
+			keys = append(keys, []byte(secret))
+		}
+	}
+	current.Store(&state{settings: c, keys: keys})
+}
+
+// Settings the settings of the tokens
+func Settings() *Config {
+	return current.Load().(*state).settings
+}
+
+// keys the key ring of the tokens
+func keys() keyRing {
+	return current.Load().(*state).keys
+}
+
+// signing the key signing the tokens
+func (k keyRing) signing() ([]byte, error) {
+	if len(k[0]) == 0 {
+		return nil, errNoSecret
+	}
+	return k[0], nil
+}
+
+// parse verify tokenString with the keys in turn into claims, the tokens signed before a rotation of the secret are
+// verified by the previous ones
+func (k keyRing) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	var token *jwt.Token
+	err := errNoSecret
+	for _, key := range k {
+		if len(key) == 0 {
+			continue
+		}
+		key := key
+		token, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, errInvalidToken
+			}
+			return key, nil
+		})
+		var ve *jwt.ValidationError
+		if err == nil || !errors.As(err, &ve) || ve.Errors&jwt.ValidationErrorSignatureInvalid == 0 {
+			return token, err
+		}
+	}
+	return token, err
+}
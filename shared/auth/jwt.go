@@ -1,14 +1,15 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
-	"github.com/tpp/msf/shared/constant"
 	"time"
 
 	jwt "github.com/dgrijalva/jwt-go"
-	"github.com/tpp/msf/config"
 	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/metrics"
 )
 
 // UserAuth user auth
@@ -16,66 +17,131 @@ type UserAuth = model.User
 
 type frClaims struct {
 	jwt.StandardClaims
-	Context *UserAuth `json:"context"`
+	Context      json.RawMessage `json:"context"`
+	Impersonator int64           `json:"impersonator"`
 }
-type ID struct {
-	ID int64 `json:"context"`
+
+// Claims holds the information carried by a token
+type Claims struct {
+	UserID  int64
+	TokenID string
+	// ImpersonatorID the real actor when the token was issued to impersonate UserID
+	ImpersonatorID int64
 }
 
 var (
 	errInvalidToken = errors.New("invalid token")
 )
 
+const (
+	// ActionResetPassword audience of the token sent in the reset password email
+	ActionResetPassword = "reset_password"
+	// ActionActivateAccount audience of the token sent in the account activation email
+	ActionActivateAccount = "activate_account"
+)
+
+// PasswordClaim the context of the tokens setting the password of a user, see ActionResetPassword
+type PasswordClaim struct {
+	UserID uint64 `json:"user_id"`
+	// Stamp the fingerprint of the password the token replaces, the token is spent once the password changed
+	Stamp string `json:"stamp"`
+}
+
+// PasswordStamp the fingerprint of hashedPassword carried by the password tokens
+func PasswordStamp(hashedPassword string) string {
+	sum := sha256.Sum256([]byte(hashedPassword))
+	return hex.EncodeToString(sum[:8])
+}
+
 // ParseJWTToken to userID
 func ParseJWTToken(tokenString string) (Id int64, err error) {
-	// get private key.
-	key := config.GetConfigByte("auth.secret")
-	if len(key) == 0 {
-		key = constant.Secret
-	}
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, errInvalidToken
-		}
-		return key, nil
-	})
+	claims, err := ParseJWTClaims(tokenString)
 	if err != nil {
-		return 0, errInvalidToken
+		return 0, err
 	}
+	return claims.UserID, nil
+}
 
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		var claim frClaims
-		var id ID
-		bs, err := json.Marshal(claims)
-		if err != nil {
-			return 0, errInvalidToken
-		}
-		json.Unmarshal(bs, &claim)
-		if err != nil {
-			return 0, errInvalidToken
-		}
-		if claim.Context.ID == 0 {
-			json.Unmarshal(bs, &id)
-			return id.ID, nil
-		}
+// ParseJWTClaims verify the token and extract its claims
+func ParseJWTClaims(tokenString string) (*Claims, error) {
+	token, err := keys().parse(tokenString, jwt.MapClaims{})
+	if err != nil {
+		return nil, errInvalidToken
+	}
 
-		return int64(claim.Context.ID), nil
+	mapClaims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, errInvalidToken
 	}
 
-	return 0, errInvalidToken
+	var claim frClaims
+	bs, err := json.Marshal(mapClaims)
+	if err != nil {
+		return nil, errInvalidToken
+	}
+	if err = json.Unmarshal(bs, &claim); err != nil {
+		return nil, errInvalidToken
+	}
+	// action tokens must not be used to authenticate
+	if claim.Audience != "" {
+		return nil, errInvalidToken
+	}
+
+	// the context is either the user itself or only its id
+	var id int64
+	var user UserAuth
+	if err = json.Unmarshal(claim.Context, &user); err == nil {
+		id = int64(user.ID)
+	} else {
+		json.Unmarshal(claim.Context, &id)
+	}
+
+	return &Claims{UserID: id, TokenID: claim.Id, ImpersonatorID: claim.Impersonator}, nil
 
 }
 
+// TokenTTL lifetime of the generated tokens
+func TokenTTL() time.Duration {
+	return time.Duration(Settings().Claim.ExpireIn) * time.Second
+}
+
+// ImpersonationTTL lifetime of the tokens issued to impersonate an user, default 15 minutes
+func ImpersonationTTL() time.Duration {
+	if expireIn := Settings().Claim.ImpersonationExpireIn; expireIn > 0 {
+		return time.Duration(expireIn) * time.Second
+	}
+	return 15 * time.Minute
+}
+
 // GenerateJWTToken Generate token
 func GenerateJWTToken(object any) (string, error) {
+	return GenerateJWTTokenWithID(object, "")
+}
 
-	exp := config.GetConfig[int64]("auth.claim.expire_in")
-	issuer := config.GetConfig[string]("auth.claim.issuer")
+// GenerateJWTTokenWithID Generate token carrying tokenID, so that it can be tied to a session
+func GenerateJWTTokenWithID(object any, tokenID string) (string, error) {
+	return generateJWTToken(object, tokenID, 0, TokenTTL())
+}
+
+// GenerateJWTTokenWithTTL Generate token carrying tokenID which expires after ttl instead of TokenTTL
+func GenerateJWTTokenWithTTL(object any, tokenID string, ttl time.Duration) (string, error) {
+	return generateJWTToken(object, tokenID, 0, ttl)
+}
+
+// GenerateImpersonationToken Generate a short-lived token for object carrying the id of the impersonator
+func GenerateImpersonationToken(object any, impersonatorID uint64, tokenID string) (string, error) {
+	return generateJWTToken(object, tokenID, impersonatorID, ImpersonationTTL())
+}
+
+func generateJWTToken(object any, tokenID string, impersonatorID uint64, ttl time.Duration) (string, error) {
+
+	issuer := Settings().Claim.Issuer
 	customClaims := object
 
 	standardClaims := jwt.StandardClaims{
+		Id:        tokenID,
 		Issuer:    issuer,
-		ExpiresAt: time.Now().Add(time.Duration(exp) * time.Second).Unix(),
+		ExpiresAt: time.Now().Add(ttl).Unix(),
 		IssuedAt:  time.Now().Unix(),
 		NotBefore: time.Now().Unix(),
 	}
@@ -83,16 +149,67 @@ func GenerateJWTToken(object any) (string, error) {
 	pay := payload{
 		StandardClaims: standardClaims,
 		Context:        customClaims,
+		Impersonator:   impersonatorID,
 	}
 
 	token := jwt.New(jwt.SigningMethodHS256)
 	token.Claims = pay
-	key := config.GetConfigByte("auth.secret")
+	key, err := keys().signing()
+	if err != nil {
+		return "", err
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", err
+	}
+	kind := "access"
+	if impersonatorID != 0 {
+		kind = "impersonation"
+	}
+	metrics.CountToken(kind)
+	return signed, nil
+}
 
-	return token.SignedString(key)
+// GenerateActionToken generate a token only valid for action, it can't be used to authenticate
+func GenerateActionToken(action string, object any, ttl time.Duration) (string, error) {
+	token := jwt.New(jwt.SigningMethodHS256)
+	token.Claims = payload{
+		StandardClaims: jwt.StandardClaims{
+			Audience:  action,
+			Issuer:    Settings().Claim.Issuer,
+			ExpiresAt: time.Now().Add(ttl).Unix(),
+			IssuedAt:  time.Now().Unix(),
+			NotBefore: time.Now().Unix(),
+		},
+		Context: object,
+	}
+	key, err := keys().signing()
+	if err != nil {
+		return "", err
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		return "", err
+	}
+	metrics.CountToken(action)
+	return signed, nil
+}
+
+// ParseActionToken verify a token generated for action and decode its context into out
+func ParseActionToken(tokenString string, action string, out any) error {
+	var claim frClaims
+	_, err := keys().parse(tokenString, &claim)
+	if err != nil || claim.Audience != action {
+		return errInvalidToken
+	}
+	if err = json.Unmarshal(claim.Context, out); err != nil {
+		return errInvalidToken
+	}
+	return nil
 }
 
 type payload struct {
 	jwt.StandardClaims
-	Context any `json:"context"`
+	Context      any    `json:"context"`
+	Impersonator uint64 `json:"impersonator,omitempty"`
 }
@@ -1,7 +1,104 @@
 package base
 
-import "errors"
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"gorm.io/gorm"
+)
 
 var (
 	ErrorNotFound error = errors.New("not found")
 )
+
+// Codes the stable machine readable codes of the error responses
+const (
+	CodeMalformedRequest = "malformed_request"
+	CodeValidationFailed = "validation_failed"
+	CodeBadRequest       = "bad_request"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodeBodyTooLarge     = "body_too_large"
+	// CodePreconditionRequired the mutation was sent without If-Match
+	CodePreconditionRequired = "precondition_required"
+	// CodePreconditionFailed the If-Match of the mutation is not the current ETag of the resource
+	CodePreconditionFailed = "precondition_failed"
+	// CodeIdempotencyKeyReused the Idempotency-Key of the request was sent with a different request
+	CodeIdempotencyKeyReused = "idempotency_key_reused"
+	// CodeIdempotencyInProgress the request with the same Idempotency-Key is not responded yet
+	CodeIdempotencyInProgress = "idempotency_in_progress"
+	CodeInternal              = "internal_error"
+)
+
+// Error an error of the API with its http status, see ResponseError
+type Error struct {
+	Status int
+	Code   string
+	Detail string
+	// Fields the field level details
+	Fields APIErrors
+	// cause is logged, never responded
+	cause error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.cause)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithCause a copy of the error caused by err
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+func NewError(status int, code, detail string) *Error {
+	return &Error{Status: status, Code: code, Detail: detail}
+}
+
+func NewBadRequestError(code, detail string) *Error {
+	return NewError(http.StatusBadRequest, code, detail)
+}
+
+func NewUnauthorizedError(detail string) *Error {
+	return NewError(http.StatusUnauthorized, CodeUnauthorized, detail)
+}
+
+func NewForbiddenError(detail string) *Error {
+	return NewError(http.StatusForbidden, CodeForbidden, detail)
+}
+
+func NewNotFoundError(detail string) *Error {
+	return NewError(http.StatusNotFound, CodeNotFound, detail)
+}
+
+// AsError the API error of err: APIErrors are validation errors, ErrorNotFound and gorm.ErrRecordNotFound
+// are not found errors, any other error is an internal error
+func AsError(err error) *Error {
+	var apiError *Error
+	var apiErrors APIErrors
+	switch {
+	case errors.As(err, &apiError):
+		return apiError
+	case errors.As(err, &apiErrors):
+		return &Error{
+			Status: http.StatusBadRequest,
+			Code:   CodeValidationFailed,
+			Detail: "the request has invalid fields",
+			Fields: apiErrors,
+		}
+	case errors.Is(err, ErrorNotFound), errors.Is(err, gorm.ErrRecordNotFound):
+		return NewNotFoundError("the resource does not exist").WithCause(err)
+	}
+	return NewError(http.StatusInternalServerError, CodeInternal, "the server failed to handle the request").WithCause(err)
+}
@@ -0,0 +1,100 @@
+package base
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPreconditionRequired a mutation was sent without the If-Match header
+	ErrPreconditionRequired = NewError(http.StatusPreconditionRequired, CodePreconditionRequired,
+		"the If-Match header with the ETag of the resource is required")
+	// ErrVersionConflict the resource was modified since the client read the ETag of its If-Match header
+	ErrVersionConflict = NewError(http.StatusPreconditionFailed, CodePreconditionFailed,
+		"the resource was modified since it was read")
+)
+
+// ETag the strong entity tag of the version of a resource
+func ETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+// IfMatch the version of the If-Match header of r, 0 for * which matches any version. A weak or unknown
+// entity tag never matches, only one entity tag is supported.
+func IfMatch(r *http.Request) (uint64, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, ErrPreconditionRequired
+	}
+	if header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, NewApiErrors("If-Match", "only one entity tag is supported")
+	}
+	version, ok := parseETag(header)
+	if !ok {
+		return 0, ErrVersionConflict
+	}
+	return version, nil
+}
+
+// ResponseVersioned respond data with the ETag of version, or 304 Not Modified without body when the If-None-Match
+// header of r lists the ETag
+func (h *httpHandler) ResponseVersioned(w http.ResponseWriter, r *http.Request, data any, version uint64) {
+	etag := ETag(version)
+	w.Header().Set("ETag", etag)
+	if noneMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	h.ResponseSuccess(w, data)
+}
+
+// noneMatch whether an If-None-Match header lists etag, compared weakly
+func noneMatch(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func parseETag(tag string) (uint64, bool) {
+	if len(tag) < 3 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	version, err := strconv.ParseUint(tag[1:len(tag)-1], 10, 64)
+	return version, err == nil && version > 0
+}
+
+// BumpVersion increment the version of the row id of table when it is still version, 0 matches any version.
+// A row modified meanwhile fails with ErrVersionConflict, a missing one with ErrorNotFound. The row is locked
+// until the end of the transaction, the concurrent mutations of the row wait for it then fail.
+func (r *repo) BumpVersion(ctx context.Context, table string, id, version uint64) (uint64, error) {
+	var bumped []uint64
+	if err := r.DB(ctx).Raw("UPDATE ? SET version = version + 1 WHERE id = ? AND (? = 0 OR version = ?) RETURNING version",
+		clause.Table{Name: table}, id, version, version).Scan(&bumped).Error; err != nil {
+		r.Error(ctx).Err(err).Str("table", table).Msg("BumpVersionError")
+		return 0, err
+	}
+	if len(bumped) == 1 {
+		return bumped[0], nil
+	}
+
+	var count int64
+	if err := r.DB(ctx).Table(table).Where("id = ?", id).Count(&count).Error; err != nil {
+		r.Error(ctx).Err(err).Str("table", table).Msg("BumpVersionError")
+		return 0, err
+	}
+	if count == 0 {
+		return 0, ErrorNotFound
+	}
+	return 0, ErrVersionConflict
+}
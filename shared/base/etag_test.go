@@ -0,0 +1,95 @@
+package base
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/utils"
+)
+
+func TestIfMatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version uint64
+		err     error
+	}{
+		{"missing", "", 0, ErrPreconditionRequired},
+		{"any", "*", 0, nil},
+		{"version", `"3"`, 3, nil},
+		{"weak", `W/"3"`, 0, ErrVersionConflict},
+		{"unquoted", "3", 0, ErrVersionConflict},
+		{"list", `"3", "4"`, 0, NewApiErrors("If-Match", "only one entity tag is supported")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/users/name", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			version, err := IfMatch(r)
+			require.EqualValues(t, tt.err, err)
+			require.EqualValues(t, tt.version, version)
+		})
+	}
+}
+
+func TestHttpHandler_ResponseVersioned(t *testing.T) {
+	h := &httpHandler{Logger: newBaseLogger(log.Logger)}
+	tests := []struct {
+		name        string
+		ifNoneMatch string
+		status      int
+	}{
+		{"without If-None-Match", "", http.StatusOK},
+		{"modified", `"2"`, http.StatusOK},
+		{"not modified", `"2", "3"`, http.StatusNotModified},
+		{"not modified weakly", `W/"3"`, http.StatusNotModified},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+			r.Header.Set("If-None-Match", tt.ifNoneMatch)
+			w := httptest.NewRecorder()
+			h.ResponseVersioned(w, r, map[string]string{"full_name": "dung"}, 3)
+			require.EqualValues(t, tt.status, w.Code)
+			require.EqualValues(t, `"3"`, w.Header().Get("ETag"))
+			if tt.status == http.StatusNotModified {
+				require.Empty(t, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRepository_BumpVersion(t *testing.T) {
+	gDB, mock, cnl, err := utils.NewDBMock()
+	require.NoError(t, err)
+	defer cnl()
+
+	r := NewBaseRepository("test")
+	ctx := context.Background().WithDBTx(gDB)
+	bump := `UPDATE "users" SET version = version + 1 WHERE id = $1 AND ($2 = 0 OR version = $3) RETURNING version`
+	count := `SELECT count(*) FROM "users" WHERE id = $1`
+
+	mock.ExpectPrepare(bump).ExpectQuery().WithArgs(1, 3, 3).WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(4))
+	version, err := r.BumpVersion(ctx, "users", 1, 3)
+	require.NoError(t, err)
+	require.EqualValues(t, 4, version)
+
+	mock.ExpectQuery(bump).WithArgs(1, 2, 2).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectPrepare(count).ExpectQuery().WithArgs(1).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	_, err = r.BumpVersion(ctx, "users", 1, 2)
+	require.Equal(t, ErrVersionConflict, err)
+
+	mock.ExpectQuery(bump).WithArgs(9, 0, 0).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectQuery(count).WithArgs(9).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	_, err = r.BumpVersion(ctx, "users", 9, 0)
+	require.Equal(t, ErrorNotFound, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,213 @@
+package base
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Fieldset the sparse fieldset of a read endpoint, comma separated keys of the fields to select and of the
+// relations to expand, e.g. fields=full_name,email&expand=roles.permissions.
+// Without fields nor expand the default expansions of the repository are loaded.
+type Fieldset struct {
+	Fields string `form:"fields" schema:"fields"`
+	Expand string `form:"expand" schema:"expand"`
+}
+
+// IsEmpty whether neither fields nor expand were requested
+func (fs *Fieldset) IsEmpty() bool {
+	return strings.TrimSpace(fs.Fields) == "" && strings.TrimSpace(fs.Expand) == ""
+}
+
+// Projection the JSON keys of a resource known to its Fieldset, see Project
+type Projection struct {
+	// Relations the expansion keys, e.g. roles.permissions, their JSON keys are omitted unless expanded
+	Relations []string
+	// Computed the JSON keys set whatever the fieldset, e.g. a count, kept along with the fields
+	Computed []string
+	// JSONKeys the JSON key of the fieldset keys named otherwise, e.g. org to orgs
+	JSONKeys map[string]string
+}
+
+// Project v, a struct or a slice of them, as the JSON objects of what fs loaded: id, the fields, every field without
+// fields, and the expansions. The unloaded ones are omitted, their zero values would pass for actual data. v is
+// returned unchanged when fs is empty, every field and the default expansions are loaded then.
+func (p *Projection) Project(fs *Fieldset, v any) (any, error) {
+	if fs.IsEmpty() {
+		return v, nil
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var out any
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	// the ids do not fit a float64
+	dec.UseNumber()
+	if err = dec.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	expand := splitKeys(fs.Expand)
+	for _, relation := range p.Relations {
+		if !expanded(relation, expand) {
+			path := strings.Split(relation, ".")
+			p.omit(out, path[:len(path)-1], p.jsonKey(path[len(path)-1]))
+		}
+	}
+	if fields := splitKeys(fs.Fields); len(fields) > 0 {
+		keep := append([]string{"id"}, p.Computed...)
+		for _, key := range fields {
+			keep = append(keep, p.jsonKey(key))
+		}
+		for _, key := range expand {
+			keep = append(keep, p.jsonKey(strings.SplitN(key, ".", 2)[0]))
+		}
+		each(out, func(object map[string]any) {
+			for key := range object {
+				if !slices.Contains(keep, key) {
+					delete(object, key)
+				}
+			}
+		})
+	}
+	return out, nil
+}
+
+func (p *Projection) jsonKey(key string) string {
+	if name, ok := p.JSONKeys[key]; ok {
+		return name
+	}
+	return key
+}
+
+// omit delete key from the objects at path of v
+func (p *Projection) omit(v any, path []string, key string) {
+	each(v, func(object map[string]any) {
+		if len(path) == 0 {
+			delete(object, key)
+			return
+		}
+		p.omit(object[p.jsonKey(path[0])], path[1:], key)
+	})
+}
+
+// each call fn with v, an object, or with the objects of v, an array
+func each(v any, fn func(object map[string]any)) {
+	switch v := v.(type) {
+	case map[string]any:
+		fn(v)
+	case []any:
+		for _, item := range v {
+			if object, ok := item.(map[string]any); ok {
+				fn(object)
+			}
+		}
+	}
+}
+
+// expanded whether relation is loaded by expand, directly or along one of its nested relations
+func expanded(relation string, expand []string) bool {
+	for _, key := range expand {
+		if key == relation || strings.HasPrefix(key, relation+".") {
+			return true
+		}
+	}
+	return false
+}
+
+type expansion struct {
+	preload string
+	// columns the preload needs, e.g. the foreign key of a belongs to relation
+	columns []string
+}
+
+func (r *repo) RegisterFields(presentKeys ...string) {
+	r.fieldKeys = append(r.fieldKeys, presentKeys...)
+}
+
+func (r *repo) RegisterExpansion(presentKey, preload string, columns ...string) {
+	r.mapExpansions[presentKey] = expansion{preload: preload, columns: columns}
+}
+
+func (r *repo) RegisterDefaultExpand(presentKeys ...string) {
+	r.defaultExpand = append(r.defaultExpand, presentKeys...)
+}
+
+// Select select the fields and preload the expansions of fs, unknown keys are added to the db errors as APIErrors.
+// columns are selected whatever the client asked for, e.g. the version of the ETag.
+func (r *repo) Select(fs *Fieldset, columns ...string) Scope {
+	return r.selectFields(fs, columns...)
+}
+
+// Expands whether fs loads the expansion presentKey, the default ones when fs is empty
+func (r *repo) Expands(fs *Fieldset, presentKey string) bool {
+	expand := r.defaultExpand
+	if !fs.IsEmpty() {
+		expand = splitKeys(fs.Expand)
+	}
+	return expanded(presentKey, expand)
+}
+
+// selectFields select the fields of fs along with columns, which the query needs whatever the client asked for
+func (r *repo) selectFields(fs *Fieldset, columns ...string) Scope {
+	return func(db *gorm.DB) *gorm.DB {
+		expand := r.defaultExpand
+		if !fs.IsEmpty() {
+			expand = splitKeys(fs.Expand)
+		}
+
+		// nil selects every column
+		var selects []string
+		if strings.TrimSpace(fs.Fields) != "" {
+			selects = append([]string{"id"}, columns...)
+			for _, key := range splitKeys(fs.Fields) {
+				if !slices.Contains(r.fieldKeys, key) {
+					db.AddError(NewApiErrors("fields", fmt.Sprintf("not support for value '%s'", key)))
+					return db
+				}
+				selects = append(selects, key)
+			}
+		}
+
+		for _, key := range expand {
+			exp, ok := r.mapExpansions[key]
+			if !ok {
+				db.AddError(NewApiErrors("expand", fmt.Sprintf("not support for value '%s'", key)))
+				return db
+			}
+			if selects != nil {
+				selects = append(selects, exp.columns...)
+			}
+			db = db.Preload(exp.preload)
+		}
+
+		if selects == nil {
+			return db
+		}
+		// qualified, the sort keys may join other tables
+		var selectClause clause.Select
+		for _, name := range selects {
+			column := clause.Column{Table: clause.CurrentTable, Name: name}
+			if !slices.Contains(selectClause.Columns, column) {
+				selectClause.Columns = append(selectClause.Columns, column)
+			}
+		}
+		return db.Clauses(selectClause)
+	}
+}
+
+func splitKeys(s string) []string {
+	var keys []string
+	for _, key := range strings.Split(s, ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
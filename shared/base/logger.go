@@ -20,31 +20,40 @@ type logger struct {
 }
 
 func (l *logger) Trace(ctx context.Context) *zerolog.Event {
-	return l.Logger.Info().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Info(), ctx)
 }
 
 func (l *logger) Debug(ctx context.Context) *zerolog.Event {
-	return l.Logger.Info().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Info(), ctx)
 }
 
 func (l *logger) Info(ctx context.Context) *zerolog.Event {
-	return l.Logger.Info().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Info(), ctx)
 }
 
 func (l *logger) Warn(ctx context.Context) *zerolog.Event {
-	return l.Logger.Warn().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Warn(), ctx)
 }
 
 func (l *logger) Error(ctx context.Context) *zerolog.Event {
-	return l.Logger.Error().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Error(), ctx)
 }
 
 func (l *logger) Faltal(ctx context.Context) *zerolog.Event {
-	return l.Logger.Fatal().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Fatal(), ctx)
 }
 
 func (l *logger) Panic(ctx context.Context) *zerolog.Event {
-	return l.Logger.Panic().Str("req_id", ctx.ReqID())
+	return tag(l.Logger.Panic(), ctx)
+}
+
+// tag the event with the request id, and the impersonator when the request is made on behalf of an user
+func tag(e *zerolog.Event, ctx context.Context) *zerolog.Event {
+	e = e.Str("req_id", ctx.ReqID())
+	if impersonator := ctx.Impersonator(); impersonator != nil {
+		e = e.Uint64("impersonator_id", impersonator.ID)
+	}
+	return e
 }
 
 func newBaseLogger(l zerolog.Logger) Logger {
@@ -0,0 +1,252 @@
+package base
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+)
+
+// List pagination modes
+const (
+	PageModeOffset = "offset"
+	PageModeCursor = "cursor"
+)
+
+const defaultLimit = 10
+
+// Page what a list query knows about the rows around the returned ones
+type Page struct {
+	// Total nil when it was not counted
+	Total      *int64
+	NextCursor string
+	PrevCursor string
+}
+
+// cursor the sort values of the row a page starts after
+type cursor struct {
+	Backward bool              `json:"b,omitempty"`
+	Values   []json.RawMessage `json:"v"`
+}
+
+type cursorKey struct {
+	column clause.Column
+	field  *schema.Field
+	desc   bool
+	// nullable only pointer fields can hold a null value
+	nullable bool
+}
+
+// FindPage run db with the filters, sort and pagination of params into dest, a pointer to a slice.
+// findScopes and the fieldset are only applied to the query of the rows, not to the count.
+func (r *repo) FindPage(db *gorm.DB, params *ListParams, dest any, findScopes ...Scope) (*Page, error) {
+	var page Page
+
+	db = db.Scopes(r.Filter(params.Filters))
+	if !params.IsCursorMode() || params.WithTotal {
+		var total int64
+		if err := db.Count(&total).Error; err != nil {
+			return nil, err
+		}
+		page.Total = &total
+	}
+
+	for _, scope := range findScopes {
+		db = db.Scopes(scope)
+	}
+
+	if !params.IsCursorMode() {
+		err := db.Scopes(r.Select(&params.Fieldset), r.Paginate(params.Limit, params.Offset*params.Limit, params.Sort)).Find(dest).Error
+		return &page, err
+	}
+	return r.findCursorPage(db, params, dest, &page)
+}
+
+func (r *repo) findCursorPage(db *gorm.DB, params *ListParams, dest any, page *Page) (*Page, error) {
+	if db.Statement.Schema == nil {
+		if err := db.Statement.Parse(db.Statement.Model); err != nil {
+			return nil, err
+		}
+	}
+
+	keys, err := r.cursorKeys(db.Statement.Schema, params.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	// the cursors are built from the sort values of the rows
+	var columns []string
+	for _, key := range keys {
+		columns = append(columns, key.column.Name)
+	}
+	db = db.Scopes(r.selectFields(&params.Fieldset, columns...))
+
+	var cur cursor
+	var values []any
+	if params.Cursor != "" {
+		if cur, values, err = decodeCursor(params.Cursor, keys); err != nil {
+			return nil, NewApiErrors("cursor", "invalid cursor")
+		}
+		db = db.Clauses(clause.Where{Exprs: []clause.Expression{after(keys, values, cur.Backward)}})
+	}
+
+	var orderBy clause.OrderBy
+	for _, key := range keys {
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{Column: key.column, Desc: key.desc != cur.Backward})
+	}
+
+	// one more row tells whether there is a page after this one
+	if err = db.Clauses(orderBy).Limit(params.Limit + 1).Find(dest).Error; err != nil {
+		return nil, err
+	}
+
+	rows := reflect.ValueOf(dest).Elem()
+	hasMore := rows.Len() > params.Limit
+	if hasMore {
+		rows.Set(rows.Slice(0, params.Limit))
+	}
+	if cur.Backward {
+		for i, j := 0, rows.Len()-1; i < j; i, j = i+1, j-1 {
+			tmp := rows.Index(i).Interface()
+			rows.Index(i).Set(rows.Index(j))
+			rows.Index(j).Set(reflect.ValueOf(tmp))
+		}
+	}
+
+	if rows.Len() == 0 {
+		return page, nil
+	}
+	first, last := rows.Index(0), rows.Index(rows.Len()-1)
+	if cur.Backward {
+		page.NextCursor = encodeCursor(db.Statement.Context, keys, last, false)
+		if hasMore {
+			page.PrevCursor = encodeCursor(db.Statement.Context, keys, first, true)
+		}
+		return page, nil
+	}
+	if hasMore {
+		page.NextCursor = encodeCursor(db.Statement.Context, keys, last, false)
+	}
+	if params.Cursor != "" {
+		page.PrevCursor = encodeCursor(db.Statement.Context, keys, first, true)
+	}
+	return page, nil
+}
+
+// cursorKeys the sort keys, which must be columns of the queried table, followed by the primary key
+func (r *repo) cursorKeys(sch *schema.Schema, sort string) ([]cursorKey, error) {
+	sortKeys, err := r.parseSort(sort)
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []cursorKey
+	var hasPrimaryKey bool
+	for _, sortKey := range sortKeys {
+		column := r.column(sortKey.key)
+		field := sch.LookUpField(column.Name)
+		if column.Table != clause.CurrentTable || field == nil {
+			return nil, NewApiErrors("sort", fmt.Sprintf("not support for value '%s' with cursor pagination", sortKey.key))
+		}
+		hasPrimaryKey = hasPrimaryKey || field == sch.PrioritizedPrimaryField
+		keys = append(keys, cursorKey{
+			column:   column,
+			field:    field,
+			desc:     sortKey.desc,
+			nullable: field.FieldType.Kind() == reflect.Ptr && !field.NotNull && !field.PrimaryKey,
+		})
+	}
+
+	if !hasPrimaryKey {
+		if sch.PrioritizedPrimaryField == nil {
+			return nil, NewApiErrors("sort", "cursor pagination is not supported")
+		}
+		keys = append(keys, cursorKey{
+			column: clause.Column{Table: clause.CurrentTable, Name: sch.PrioritizedPrimaryField.DBName},
+			field:  sch.PrioritizedPrimaryField,
+		})
+	}
+	return keys, nil
+}
+
+// after the rows strictly after values in the order of keys, reversed when backward.
+// Like postgres, null values are sorted after any other value.
+func after(keys []cursorKey, values []any, backward bool) clause.Expression {
+	var ors []clause.Expression
+	for i, key := range keys {
+		var ands []clause.Expression
+		for j := 0; j < i; j++ {
+			ands = append(ands, clause.Eq{Column: keys[j].column, Value: values[j]})
+		}
+
+		desc := key.desc != backward
+		switch {
+		case values[i] == nil && desc:
+			ands = append(ands, clause.Neq{Column: key.column, Value: nil})
+		case values[i] == nil:
+			// nothing is after null
+			continue
+		case desc:
+			ands = append(ands, clause.Lt{Column: key.column, Value: values[i]})
+		case !key.nullable:
+			ands = append(ands, clause.Gt{Column: key.column, Value: values[i]})
+		default:
+			ands = append(ands, clause.Or(clause.Gt{Column: key.column, Value: values[i]}, clause.Eq{Column: key.column, Value: nil}))
+		}
+		ors = append(ors, clause.And(ands...))
+	}
+
+	switch len(ors) {
+	case 0:
+		return clause.Expr{SQL: "1 = 0"}
+	case 1:
+		return ors[0]
+	}
+	return clause.Or(ors...)
+}
+
+func encodeCursor(ctx context.Context, keys []cursorKey, row reflect.Value, backward bool) string {
+	cur := cursor{Backward: backward}
+	for _, key := range keys {
+		value, _ := key.field.ValueOf(ctx, row)
+		bs, _ := json.Marshal(value)
+		cur.Values = append(cur.Values, bs)
+	}
+	bs, _ := json.Marshal(cur)
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+func decodeCursor(s string, keys []cursorKey) (cursor, []any, error) {
+	var cur cursor
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return cur, nil, err
+	}
+	if err = json.Unmarshal(bs, &cur); err != nil {
+		return cur, nil, err
+	}
+	if len(cur.Values) != len(keys) {
+		return cur, nil, fmt.Errorf("cursor has %d values, expected %d", len(cur.Values), len(keys))
+	}
+
+	values := make([]any, len(keys))
+	for i, key := range keys {
+		ptr := reflect.New(key.field.FieldType)
+		if err = json.Unmarshal(cur.Values[i], ptr.Interface()); err != nil {
+			return cur, nil, err
+		}
+		value := ptr.Elem()
+		for value.Kind() == reflect.Ptr && !value.IsNil() {
+			value = value.Elem()
+		}
+		if value.Kind() != reflect.Ptr {
+			values[i] = value.Interface()
+		}
+	}
+	return cur, values, nil
+}
@@ -0,0 +1,326 @@
+package base
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-chi/chi"
+	"github.com/gorilla/schema"
+	"github.com/rs/xid"
+	"github.com/tpp/msf/external-adapter/blob"
+	"github.com/tpp/msf/shared/context"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseType the sources of the request decoded by Parse, combined as a bitmask e.g. ParseTypePath | ParseTypeJSON.
+// The later sources take precedence: query, form, multipart, JSON or YAML then path params.
+type ParseType uint8
+
+const (
+	// ParseTypeQuery the query string, by schema tag
+	ParseTypeQuery ParseType = 1 << iota
+	// ParseTypePostForm the url encoded body, by schema tag
+	ParseTypePostForm
+	// ParseTypeJSON the JSON body, by json tag
+	ParseTypeJSON
+	// ParseTypeMultipartForm the multipart body, the values by schema tag and the files by file tag, see File
+	ParseTypeMultipartForm
+	// ParseTypePath the chi path params, by schema tag, the params without field are ignored
+	ParseTypePath
+	// ParseTypeYAML the YAML body, by yaml tag, the unknown fields are errors. JSON being YAML, the JSON bodies
+	// are decoded as well
+	ParseTypeYAML
+
+	// ParseTypeNone nothing is parsed, only the context of the request is returned
+	ParseTypeNone ParseType = 0
+	// ParseTypeParam the query string along with the url encoded body
+	ParseTypeParam = ParseTypeQuery | ParseTypePostForm
+)
+
+const (
+	// defaultMaxBodySize the limit of the bodies without file, in MB
+	defaultMaxBodySize = 1
+	// defaultMaxUploadSize the limit of the multipart bodies, in MB
+	defaultMaxUploadSize = 32
+)
+
+var (
+	decoder = schema.NewDecoder()
+	// pathDecoder the handlers may read the path params on their own, they are not all bound to the request
+	pathDecoder = schema.NewDecoder()
+
+	errBodyTooLarge = errors.New("request body too large")
+	fileExtPattern  = regexp.MustCompile(`^\.[a-z0-9]{1,10}$`)
+	fileType        = reflect.TypeOf(&File{})
+)
+
+func init() {
+	pathDecoder.IgnoreUnknownKeys(true)
+}
+
+// File a file part of a multipart request, Parse streams it into the blob store under Key and binds it to the
+// field tagged by the name of the part, e.g. Avatar *base.File `file:"avatar"` or Docs []*base.File `file:"docs"`.
+// The blobs are left to the handler once parsed, it deletes them when it rejects the request.
+type File struct {
+	// Key the key of the blob, prefixed by the name of the part e.g. avatar/cl0s3m1f8k9f8k9f8k9g.png
+	Key string
+	// Name the base name of the file on the client
+	Name string
+	// ContentType sniffed from the content, the one declared by the client is not trusted
+	ContentType string
+	Size        int64
+	// Checksum the hex encoded sha256 of the content
+	Checksum string
+}
+
+// Parse decode the sources of parseType into out, the body is limited to service.max_body_size MB, or to
+// service.max_upload_size MB when it is multipart, see SetBodyLimits
+func (h *httpHandler) Parse(r *http.Request, out any, parseType ParseType) (context.Context, error) {
+	var ctx = context.FromBaseContext(r.Context())
+	if parseType == ParseTypeNone {
+		return ctx, nil
+	}
+
+	maxSize := sizeLimit(&maxBodySize, defaultMaxBodySize)
+	if parseType&ParseTypeMultipartForm != 0 {
+		maxSize = sizeLimit(&maxUploadSize, defaultMaxUploadSize)
+	}
+	r.Body = &limitedBody{ReadCloser: r.Body, remaining: maxSize}
+
+	var err error
+	values := url.Values{}
+	if parseType&ParseTypeQuery != 0 {
+		addValues(values, r.URL.Query())
+	}
+	if parseType&ParseTypePostForm != 0 {
+		if err = r.ParseForm(); err != nil {
+			return ctx, h.parseError(ctx, err, "could not parse form")
+		}
+		addValues(values, r.PostForm)
+	}
+	if parseType&ParseTypeMultipartForm != 0 {
+		if err = h.parseMultipart(ctx, r, out, values); err != nil {
+			return ctx, h.parseError(ctx, err, "could not parse multipart form")
+		}
+	}
+	if parseType&(ParseTypeQuery|ParseTypePostForm|ParseTypeMultipartForm) != 0 {
+		if err = decoder.Decode(out, values); err != nil {
+			return ctx, h.parseError(ctx, err, "could not decode form data")
+		}
+	}
+
+	if parseType&ParseTypeJSON != 0 {
+		defer r.Body.Close()
+		if err = json.NewDecoder(r.Body).Decode(out); err != nil {
+			return ctx, h.parseError(ctx, err, "could not decode JSON request body")
+		}
+	}
+
+	if parseType&ParseTypeYAML != 0 {
+		defer r.Body.Close()
+		dec := yaml.NewDecoder(r.Body)
+		dec.KnownFields(true)
+		if err = dec.Decode(out); err != nil {
+			return ctx, h.parseError(ctx, err, "could not decode YAML request body")
+		}
+	}
+
+	if parseType&ParseTypePath != 0 {
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			params := url.Values{}
+			for i, key := range rctx.URLParams.Keys {
+				params.Set(key, rctx.URLParams.Values[i])
+			}
+			if err = pathDecoder.Decode(out, params); err != nil {
+				return ctx, h.parseError(ctx, err, "could not decode path params")
+			}
+		}
+	}
+	return ctx, nil
+}
+
+func (h *httpHandler) parseError(ctx context.Context, err error, msg string) error {
+	var apiErrors APIErrors
+	if errors.As(err, &apiErrors) {
+		return err
+	}
+	if errors.Is(err, errBodyTooLarge) {
+		return NewError(http.StatusRequestEntityTooLarge, CodeBodyTooLarge, "the request body is too large").WithCause(err)
+	}
+	h.Error(ctx).Err(err).Msg(msg)
+	return NewBadRequestError(CodeMalformedRequest, "the request could not be parsed").WithCause(err)
+}
+
+// parseMultipart stream the parts of the body, the values are added to values and the files are stored then
+// bound to out. The files already stored are deleted when a later part fails.
+func (h *httpHandler) parseMultipart(ctx context.Context, r *http.Request, out any, values url.Values) (err error) {
+	mr, err := r.MultipartReader()
+	if err != nil {
+		return err
+	}
+
+	store := blob.GetBlobStoreInstance()
+	fields := fileFields(out)
+	var stored []string
+	defer func() {
+		if err == nil {
+			return
+		}
+		for _, key := range stored {
+			if deleteErr := store.Delete(ctx, key); deleteErr != nil {
+				h.Error(ctx).Err(deleteErr).Str("key", key).Msg("could not delete the blob of a rejected request")
+			}
+		}
+	}()
+
+	for {
+		var part *multipart.Part
+		if part, err = mr.NextPart(); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			var b []byte
+			if b, err = io.ReadAll(part); err != nil {
+				return err
+			}
+			values.Add(name, string(b))
+			continue
+		}
+
+		field, ok := fields[name]
+		if !ok {
+			return NewApiErrors(name, "unexpected file")
+		}
+		var file *File
+		if file, err = storeFile(ctx, store, name, part); err != nil {
+			return err
+		}
+		stored = append(stored, file.Key)
+		if field.Kind() == reflect.Slice {
+			field.Set(reflect.Append(field, reflect.ValueOf(file)))
+		} else {
+			field.Set(reflect.ValueOf(file))
+		}
+	}
+}
+
+// storeFile stream part into store, sniffing its content type and summing its content along the way
+func storeFile(ctx context.Context, store blob.BlobStore, name string, part *multipart.Part) (*File, error) {
+	ext := strings.ToLower(filepath.Ext(part.FileName()))
+	if !fileExtPattern.MatchString(ext) {
+		ext = ""
+	}
+	file := &File{
+		Key:  name + "/" + xid.New().String() + ext,
+		Name: filepath.Base(filepath.FromSlash(part.FileName())),
+	}
+
+	br := bufio.NewReaderSize(part, 512)
+	head, err := br.Peek(512)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	file.ContentType = http.DetectContentType(head)
+
+	hash := sha256.New()
+	counter := &countWriter{}
+	if err = store.Put(ctx, file.Key, io.TeeReader(br, io.MultiWriter(hash, counter)), file.ContentType); err != nil {
+		return nil, err
+	}
+	file.Size = counter.n
+	file.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return file, nil
+}
+
+// fileFields the *File and []*File fields of out by the name of their file tag
+func fileFields(out any) map[string]reflect.Value {
+	fields := map[string]reflect.Value{}
+	v := reflect.ValueOf(out)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fields
+	}
+	v = v.Elem()
+	for i := 0; i < v.NumField(); i++ {
+		sf := v.Type().Field(i)
+		name := sf.Tag.Get("file")
+		if name == "" || !sf.IsExported() {
+			continue
+		}
+		if sf.Type == fileType || (sf.Type.Kind() == reflect.Slice && sf.Type.Elem() == fileType) {
+			fields[name] = v.Field(i)
+		}
+	}
+	return fields
+}
+
+func addValues(dst, src url.Values) {
+	for key, vs := range src {
+		dst[key] = append(dst[key], vs...)
+	}
+}
+
+// the limits in MB of the request bodies, see SetBodyLimits
+var maxBodySize, maxUploadSize int64
+
+// SetBodyLimits set the limits in MB of the bodies and of the multipart bodies with files, the default limits apply
+// to the ones which are not positive
+func SetBodyLimits(bodyMB, uploadMB int64) {
+	atomic.StoreInt64(&maxBodySize, bodyMB)
+	atomic.StoreInt64(&maxUploadSize, uploadMB)
+}
+
+func sizeLimit(limit *int64, defaultMB int64) int64 {
+	mb := atomic.LoadInt64(limit)
+	if mb <= 0 {
+		mb = defaultMB
+	}
+	return mb << 20
+}
+
+// limitedBody fails the reads beyond the limit with errBodyTooLarge, rather than truncating the body
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, errBodyTooLarge
+	}
+	// one more byte tells an exact fit from an overflow
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	b.remaining -= int64(n)
+	if b.remaining < 0 {
+		return n + int(b.remaining), errBodyTooLarge
+	}
+	return n, err
+}
+
+type countWriter struct {
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
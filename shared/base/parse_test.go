@@ -0,0 +1,199 @@
+package base
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/external-adapter/blob"
+	"github.com/tpp/msf/shared/log"
+)
+
+type parseReq struct {
+	ID     int64    `schema:"id" json:"-"`
+	Expand string   `schema:"expand" json:"-"`
+	Name   string   `schema:"name" json:"name"`
+	Avatar *File    `schema:"-" json:"-" file:"avatar"`
+	Docs   []*File  `schema:"-" json:"-" file:"docs"`
+	Tags   []string `schema:"tags" json:"tags"`
+}
+
+func newMultipart(r *require.Assertions, build func(mw *multipart.Writer)) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	build(mw)
+	r.NoError(mw.Close())
+	return body, mw.FormDataContentType()
+}
+
+func TestHttpHandler_Parse(t *testing.T) {
+	pdf := []byte("%PDF-1.4 contract")
+	tests := []struct {
+		name      string
+		parseType ParseType
+		request   func(r *require.Assertions) *http.Request
+		expect    func(r *require.Assertions, out *parseReq, store *blob.MemoryStore, err error)
+	}{
+		{
+			name:      "path, query and JSON",
+			parseType: ParseTypePath | ParseTypeQuery | ParseTypeJSON,
+			request: func(r *require.Assertions) *http.Request {
+				return httptest.NewRequest(http.MethodPut, "/users/42?expand=roles", strings.NewReader(`{"name":"dung","tags":["a"]}`))
+			},
+			expect: func(r *require.Assertions, out *parseReq, _ *blob.MemoryStore, err error) {
+				r.NoError(err)
+				r.EqualValues(&parseReq{ID: 42, Expand: "roles", Name: "dung", Tags: []string{"a"}}, out)
+			},
+		},
+		{
+			name:      "path takes precedence",
+			parseType: ParseTypePath | ParseTypeQuery,
+			request: func(r *require.Assertions) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/users/42?id=7", nil)
+			},
+			expect: func(r *require.Assertions, out *parseReq, _ *blob.MemoryStore, err error) {
+				r.NoError(err)
+				r.EqualValues(42, out.ID)
+			},
+		},
+		{
+			name:      "multipart streams files",
+			parseType: ParseTypePath | ParseTypeMultipartForm,
+			request: func(r *require.Assertions) *http.Request {
+				body, contentType := newMultipart(r, func(mw *multipart.Writer) {
+					r.NoError(mw.WriteField("name", "dung"))
+					w, err := mw.CreateFormFile("avatar", "me.PNG")
+					r.NoError(err)
+					w.Write([]byte("\x89PNG\r\n\x1a\nimage"))
+					for _, name := range []string{"a.pdf", "../../b.pdf"} {
+						w, err = mw.CreateFormFile("docs", name)
+						r.NoError(err)
+						w.Write(pdf)
+					}
+				})
+				req := httptest.NewRequest(http.MethodPost, "/users/42", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			expect: func(r *require.Assertions, out *parseReq, store *blob.MemoryStore, err error) {
+				r.NoError(err)
+				r.EqualValues(42, out.ID)
+				r.EqualValues("dung", out.Name)
+				r.NotNil(out.Avatar)
+				r.EqualValues("image/png", out.Avatar.ContentType)
+				r.True(strings.HasPrefix(out.Avatar.Key, "avatar/"))
+				r.True(strings.HasSuffix(out.Avatar.Key, ".png"))
+				r.Len(out.Docs, 2)
+				sum := sha256.Sum256(pdf)
+				r.EqualValues("b.pdf", out.Docs[1].Name)
+				r.EqualValues("application/pdf", out.Docs[1].ContentType)
+				r.EqualValues(len(pdf), out.Docs[1].Size)
+				r.EqualValues(hex.EncodeToString(sum[:]), out.Docs[1].Checksum)
+				r.Len(store.Blobs(), 3)
+				r.EqualValues(pdf, store.Blobs()[out.Docs[0].Key].Data)
+			},
+		},
+		{
+			name:      "unexpected file deletes the stored ones",
+			parseType: ParseTypeMultipartForm,
+			request: func(r *require.Assertions) *http.Request {
+				body, contentType := newMultipart(r, func(mw *multipart.Writer) {
+					w, err := mw.CreateFormFile("avatar", "me.png")
+					r.NoError(err)
+					w.Write([]byte("image"))
+					w, err = mw.CreateFormFile("other", "x.png")
+					r.NoError(err)
+					w.Write([]byte("image"))
+				})
+				req := httptest.NewRequest(http.MethodPost, "/users", body)
+				req.Header.Set("Content-Type", contentType)
+				return req
+			},
+			expect: func(r *require.Assertions, _ *parseReq, store *blob.MemoryStore, err error) {
+				r.EqualValues(http.StatusBadRequest, AsError(err).Status)
+				r.EqualValues([]*APIError{{Field: "other", Message: "unexpected file"}}, AsError(err).Fields)
+				r.Empty(store.Blobs())
+			},
+		},
+		{
+			name:      "body too large",
+			parseType: ParseTypeJSON,
+			request: func(r *require.Assertions) *http.Request {
+				name := strings.Repeat("a", defaultMaxBodySize<<20)
+				return httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"`+name+`"}`))
+			},
+			expect: func(r *require.Assertions, _ *parseReq, _ *blob.MemoryStore, err error) {
+				r.EqualValues(http.StatusRequestEntityTooLarge, AsError(err).Status)
+				r.EqualValues(CodeBodyTooLarge, AsError(err).Code)
+			},
+		},
+		{
+			name:      "malformed JSON",
+			parseType: ParseTypeJSON,
+			request: func(r *require.Assertions) *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":`))
+			},
+			expect: func(r *require.Assertions, _ *parseReq, _ *blob.MemoryStore, err error) {
+				r.EqualValues(CodeMalformedRequest, AsError(err).Code)
+			},
+		},
+		{
+			name:      "YAML",
+			parseType: ParseTypeYAML,
+			request: func(r *require.Assertions) *http.Request {
+				return httptest.NewRequest(http.MethodPut, "/users", strings.NewReader("name: dung\ntags: [a, b]\n"))
+			},
+			expect: func(r *require.Assertions, out *parseReq, _ *blob.MemoryStore, err error) {
+				r.NoError(err)
+				r.EqualValues(&parseReq{Name: "dung", Tags: []string{"a", "b"}}, out)
+			},
+		},
+		{
+			name:      "unknown YAML field",
+			parseType: ParseTypeYAML,
+			request: func(r *require.Assertions) *http.Request {
+				return httptest.NewRequest(http.MethodPut, "/users", strings.NewReader("nickname: dung\n"))
+			},
+			expect: func(r *require.Assertions, _ *parseReq, _ *blob.MemoryStore, err error) {
+				r.EqualValues(CodeMalformedRequest, AsError(err).Code)
+			},
+		},
+		{
+			name:      "none",
+			parseType: ParseTypeNone,
+			request: func(r *require.Assertions) *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/users/42?name=dung", nil)
+			},
+			expect: func(r *require.Assertions, out *parseReq, _ *blob.MemoryStore, err error) {
+				r.NoError(err)
+				r.EqualValues(&parseReq{}, out)
+			},
+		},
+	}
+	h := &httpHandler{Logger: newBaseLogger(log.Logger)}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := require.New(t)
+			store := blob.New(&blob.Config{Kind: "memory"}).(*blob.MemoryStore)
+
+			var out parseReq
+			var err error
+			router := chi.NewRouter()
+			router.HandleFunc("/users/{id}", func(w http.ResponseWriter, req *http.Request) {
+				_, err = h.Parse(req, &out, tt.parseType)
+			})
+			router.HandleFunc("/users", func(w http.ResponseWriter, req *http.Request) {
+				_, err = h.Parse(req, &out, tt.parseType)
+			})
+			router.ServeHTTP(httptest.NewRecorder(), tt.request(r))
+			tt.expect(r, &out, store, err)
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package base
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/log"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem the body of an error response, see RFC 7807
+type Problem struct {
+	Type      string      `json:"type"`
+	Title     string      `json:"title"`
+	Status    int         `json:"status"`
+	Detail    string      `json:"detail,omitempty"`
+	Instance  string      `json:"instance,omitempty"`
+	Code      string      `json:"code"`
+	RequestID string      `json:"request_id,omitempty"`
+	Errors    []*APIError `json:"errors,omitempty"`
+}
+
+// HandlerFunc an http handler which returns its error rather than responding it, see Handle
+type HandlerFunc func(w http.ResponseWriter, r *http.Request) error
+
+// Handle adapt fn to http, the error it returns is responded by ResponseError
+func Handle(fn HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := fn(w, r); err != nil {
+			ResponseError(w, r, err)
+		}
+	}
+}
+
+// ResponseError respond err as an application/problem+json, internal errors are logged with their cause
+func ResponseError(w http.ResponseWriter, r *http.Request, err error) {
+	ctx := context.FromBaseContext(r.Context())
+	apiError := AsError(err)
+	if apiError.Status >= http.StatusInternalServerError {
+		tag(log.Logger.Error(), ctx).Err(err).Str("method", r.Method).Str("path", r.URL.Path).Msg("InternalServerError")
+	}
+
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(apiError.Status),
+		Status:    apiError.Status,
+		Detail:    apiError.Detail,
+		Instance:  r.URL.Path,
+		Code:      apiError.Code,
+		RequestID: ctx.ReqID(),
+		Errors:    apiError.Fields,
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.WriteHeader(apiError.Status)
+	b, _ := json.Marshal(problem)
+	w.Write(b)
+}
@@ -0,0 +1,76 @@
+package base
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+)
+
+func TestResponseError(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		expect func(r *require.Assertions, status int, problem Problem)
+	}{
+		{
+			name: "validation",
+			err:  NewApiErrors("email", "email already existed"),
+			expect: func(r *require.Assertions, status int, problem Problem) {
+				r.EqualValues(http.StatusBadRequest, status)
+				r.EqualValues(CodeValidationFailed, problem.Code)
+				r.EqualValues([]*APIError{{Field: "email", Message: "email already existed"}}, problem.Errors)
+			},
+		},
+		{
+			name: "not found",
+			err:  gorm.ErrRecordNotFound,
+			expect: func(r *require.Assertions, status int, problem Problem) {
+				r.EqualValues(http.StatusNotFound, status)
+				r.EqualValues(CodeNotFound, problem.Code)
+			},
+		},
+		{
+			name: "api error",
+			err:  NewForbiddenError("the user does not have the permission"),
+			expect: func(r *require.Assertions, status int, problem Problem) {
+				r.EqualValues(http.StatusForbidden, status)
+				r.EqualValues(CodeForbidden, problem.Code)
+				r.EqualValues("Forbidden", problem.Title)
+				r.EqualValues("the user does not have the permission", problem.Detail)
+			},
+		},
+		{
+			name: "internal",
+			err:  errors.New("pq: connection refused"),
+			expect: func(r *require.Assertions, status int, problem Problem) {
+				r.EqualValues(http.StatusInternalServerError, status)
+				r.EqualValues(CodeInternal, problem.Code)
+				r.NotContains(problem.Detail, "connection refused")
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion := require.New(t)
+			ctx := context.Background().WithReqID("req-1")
+			r := httptest.NewRequest(http.MethodGet, "/users/1", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			Handle(func(w http.ResponseWriter, r *http.Request) error { return tt.err })(w, r)
+
+			var problem Problem
+			assertion.EqualValues(problemContentType, w.Header().Get("Content-Type"))
+			assertion.NoError(json.Unmarshal(w.Body.Bytes(), &problem))
+			assertion.EqualValues(w.Code, problem.Status)
+			assertion.EqualValues("req-1", problem.RequestID)
+			assertion.EqualValues("/users/1", problem.Instance)
+			tt.expect(assertion, w.Code, problem)
+		})
+	}
+}
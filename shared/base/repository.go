@@ -1,28 +1,26 @@
 package base
 
 import (
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tpp/msf/shared/context"
 	"github.com/tpp/msf/shared/log"
 	"golang.org/x/exp/slices"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type Scope func(*gorm.DB) *gorm.DB
 
 var (
-	noneScope        Scope = func(db *gorm.DB) *gorm.DB { return db }
-	mapQueryOperator       = map[Operator]string{
-		OperatorEqual:              "=",
-		OperatorNotEqual:           "IS NOT",
-		OperatorLessThan:           "<",
-		OperatorLessThanOrEqual:    "<=",
-		OperatorGreaterThan:        ">",
-		OperatorGreaterThanOrEqual: ">=",
-		OperatorLike:               "like",
-	}
+	noneScope Scope = func(db *gorm.DB) *gorm.DB { return db }
+	// likeEscaper makes the wildcards of a like value match literally
+	likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+	dateLayouts = []string{"2006-01-02", time.RFC3339}
 )
 
 type Repository interface {
@@ -31,9 +29,27 @@ type Repository interface {
 	DB(ctx context.Context) *gorm.DB
 	// additional method helper for repository
 	Filter(filters []*Filter) Scope
+	// Paginate limit, offset and order the query by the sort spec, see ListParams.Sort
 	Paginate(limit int, offset int, sort string) Scope
 	RegisterActualKey(presentKey, actualKey string)
+	RegisterKeyType(presentKey string, keyType KeyType)
 	RegisterJoinScope(presentKey string, scopes ...Scope)
+	// RegisterSortKeys declare the keys accepted by the sort spec, id is always accepted
+	RegisterSortKeys(presentKeys ...string)
+	// RegisterFields declare the columns accepted by the fields param, id is always selected
+	RegisterFields(presentKeys ...string)
+	// RegisterExpansion declare a relation accepted by the expand param, columns are the ones its preload needs
+	RegisterExpansion(presentKey, preload string, columns ...string)
+	// RegisterDefaultExpand declare the expansions loaded when neither fields nor expand are requested
+	RegisterDefaultExpand(presentKeys ...string)
+	// Select select the fields and preload the expansions of a Fieldset along with columns
+	Select(fs *Fieldset, columns ...string) Scope
+	// Expands whether a Fieldset loads an expansion, e.g. to preload it with conditions
+	Expands(fs *Fieldset, presentKey string) bool
+	// FindPage run db with the filters, sort, fieldset and pagination of params into dest, see ListParams
+	FindPage(db *gorm.DB, params *ListParams, dest any, findScopes ...Scope) (*Page, error)
+	// BumpVersion increment the version of a row matching the version of an If-Match header, see IfMatch
+	BumpVersion(ctx context.Context, table string, id, version uint64) (uint64, error)
 }
 
 type repo struct {
@@ -41,73 +57,307 @@ type repo struct {
 	// additional helper for repository
 	mapJoinScopes map[string][]*Scope
 	mapActualKeys map[string]string
+	mapKeyTypes   map[string]KeyType
+	sortKeys      []string
+	fieldKeys     []string
+	mapExpansions map[string]expansion
+	defaultExpand []string
 }
 
 func (r *repo) RegisterActualKey(presentKey, actualKey string) {
 	r.mapActualKeys[presentKey] = actualKey
 }
 
+func (r *repo) RegisterKeyType(presentKey string, keyType KeyType) {
+	r.mapKeyTypes[presentKey] = keyType
+}
+
 func (r *repo) RegisterJoinScope(presentKey string, scopes ...Scope) {
 	r.mapJoinScopes[presentKey] = make([]*Scope, len(scopes))
 	for idx, scope := range scopes {
+		scope := scope
 		r.mapJoinScopes[presentKey][idx] = &scope
 	}
 }
 
+func (r *repo) RegisterSortKeys(presentKeys ...string) {
+	r.sortKeys = append(r.sortKeys, presentKeys...)
+}
+
+// joinScopes collect the join scopes of key which are not in joinScopes yet
+func (r *repo) joinScopes(joinScopes []*Scope, key string) []*Scope {
+	for _, scope := range r.mapJoinScopes[key] {
+		if scope != nil && !slices.Contains(joinScopes, scope) {
+			joinScopes = append(joinScopes, scope)
+		}
+	}
+	return joinScopes
+}
+
+// applyJoinScopes apply the join scopes, skipping the joins the statement already has,
+// so that Filter and Paginate can require the same join around a Count
+func applyJoinScopes(db *gorm.DB, joinScopes []*Scope) *gorm.DB {
+	if len(joinScopes) == 0 {
+		return db
+	}
+	for _, scopePtr := range joinScopes {
+		db = (*scopePtr)(db)
+	}
+
+	// gorm moves the joins into the FROM clause once a query has been built
+	joined := map[string]bool{}
+	if c, ok := db.Statement.Clauses["FROM"]; ok {
+		if from, ok := c.Expression.(clause.From); ok {
+			for _, join := range from.Joins {
+				if expr, ok := join.Expression.(clause.NamedExpr); ok {
+					joined[expr.SQL] = true
+				}
+			}
+		}
+	}
+	joins := db.Statement.Joins[:0]
+	for _, join := range db.Statement.Joins {
+		if len(join.Conds) == 0 && join.On == nil {
+			if joined[join.Name] {
+				continue
+			}
+			joined[join.Name] = true
+		}
+		joins = append(joins, join)
+	}
+	db.Statement.Joins = joins
+	return db
+}
+
+// Filter build the where conditions of filters, invalid values are added to the db errors as APIErrors
 func (r *repo) Filter(filters []*Filter) Scope {
 	if len(filters) == 0 {
 		return noneScope
 	}
 
-	var queryStr string
-	var queryFilters, values = []string{}, []any{}
+	var exprs []clause.Expression
+	var groups = map[string][]clause.Expression{}
+	var groupNames []string
+	var errs APIErrors
 
 	var joinScopes = make([]*Scope, 0)
 
-	for _, filter := range filters {
-		operator := mapQueryOperator[filter.Operator]
-		if operator == "" || filter.Key == "" {
+	for idx, filter := range filters {
+		if filter == nil || filter.Key == "" {
+			continue
+		}
+
+		expr, err := r.expression(filter)
+		if err != nil {
+			errs = append(errs, &APIError{Field: fmt.Sprintf("filters.%d.value", idx), Message: err.Error()})
+			continue
+		}
+
+		joinScopes = r.joinScopes(joinScopes, filter.Key)
+
+		if filter.Group == "" {
+			exprs = append(exprs, expr)
 			continue
 		}
+		if _, ok := groups[filter.Group]; !ok {
+			groupNames = append(groupNames, filter.Group)
+		}
+		groups[filter.Group] = append(groups[filter.Group], expr)
+	}
 
-		var value string
-		if operator == "like" {
-			value = fmt.Sprintf("%%%s%%", filter.Value)
+	for _, name := range groupNames {
+		// a single condition is not an OR group, gorm would join it with OR to the previous one
+		if len(groups[name]) == 1 {
+			exprs = append(exprs, groups[name][0])
 		} else {
-			value = filter.Value
+			exprs = append(exprs, clause.Or(groups[name]...))
 		}
+	}
 
-		if len(r.mapJoinScopes[filter.Key]) > 0 {
-			for _, scope := range r.mapJoinScopes[filter.Key] {
-				if scope != nil && !slices.Contains(joinScopes, scope) {
-					joinScopes = append(joinScopes, scope)
-				}
-			}
+	return func(db *gorm.DB) *gorm.DB {
+		if len(errs) > 0 {
+			db.AddError(errs)
+			return db
+		}
+		db = applyJoinScopes(db, joinScopes)
+		if len(exprs) == 0 {
+			return db
 		}
+		return db.Clauses(clause.Where{Exprs: exprs})
+	}
+}
+
+// column the quoted column of a key, table.name keys are split, others belong to the queried table
+func (r *repo) column(key string) clause.Column {
+	if r.mapActualKeys[key] != "" {
+		key = r.mapActualKeys[key]
+	}
+	if idx := strings.LastIndex(key, "."); idx > 0 {
+		return clause.Column{Table: key[:idx], Name: key[idx+1:]}
+	}
+	return clause.Column{Table: clause.CurrentTable, Name: key}
+}
+
+func (r *repo) expression(filter *Filter) (clause.Expression, error) {
+	column := r.column(filter.Key)
 
-		key := filter.Key
-		if r.mapActualKeys[key] != "" {
-			key = r.mapActualKeys[key]
+	switch filter.Operator {
+	case OperatorLike, OperatorILike, OperatorStartsWith:
+		if keyType := r.mapKeyTypes[filter.Key]; keyType != "" && keyType != KeyTypeString {
+			return nil, fmt.Errorf("operator '%s' is not supported for %s values", filter.Operator, keyType)
+		}
+		pattern := likeEscaper.Replace(filter.Value) + "%"
+		if filter.Operator != OperatorStartsWith {
+			pattern = "%" + pattern
+		}
+		if filter.Operator == OperatorILike {
+			return clause.Expr{SQL: "? ILIKE ?", Vars: []any{column, pattern}}, nil
+		}
+		return clause.Like{Column: column, Value: pattern}, nil
+	case OperatorIn, OperatorNotIn:
+		values, err := r.parseValues(filter.Key, filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		if filter.Operator == OperatorNotIn {
+			return clause.Not(clause.IN{Column: column, Values: values}), nil
+		}
+		return clause.IN{Column: column, Values: values}, nil
+	case OperatorBetween:
+		values, err := r.parseValues(filter.Key, filter.Value)
+		if err != nil {
+			return nil, err
+		}
+		if len(values) != 2 {
+			return nil, errors.New("This field must be two values separated by a comma")
+		}
+		return clause.Expr{SQL: "? BETWEEN ? AND ?", Vars: []any{column, values[0], values[1]}}, nil
+	case OperatorIsNull:
+		isNull, err := strconv.ParseBool(filter.Value)
+		if err != nil {
+			return nil, errors.New("This field must be true or false")
 		}
+		if isNull {
+			return clause.Eq{Column: column, Value: nil}, nil
+		}
+		return clause.Neq{Column: column, Value: nil}, nil
+	}
 
-		queryFilters = append(queryFilters, fmt.Sprintf("%s %s ?", key, operator))
-		values = append(values, value)
+	value, err := r.parseValue(filter.Key, filter.Value)
+	if err != nil {
+		return nil, err
 	}
 
-	queryStr = strings.Join(queryFilters, " AND ")
+	switch filter.Operator {
+	case OperatorEqual:
+		return clause.Eq{Column: column, Value: value}, nil
+	case OperatorNotEqual:
+		return clause.Neq{Column: column, Value: value}, nil
+	case OperatorLessThan:
+		return clause.Lt{Column: column, Value: value}, nil
+	case OperatorLessThanOrEqual:
+		return clause.Lte{Column: column, Value: value}, nil
+	case OperatorGreaterThan:
+		return clause.Gt{Column: column, Value: value}, nil
+	case OperatorGreaterThanOrEqual:
+		return clause.Gte{Column: column, Value: value}, nil
+	}
+	return nil, fmt.Errorf("not support for operator '%s'", filter.Operator)
+}
 
-	return func(db *gorm.DB) *gorm.DB {
-		for _, scopePtr := range joinScopes {
-			db = (*scopePtr)(db)
+// parseValue convert value into the type registered for key
+func (r *repo) parseValue(key, value string) (any, error) {
+	switch r.mapKeyTypes[key] {
+	case KeyTypeNumber:
+		if i, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return i, nil
 		}
-		return db.Where(queryStr, values...)
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a number", value)
+		}
+		return f, nil
+	case KeyTypeBool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("'%s' is not a boolean", value)
+		}
+		return b, nil
+	case KeyTypeDate:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("'%s' is not a date, use 2006-01-02 or RFC3339", value)
 	}
+	return value, nil
+}
+
+func (r *repo) parseValues(key, value string) ([]any, error) {
+	var values []any
+	for _, v := range strings.Split(value, ",") {
+		parsed, err := r.parseValue(key, strings.TrimSpace(v))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, parsed)
+	}
+	return values, nil
 }
 
 func (r *repo) Paginate(limit int, offset int, sort string) Scope {
+	orderBy, joinScopes, err := r.orderBy(sort)
 	return func(db *gorm.DB) *gorm.DB {
-		return db.Offset(offset).Limit(limit).Order(sort)
+		if err != nil {
+			db.AddError(err)
+			return db
+		}
+		db = applyJoinScopes(db, joinScopes)
+		if len(orderBy.Columns) > 0 {
+			db = db.Clauses(orderBy)
+		}
+		return db.Offset(offset).Limit(limit)
+	}
+}
+
+type sortKey struct {
+	key  string
+	desc bool
+}
+
+// parseSort parse the sort spec, comma separated keys prefixed by - for a descending order
+func (r *repo) parseSort(sort string) ([]sortKey, error) {
+	var sortKeys []sortKey
+	if strings.TrimSpace(sort) == "" {
+		return sortKeys, nil
+	}
+
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		desc := strings.HasPrefix(key, "-")
+		key = strings.TrimPrefix(key, "-")
+		if key != "id" && !slices.Contains(r.sortKeys, key) {
+			return nil, NewApiErrors("sort", fmt.Sprintf("not support for value '%s'", key))
+		}
+		sortKeys = append(sortKeys, sortKey{key: key, desc: desc})
+	}
+	return sortKeys, nil
+}
+
+func (r *repo) orderBy(sort string) (clause.OrderBy, []*Scope, error) {
+	var orderBy clause.OrderBy
+	var joinScopes = make([]*Scope, 0)
+
+	sortKeys, err := r.parseSort(sort)
+	if err != nil {
+		return orderBy, nil, err
+	}
+	for _, sortKey := range sortKeys {
+		joinScopes = r.joinScopes(joinScopes, sortKey.key)
+		orderBy.Columns = append(orderBy.Columns, clause.OrderByColumn{Column: r.column(sortKey.key), Desc: sortKey.desc})
 	}
+	return orderBy, joinScopes, nil
 }
 
 func (r *repo) DB(ctx context.Context) *gorm.DB {
@@ -119,5 +369,7 @@ func NewBaseRepository(repositoryName string) Repository {
 		Logger:        newBaseLogger(log.Logger.With().Str("layer", fmt.Sprintf("repository:%s", repositoryName)).Logger()),
 		mapActualKeys: map[string]string{},
 		mapJoinScopes: map[string][]*Scope{},
+		mapKeyTypes:   map[string]KeyType{},
+		mapExpansions: map[string]expansion{},
 	}
 }
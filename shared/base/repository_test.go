@@ -0,0 +1,426 @@
+package base
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/utils"
+	"gorm.io/gorm"
+)
+
+func TestRepository_Filter(t *testing.T) {
+	gDB, _, cnl, err := utils.NewDBMock()
+	require.NoError(t, err)
+	defer cnl()
+
+	r := NewBaseRepository("test")
+	r.RegisterActualKey("org_name", "orgs.name")
+	r.RegisterKeyType("role_id", KeyTypeNumber)
+	r.RegisterKeyType("status", KeyTypeBool)
+	r.RegisterKeyType("last_login", KeyTypeDate)
+	r.RegisterJoinScope("org_name", func(d *gorm.DB) *gorm.DB { return d.Joins(`INNER JOIN "orgs" ON "org_id" = "orgs"."id"`) })
+	r.RegisterSortKeys("full_name", "last_login", "org_name")
+
+	tests := []struct {
+		name    string
+		filters Filters
+		expect  func(r *require.Assertions, stmt *gorm.Statement)
+	}{
+		{
+			name: "comparisons",
+			filters: Filters{
+				{Key: "full_name", Operator: OperatorEqual, Value: "Hank"},
+				{Key: "email", Operator: OperatorNotEqual, Value: "hank@email.test"},
+				{Key: "status", Operator: OperatorEqual, Value: "true"},
+			},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users" WHERE "users"."full_name" = $1 AND "users"."email" <> $2 AND "users"."status" = $3`, stmt.SQL.String())
+				r.EqualValues([]any{"Hank", "hank@email.test", true}, stmt.Vars)
+			},
+		},
+		{
+			name: "like operators escape wildcards",
+			filters: Filters{
+				{Key: "full_name", Operator: OperatorLike, Value: "50%_off"},
+				{Key: "org_name", Operator: OperatorILike, Value: "tpp"},
+				{Key: "email", Operator: OperatorStartsWith, Value: "hank"},
+			},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users" INNER JOIN "orgs" ON "org_id" = "orgs"."id" WHERE "users"."full_name" LIKE $1 AND "orgs"."name" ILIKE $2 AND "users"."email" LIKE $3`, stmt.SQL.String())
+				r.EqualValues([]any{`%50\%\_off%`, "%tpp%", "hank%"}, stmt.Vars)
+			},
+		},
+		{
+			name: "lists, ranges and null",
+			filters: Filters{
+				{Key: "role_id", Operator: OperatorIn, Value: "1, 2"},
+				{Key: "role_id", Operator: OperatorNotIn, Value: "3,4"},
+				{Key: "last_login", Operator: OperatorBetween, Value: "2022-01-01,2022-02-01"},
+				{Key: "org_id", Operator: OperatorIsNull, Value: "false"},
+			},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users" WHERE "users"."role_id" IN ($1,$2) AND "users"."role_id" NOT IN ($3,$4) AND ("users"."last_login" BETWEEN $5 AND $6) AND "users"."org_id" IS NOT NULL`, stmt.SQL.String())
+				r.EqualValues([]any{int64(1), int64(2), int64(3), int64(4), time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC)}, stmt.Vars)
+			},
+		},
+		{
+			name: "or group",
+			filters: Filters{
+				{Key: "status", Operator: OperatorEqual, Value: "true"},
+				{Key: "full_name", Operator: OperatorILike, Value: "hank", Group: "q"},
+				{Key: "email", Operator: OperatorILike, Value: "hank", Group: "q"},
+				{Key: "role_id", Operator: OperatorEqual, Value: "1", Group: "single"},
+			},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users" WHERE "users"."status" = $1 AND ("users"."full_name" ILIKE $2 OR "users"."email" ILIKE $3) AND "users"."role_id" = $4`, stmt.SQL.String())
+			},
+		},
+		{
+			name: "invalid typed values",
+			filters: Filters{
+				{Key: "role_id", Operator: OperatorEqual, Value: "one"},
+				{Key: "last_login", Operator: OperatorBetween, Value: "2022-01-01"},
+				{Key: "status", Operator: OperatorLike, Value: "true"},
+			},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				errs, ok := stmt.Error.(APIErrors)
+				r.True(ok)
+				r.Len(errs, 3)
+				r.EqualValues("filters.0.value", errs[0].Field)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dest []map[string]any
+			stmt := gDB.Session(&gorm.Session{DryRun: true}).
+				Table("users").
+				Scopes(r.Filter(tt.filters)).
+				Find(&dest).Statement
+			tt.expect(require.New(t), stmt)
+		})
+	}
+}
+
+func TestRepository_Paginate(t *testing.T) {
+	gDB, _, cnl, err := utils.NewDBMock()
+	require.NoError(t, err)
+	defer cnl()
+
+	r := NewBaseRepository("test")
+	r.RegisterActualKey("org_name", "orgs.name")
+	r.RegisterJoinScope("org_name", func(d *gorm.DB) *gorm.DB { return d.Joins(`INNER JOIN "orgs" ON "org_id" = "orgs"."id"`) })
+	r.RegisterSortKeys("full_name", "last_login", "org_name")
+
+	tests := []struct {
+		name    string
+		sort    string
+		filters Filters
+		expect  func(r *require.Assertions, stmt *gorm.Statement)
+	}{
+		{
+			name: "multi column",
+			sort: "-last_login, full_name",
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users" ORDER BY "users"."last_login" DESC,"users"."full_name" LIMIT 10 OFFSET 20`, stmt.SQL.String())
+			},
+		},
+		{
+			name: "no sort",
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users" LIMIT 10 OFFSET 20`, stmt.SQL.String())
+			},
+		},
+		{
+			name:    "join shared with filter",
+			sort:    "org_name,-id",
+			filters: Filters{{Key: "org_name", Operator: OperatorEqual, Value: "tpp"}},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT "users"."id" FROM "users" INNER JOIN "orgs" ON "org_id" = "orgs"."id" WHERE "orgs"."name" = $1 ORDER BY "orgs"."name","users"."id" DESC LIMIT 10 OFFSET 20`, stmt.SQL.String())
+			},
+		},
+		{
+			name: "unknown key",
+			sort: "full_name,password",
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				errs, ok := stmt.Error.(APIErrors)
+				r.True(ok)
+				r.EqualValues("sort", errs[0].Field)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var total int64
+			var dest []map[string]any
+			db := gDB.Session(&gorm.Session{DryRun: true}).Table("users")
+			if len(tt.filters) > 0 {
+				// the filter joins are moved into the FROM clause by the count query
+				db = db.Select(`"users"."id"`).Scopes(r.Filter(tt.filters)).Count(&total)
+				// a dry run keeps the built sql
+				db.Statement.SQL.Reset()
+				db.Statement.Vars = nil
+			}
+			stmt := db.Scopes(r.Paginate(10, 20, tt.sort)).Find(&dest).Statement
+			tt.expect(require.New(t), stmt)
+		})
+	}
+}
+
+type cursorUser struct {
+	ID       uint64
+	FullName string
+}
+
+func (cursorUser) TableName() string {
+	return "users"
+}
+
+func TestRepository_FindPage(t *testing.T) {
+	gDB, mock, cnl, err := utils.NewDBMock()
+	require.NoError(t, err)
+	defer cnl()
+
+	r := NewBaseRepository("test")
+	r.RegisterSortKeys("full_name", "org_name")
+	r.RegisterActualKey("org_name", "orgs.name")
+
+	columns := []string{"id", "full_name"}
+	find := func(params *ListParams) ([]cursorUser, *Page, error) {
+		var users []cursorUser
+		page, err := r.FindPage(gDB.Model(&cursorUser{}), params, &users)
+		return users, page, err
+	}
+
+	t.Run("offset", func(t *testing.T) {
+		mock.ExpectPrepare(`SELECT count(*) FROM "users"`).ExpectQuery().WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectPrepare(`SELECT * FROM "users" ORDER BY "users"."full_name" LIMIT 2 OFFSET 2`).ExpectQuery().WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(3, "c"),
+		)
+		users, page, err := find(&ListParams{Limit: 2, Offset: 1, Sort: "full_name"})
+		require.NoError(t, err)
+		require.Len(t, users, 1)
+		require.EqualValues(t, 3, *page.Total)
+		require.Empty(t, page.NextCursor)
+	})
+
+	var next string
+	t.Run("first page", func(t *testing.T) {
+		mock.ExpectPrepare(`SELECT * FROM "users" ORDER BY "users"."full_name","users"."id" LIMIT 3`).ExpectQuery().WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(1, "a").AddRow(2, "b").AddRow(3, "c"),
+		)
+		users, page, err := find(&ListParams{Limit: 2, Mode: PageModeCursor, Sort: "full_name"})
+		require.NoError(t, err)
+		require.EqualValues(t, []cursorUser{{1, "a"}, {2, "b"}}, users)
+		require.Nil(t, page.Total)
+		require.NotEmpty(t, page.NextCursor)
+		require.Empty(t, page.PrevCursor)
+		next = page.NextCursor
+	})
+
+	var prev string
+	t.Run("last page", func(t *testing.T) {
+		// the count statement was prepared by the offset page
+		mock.ExpectQuery(`SELECT count(*) FROM "users"`).WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+		mock.ExpectPrepare(`SELECT * FROM "users" WHERE ("users"."full_name" > $1 OR ("users"."full_name" = $2 AND "users"."id" > $3)) ORDER BY "users"."full_name","users"."id" LIMIT 3`).
+			ExpectQuery().WithArgs("b", "b", 2).WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(3, "c"),
+		)
+		users, page, err := find(&ListParams{Limit: 2, Cursor: next, WithTotal: true, Sort: "full_name"})
+		require.NoError(t, err)
+		require.EqualValues(t, []cursorUser{{3, "c"}}, users)
+		require.EqualValues(t, 3, *page.Total)
+		require.Empty(t, page.NextCursor)
+		require.NotEmpty(t, page.PrevCursor)
+		prev = page.PrevCursor
+	})
+
+	t.Run("previous page", func(t *testing.T) {
+		mock.ExpectPrepare(`SELECT * FROM "users" WHERE ("users"."full_name" < $1 OR ("users"."full_name" = $2 AND "users"."id" < $3)) ORDER BY "users"."full_name" DESC,"users"."id" DESC LIMIT 3`).
+			ExpectQuery().WithArgs("c", "c", 3).WillReturnRows(
+			sqlmock.NewRows(columns).AddRow(2, "b").AddRow(1, "a"),
+		)
+		users, page, err := find(&ListParams{Limit: 2, Cursor: prev, Sort: "full_name"})
+		require.NoError(t, err)
+		require.EqualValues(t, []cursorUser{{1, "a"}, {2, "b"}}, users)
+		require.NotEmpty(t, page.NextCursor)
+		require.Empty(t, page.PrevCursor)
+	})
+
+	t.Run("invalid cursor", func(t *testing.T) {
+		_, _, err := find(&ListParams{Limit: 2, Cursor: "bm90IGEgY3Vyc29y", Sort: "full_name"})
+		errs, ok := err.(APIErrors)
+		require.True(t, ok)
+		require.EqualValues(t, "cursor", errs[0].Field)
+	})
+
+	t.Run("joined sort key", func(t *testing.T) {
+		_, _, err := find(&ListParams{Limit: 2, Mode: PageModeCursor, Sort: "org_name"})
+		errs, ok := err.(APIErrors)
+		require.True(t, ok)
+		require.EqualValues(t, "sort", errs[0].Field)
+	})
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type fieldsetUser struct {
+	ID       uint64
+	FullName string
+	OrgID    uint64
+	Org      *fieldsetOrg
+}
+
+type fieldsetOrg struct {
+	ID   uint64
+	Name string
+}
+
+func (fieldsetUser) TableName() string {
+	return "users"
+}
+
+func (fieldsetOrg) TableName() string {
+	return "orgs"
+}
+
+func TestRepository_Select(t *testing.T) {
+	gDB, _, cnl, err := utils.NewDBMock()
+	require.NoError(t, err)
+	defer cnl()
+
+	r := NewBaseRepository("test")
+	r.RegisterFields("full_name", "email")
+	r.RegisterExpansion("org", "Org", "org_id")
+	r.RegisterDefaultExpand("org")
+
+	tests := []struct {
+		name     string
+		fieldset Fieldset
+		expect   func(r *require.Assertions, stmt *gorm.Statement)
+	}{
+		{
+			name: "default",
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT * FROM "users"`, stmt.SQL.String())
+				r.Contains(stmt.Preloads, "Org")
+			},
+		},
+		{
+			name:     "fields without expansions",
+			fieldset: Fieldset{Fields: "full_name, email"},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT "users"."id","users"."full_name","users"."email" FROM "users"`, stmt.SQL.String())
+				r.Empty(stmt.Preloads)
+			},
+		},
+		{
+			name:     "fields with expansion",
+			fieldset: Fieldset{Fields: "full_name", Expand: "org"},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				r.NoError(stmt.Error)
+				r.EqualValues(`SELECT "users"."id","users"."full_name","users"."org_id" FROM "users"`, stmt.SQL.String())
+				r.Contains(stmt.Preloads, "Org")
+			},
+		},
+		{
+			name:     "unknown field",
+			fieldset: Fieldset{Fields: "password"},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				errs, ok := stmt.Error.(APIErrors)
+				r.True(ok)
+				r.EqualValues("fields", errs[0].Field)
+			},
+		},
+		{
+			name:     "unknown expansion",
+			fieldset: Fieldset{Expand: "roles"},
+			expect: func(r *require.Assertions, stmt *gorm.Statement) {
+				errs, ok := stmt.Error.(APIErrors)
+				r.True(ok)
+				r.EqualValues("expand", errs[0].Field)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var dest []fieldsetUser
+			stmt := gDB.Session(&gorm.Session{DryRun: true}).Scopes(r.Select(&tt.fieldset)).Find(&dest).Statement
+			tt.expect(require.New(t), stmt)
+		})
+	}
+}
+
+func TestProjection_Project(t *testing.T) {
+	type permission struct {
+		ID   uint64 `json:"id"`
+		Name string `json:"name"`
+	}
+	type role struct {
+		ID          uint64        `json:"id"`
+		Permissions []*permission `json:"permissions"`
+	}
+	type user struct {
+		ID       uint64  `json:"id"`
+		FullName string  `json:"full_name"`
+		Status   bool    `json:"status"`
+		Roles    []*role `json:"roles"`
+		Org      *struct {
+			ID uint64 `json:"id"`
+		} `json:"orgs"`
+		Logins int `json:"logins"`
+	}
+	p := &Projection{
+		Relations: []string{"roles", "roles.permissions", "org"},
+		Computed:  []string{"logins"},
+		JSONKeys:  map[string]string{"org": "orgs"},
+	}
+	users := []*user{{ID: 18446744073709551615, FullName: "Hank", Roles: []*role{{ID: 1}}}}
+
+	tests := []struct {
+		name     string
+		fieldset Fieldset
+		expect   string
+	}{
+		{
+			name:   "default",
+			expect: `[{"id":18446744073709551615,"full_name":"Hank","status":false,"roles":[{"id":1,"permissions":null}],"orgs":null,"logins":0}]`,
+		},
+		{
+			name:     "fields without expansions",
+			fieldset: Fieldset{Fields: "full_name"},
+			expect:   `[{"full_name":"Hank","id":18446744073709551615,"logins":0}]`,
+		},
+		{
+			name:     "fields with nested expansion",
+			fieldset: Fieldset{Fields: "status", Expand: "roles.permissions"},
+			expect:   `[{"id":18446744073709551615,"logins":0,"roles":[{"id":1,"permissions":null}],"status":false}]`,
+		},
+		{
+			name:     "expansion without fields",
+			fieldset: Fieldset{Expand: "roles"},
+			expect:   `[{"full_name":"Hank","id":18446744073709551615,"logins":0,"roles":[{"id":1}],"status":false}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projected, err := p.Project(&tt.fieldset, users)
+			require.NoError(t, err)
+			raw, err := json.Marshal(projected)
+			require.NoError(t, err)
+			require.EqualValues(t, tt.expect, string(raw))
+		})
+	}
+}
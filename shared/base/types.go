@@ -2,7 +2,6 @@ package base
 
 import (
 	"encoding/json"
-	"fmt"
 )
 
 type Operator string
@@ -15,40 +14,99 @@ const (
 	OperatorGreaterThan        Operator = "gt"
 	OperatorGreaterThanOrEqual Operator = "ge"
 	OperatorLike               Operator = "like"
+	OperatorILike              Operator = "ilike"
+	OperatorStartsWith         Operator = "starts_with"
+	OperatorIn                 Operator = "in"
+	OperatorNotIn              Operator = "not_in"
+	OperatorBetween            Operator = "between"
+	OperatorIsNull             Operator = "is_null"
+)
+
+// KeyType type of the values accepted by a filter key, default KeyTypeString
+type KeyType string
+
+const (
+	KeyTypeString KeyType = "string"
+	KeyTypeNumber KeyType = "number"
+	KeyTypeBool   KeyType = "bool"
+	// KeyTypeDate accept 2006-01-02 or RFC3339
+	KeyTypeDate KeyType = "date"
 )
 
 type Filters = []*Filter
 
+// ListParams the common params of list endpoints,
+// Sort is comma separated keys prefixed by - for a descending order, e.g. -last_login,full_name.
+// Offset is a page index, in cursor mode it is ignored and pages are walked with the next and prev cursors
+// of the previous response, the total is only counted when WithTotal is set.
 type ListParams struct {
-	Limit   int     `form:"limit" schema:"limit" validate:"gt=0"`
-	Offset  int     `form:"offset" schema:"offset" validate:"gte=0"`
-	Sort    string  `form:"sort" schema:"sort" validate:""`
-	Filters Filters `form:"filters" schema:"filters" validate:"dive,required"`
+	Limit     int     `form:"limit" schema:"limit" validate:"gt=0,lte=100"`
+	Offset    int     `form:"offset" schema:"offset" validate:"gte=0"`
+	Sort      string  `form:"sort" schema:"sort" validate:""`
+	Filters   Filters `form:"filters" schema:"filters" validate:"dive,required"`
+	Mode      string  `form:"mode" schema:"mode" validate:"omitempty,oneof=offset cursor"`
+	Cursor    string  `form:"cursor" schema:"cursor"`
+	WithTotal bool    `form:"with_total" schema:"with_total"`
+	Fieldset
 
 	acceptedFilterKeys []string `form:"-" schema:"-"`
 }
 
+// Filter a condition on Key. in, not_in and between take comma separated values, is_null takes true or false.
+// Filters sharing the same Group are joined with OR, the groups and the ungrouped filters are joined with AND.
 type Filter struct {
 	Key      string   `form:"key" schema:"key" validate:"required"`
-	Operator Operator `form:"operator" schema:"operator" validate:"required,eq=eq|eq=lt|eq=gt|eq=ge|eq=le|eq=ne|eq=like"`
+	Operator Operator `form:"operator" schema:"operator" validate:"required,oneof=eq ne lt le gt ge like ilike starts_with in not_in between is_null"`
 	Value    string   `form:"value" schema:"value" validate:"required"`
+	Group    string   `form:"group" schema:"group"`
 }
 
 type Pagination struct {
-	Offset    int    `json:"offset"`
-	Limit     int    `json:"limit"`
-	Sort      string `json:"sort"`
-	Total     int64  `json:"total"`
-	TotalPage int64  `json:"total_page"`
-	List      any    `json:"list"`
+	Offset     int    `json:"offset"`
+	Limit      int    `json:"limit"`
+	Sort       string `json:"sort"`
+	Total      *int64 `json:"total,omitempty"`
+	TotalPage  *int64 `json:"total_page,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
+	List       any    `json:"list"`
+}
+
+// NewPagination the response of a list endpoint
+func NewPagination(lp *ListParams, page *Page, list any) *Pagination {
+	pagination := &Pagination{
+		Offset: lp.Offset,
+		Limit:  lp.Limit,
+		Sort:   lp.Sort,
+		List:   list,
+	}
+	if page == nil {
+		return pagination
+	}
+
+	pagination.NextCursor = page.NextCursor
+	pagination.PrevCursor = page.PrevCursor
+	if page.Total != nil {
+		totalPage := (*page.Total + int64(lp.Limit) - 1) / int64(lp.Limit)
+		pagination.Total = page.Total
+		pagination.TotalPage = &totalPage
+	}
+	return pagination
+}
+
+// IsCursorMode whether the list is walked with cursors rather than offsets
+func (lp *ListParams) IsCursorMode() bool {
+	return lp.Mode == PageModeCursor || lp.Cursor != ""
 }
 
 func (lp *ListParams) EnsureDefault() {
 
-	if lp.Limit < 10 {
-		lp.Limit = 10
+	if lp.Limit <= 0 {
+		lp.Limit = defaultLimit
+	}
+	if lp.Sort == "" {
+		lp.Sort = "-id"
 	}
-	lp.Sort = "id desc"
 
 	if lp.Offset < 0 {
 		lp.Offset = 0
@@ -87,42 +145,9 @@ func (lp *ListParams) IsValid() error {
 
 }
 
-var mapMsgValidationTag = map[string]func(val any, param string) string{
-	"required": func(val any, param string) string {
-		return "This field is required"
-	},
-	"email": func(val any, param string) string {
-		return "invalid email format"
-	},
-	"lt": func(val any, param string) string {
-		return fmt.Sprintf("This field value length must be less than %v", param)
-	},
-	"lte": func(val any, param string) string {
-		return fmt.Sprintf("This field value length must be less than or equal to %v", param)
-	},
-	"gt": func(val any, param string) string {
-		return fmt.Sprintf("This field value length must be greater than %v", param)
-	},
-	"gte": func(val any, param string) string {
-		return fmt.Sprintf("This field value length must be greater than or equal to %v", param)
-	},
-	"eq": func(val any, param string) string {
-		return fmt.Sprintf("This field must be equal to '%v'", param)
-	},
-	"ne": func(val any, param string) string {
-		return fmt.Sprintf("This field must not be equal to '%v'", param)
-	},
-	"oneoffield": func(val any, param string) string {
-		return fmt.Sprintf("not support for value '%v'", val)
-	},
-	"alphanum": func(val any, param string) string {
-		return "invalid alphanumeric format"
-	},
-}
-
 type APIError struct {
-	Field   string
-	Message string
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 type APIErrors []*APIError
@@ -23,15 +23,25 @@ func TestListParams_IsValid(t *testing.T) {
 				0,
 				[]*Filter{
 					{
-						"name",
-						OperatorEqual,
-						"dung",
+						Key:      "name",
+						Operator: OperatorEqual,
+						Value:    "dung",
 					},
 				},
 				[]string{"name", "age"},
 			},
 			false,
 		},
+		{
+			"limit above the maximum",
+			fields{
+				500,
+				0,
+				nil,
+				[]string{"name", "age"},
+			},
+			true,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
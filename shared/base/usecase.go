@@ -1,71 +1,80 @@
 package base
 
 import (
-	"bytes"
-	"embed"
 	"fmt"
-	"html/template"
 
 	"github.com/go-mail/mail/v2"
 	"github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/model"
 	"github.com/tpp/msf/shared/log"
+	"github.com/tpp/msf/shared/mailtemplate"
+	"github.com/tpp/msf/shared/metrics"
 )
 
 type Usecase interface {
 	Logger
 	// additional method helper for usecase
-	SendEmail(accessToken string, to string, templateFile string, subject string) error
+	SendEmail(to *model.User, name string, accessToken string, values map[string]string) error
+	PreviewEmail(name string, locale string) (*mailtemplate.Message, error)
+	EmailTemplates() []string
 }
 
 type usecase struct {
 	Logger
-	// additional helper for usecase
-	*mailer.Mailer
 }
 
-//go:embed templates/*
-var templateFS embed.FS
-
-type Repo struct {
-	Subject       string
-	ResetPassword string
-	ActiveUser    string
-	To            string
-	Map           map[string]string
-}
-
-func (m *usecase) SendEmail(accessToken string, to string, templateFile string, subject string) error {
-
-	tmpl, err := template.New("email").ParseFS(templateFS, "templates/"+templateFile)
+// SendEmail renders the message name in the user's preferred locale and sends it
+func (m *usecase) SendEmail(to *model.User, name string, accessToken string, values map[string]string) error {
+	// the instance is looked up on each message, it is replaced when the configuration is reloaded
+	mlr := mailer.GetMailInstance()
+	rendered, err := mlr.Templates.Render(name, to.Locale, mailtemplate.Data{
+		To:            to.Email,
+		Name:          to.FullName,
+		ResetPassword: mlr.Config.ResetPath + accessToken,
+		ActiveUser:    mlr.Config.ActivePath + accessToken,
+		RevokeSession: mlr.Config.RevokeSessionPath + accessToken,
+		Values:        values,
+	})
 	if err != nil {
+		metrics.CountEmail(name, "render_error")
 		return err
 	}
-	var body bytes.Buffer
-	newData := Repo{
-		ResetPassword: m.Config.ResetPath + accessToken,
-		ActiveUser:    m.Config.ActivePath + accessToken,
-		To:            to,
-		Subject:       subject,
-		//Map: map[string]any,
+
+	msg := mail.NewMessage()
+	msg.SetHeader("To", to.Email)
+	msg.SetHeader("Subject", rendered.Subject)
+	msg.SetHeader("From", mlr.Sender)
+	if rendered.Text != "" {
+		msg.SetBody("text/plain", rendered.Text)
+		msg.AddAlternative("text/html", rendered.HTML)
+	} else {
+		msg.SetBody("text/html", rendered.HTML)
 	}
-	err = tmpl.ExecuteTemplate(&body, templateFile, newData)
-	if err != nil {
+
+	if err = mlr.Send(msg); err != nil {
+		metrics.CountEmail(name, "send_error")
 		return err
 	}
-	msg := mail.NewMessage()
-	msg.SetHeader("To", to)
-	msg.SetHeader("Subject", subject)
-	msg.SetHeader("From", m.Sender)
-	msg.AddAlternative("text/html", body.String())
-	//msg.SetBody("text/html", "")
+	metrics.CountEmail(name, "sent")
+	return nil
+
+}
 
-	return m.Dialer.DialAndSend(msg)
+// PreviewEmail renders the message name with sample data
+func (m *usecase) PreviewEmail(name string, locale string) (*mailtemplate.Message, error) {
+	rendered, err := mailer.GetMailInstance().Templates.Render(name, locale, mailtemplate.SampleData(name))
+	if err == mailtemplate.ErrNotFound {
+		return nil, ErrorNotFound
+	}
+	return rendered, err
+}
 
+func (m *usecase) EmailTemplates() []string {
+	return mailer.GetMailInstance().Templates.Names()
 }
 
 func NewBaseUsecase(usecaseName string) Usecase {
 	return &usecase{
 		Logger: newBaseLogger(log.Logger.With().Str("layer", fmt.Sprintf("usecase:%s", usecaseName)).Logger()),
-		Mailer: mailer.GetMailInstance(),
 	}
 }
@@ -1,3 +0,0 @@
-package constant
-
-var Secret = []byte("1CgxTZkylgQYXu16fVQ8fkd_Kbw7h5XMITtNrEOqXFdzy6WTWQOKW7lc_DINetHOwIWyCZcQRdqUIxyN60gNkXoclS73Lwm8eCvbgOArMGwDyaWKC6Gv2cYkelW6ecLwTahr2NkM31FoOiZgTp6pKNcBUhI9YlD2np31iebDHgMcLKIf0N7Bv_U-yd8cDigJDSXpDbMVvwDl0aDCau3u4AW13rP-KyKQHIv63IQFPbZqlt4pDgsNRuthiPtkNO_taHxzOnonaffgmQB1YrDgETUtS9s8ok6ES5PUdip9BVqC4-473LpPNV02eKHJzEkbvtTZrOuZTdBXrbYvkKIR3Q")
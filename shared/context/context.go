@@ -18,6 +18,12 @@ type set interface {
 	WithRole(model.RoleName) Context
 	// WithReqID to save user's role
 	WithReqID(string) Context
+	// WithTokenID to save the id of the token used by the request
+	WithTokenID(string) Context
+	// WithImpersonator to save the staff member impersonating the user
+	WithImpersonator(*model.User) Context
+	// WithLocale to save the locale accepted by the request
+	WithLocale(string) Context
 }
 
 type get interface {
@@ -27,6 +33,20 @@ type get interface {
 	User() *model.User
 	// Role
 	Role() model.RoleName
+	// TokenID
+	TokenID() string
+	// Impersonator, nil unless the request is made on behalf of User
+	Impersonator() *model.User
+	// Actor the real user behind the request, the impersonator if any, otherwise User
+	Actor() *model.User
+	// Locale the locale of the user profile, otherwise the one accepted by the request
+	Locale() string
+}
+
+type hook interface {
+	// AfterCommit to run fn once the unit of work of the request is committed, right away outside of one,
+	// for the side effects which must not happen unless the data is durable, e.g. sending an email
+	AfterCommit(fn func())
 }
 
 // Context wrapped golang based context
@@ -34,6 +54,7 @@ type Context interface {
 	context.Context
 	set
 	get
+	hook
 }
 
 // CancelFunc wrapped from base context
@@ -42,12 +63,23 @@ type CancelFunc = context.CancelFunc
 type ctxKey struct{ value string }
 
 var (
-	ctxDBKey    = &ctxKey{"database transaction"}
-	ctxUserKey  = &ctxKey{"user"}
-	ctxRoleKey  = &ctxKey{"role"}
-	ctxReqIDKey = &ctxKey{"request id"}
+	ctxDBKey     = &ctxKey{"database transaction"}
+	ctxUserKey   = &ctxKey{"user"}
+	ctxRoleKey   = &ctxKey{"role"}
+	ctxReqIDKey  = &ctxKey{"request id"}
+	ctxTokenKey  = &ctxKey{"token id"}
+	ctxImpKey    = &ctxKey{"impersonator"}
+	ctxLocaleKey = &ctxKey{"locale"}
+	ctxHooksKey  = &ctxKey{"after commit hooks"}
 )
 
+// afterCommitHooks the hooks registered during an unit of work
+type afterCommitHooks struct {
+	fns []func()
+	// committed the hooks registered later run right away
+	committed bool
+}
+
 type appContext struct {
 	context.Context
 }
@@ -72,6 +104,29 @@ func (ctx *appContext) WithReqID(s string) Context {
 	return ctx
 }
 
+func (ctx *appContext) WithTokenID(s string) Context {
+	ctx.Context = context.WithValue(ctx.Context, ctxTokenKey, s)
+	return ctx
+}
+
+func (ctx *appContext) WithImpersonator(user *model.User) Context {
+	ctx.Context = context.WithValue(ctx.Context, ctxImpKey, user)
+	return ctx
+}
+
+func (ctx *appContext) WithLocale(locale string) Context {
+	ctx.Context = context.WithValue(ctx.Context, ctxLocaleKey, locale)
+	return ctx
+}
+
+func (ctx *appContext) AfterCommit(fn func()) {
+	if hooks, ok := ctx.Value(ctxHooksKey).(*afterCommitHooks); ok && !hooks.committed {
+		hooks.fns = append(hooks.fns, fn)
+		return
+	}
+	fn()
+}
+
 func valueFromCtx[V string | *model.User | model.RoleName | *gorm.DB](ctx context.Context, key *ctxKey) V {
 	v, _ := ctx.Value(key).(V)
 	return v
@@ -89,11 +144,51 @@ func (ctx *appContext) Role() model.RoleName {
 	return valueFromCtx[model.RoleName](ctx, ctxRoleKey)
 }
 
+func (ctx *appContext) TokenID() string {
+	return valueFromCtx[string](ctx, ctxTokenKey)
+}
+
+func (ctx *appContext) Impersonator() *model.User {
+	return valueFromCtx[*model.User](ctx, ctxImpKey)
+}
+
+func (ctx *appContext) Actor() *model.User {
+	if impersonator := ctx.Impersonator(); impersonator != nil {
+		return impersonator
+	}
+	return ctx.User()
+}
+
+func (ctx *appContext) Locale() string {
+	if user := ctx.User(); user != nil && user.Locale != "" {
+		return user.Locale
+	}
+	return valueFromCtx[string](ctx, ctxLocaleKey)
+}
+
 // DBTxFromContext get database from context
 func DBTxFromContext(ctx Context) *gorm.DB {
 	return valueFromCtx[*gorm.DB](ctx, ctxDBKey)
 }
 
+// WithUnitOfWork save tx as the database of ctx and hold its AfterCommit hooks until RunAfterCommit
+func WithUnitOfWork(ctx Context, tx *gorm.DB) Context {
+	return WithValue(ctx.WithDBTx(tx), ctxHooksKey, &afterCommitHooks{})
+}
+
+// RunAfterCommit run the AfterCommit hooks of the unit of work of ctx in their registration order, once
+func RunAfterCommit(ctx Context) {
+	hooks, ok := ctx.Value(ctxHooksKey).(*afterCommitHooks)
+	if !ok {
+		return
+	}
+	fns := hooks.fns
+	hooks.fns, hooks.committed = nil, true
+	for _, fn := range fns {
+		fn()
+	}
+}
+
 // FromBaseContext wrapping base context
 func FromBaseContext(ctx context.Context) Context {
 	switch v := ctx.(type) {
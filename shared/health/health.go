@@ -0,0 +1,138 @@
+// Package health checks the dependencies of the service for the readiness probes and the administrators
+package health
+
+import (
+	stdcontext "context"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tpp/msf/shared/context"
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+	// StatusShuttingDown the service is draining its requests, it must not be sent new ones
+	StatusShuttingDown = "shutting_down"
+
+	defaultTimeout = 2 * time.Second
+)
+
+// Check check a dependency, the detail is reported along with its status, e.g. the version of the schema
+type Check func(ctx context.Context) (detail string, err error)
+
+// Result the outcome of a check
+type Result struct {
+	Status string `json:"status"`
+	// Latency the duration of the check in milliseconds
+	Latency float64 `json:"latency_ms"`
+	Detail  string  `json:"detail,omitempty"`
+	Error   string  `json:"error,omitempty"`
+}
+
+// Report the outcome of the checks, the service is up when all of them are
+type Report struct {
+	Status string             `json:"status"`
+	Checks map[string]*Result `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+var (
+	mu     sync.RWMutex
+	checks []namedCheck
+	// timeout the nanoseconds a check may take, see SetTimeout
+	timeout      = int64(defaultTimeout)
+	shuttingDown int32
+)
+
+// Register add the check of the dependency name to the reports
+func Register(name string, check Check) {
+	mu.Lock()
+	defer mu.Unlock()
+	checks = append(checks, namedCheck{name: name, check: check})
+}
+
+// SetTimeout set the time a check may take before it is reported down, default: 2 seconds
+func SetTimeout(d time.Duration) {
+	if d <= 0 {
+		d = defaultTimeout
+	}
+	atomic.StoreInt64(&timeout, int64(d))
+}
+
+// SetShuttingDown report the service shutting down from now on, whatever its dependencies
+func SetShuttingDown() {
+	atomic.StoreInt32(&shuttingDown, 1)
+}
+
+func ShuttingDown() bool {
+	return atomic.LoadInt32(&shuttingDown) == 1
+}
+
+// Run run the checks concurrently, each of them for at most the timeout
+func Run(ctx context.Context) *Report {
+	mu.RLock()
+	named := append([]namedCheck{}, checks...)
+	mu.RUnlock()
+	sort.Slice(named, func(i, j int) bool { return named[i].name < named[j].name })
+
+	report := &Report{Status: StatusUp, Checks: make(map[string]*Result, len(named))}
+	results := make([]*Result, len(named))
+	var wg sync.WaitGroup
+	for i, c := range named {
+		wg.Add(1)
+		go func(i int, check Check) {
+			defer wg.Done()
+			results[i] = run(ctx, check)
+		}(i, c.check)
+	}
+	wg.Wait()
+
+	for i, c := range named {
+		report.Checks[c.name] = results[i]
+		if results[i].Status != StatusUp {
+			report.Status = StatusDown
+		}
+	}
+	if ShuttingDown() {
+		report.Status = StatusShuttingDown
+	}
+	return report
+}
+
+// run check within the timeout, a check ignoring the deadline of its context is reported down all the same
+func run(parent context.Context, check Check) *Result {
+	// context.WithTimeout would set the deadline on parent, shared by the checks running concurrently
+	deadline, cancel := stdcontext.WithTimeout(parent, time.Duration(atomic.LoadInt64(&timeout)))
+	defer cancel()
+	ctx := context.FromBaseContext(deadline)
+
+	type outcome struct {
+		detail string
+		err    error
+	}
+	done := make(chan outcome, 1)
+	start := time.Now()
+	go func() {
+		detail, err := check(ctx)
+		done <- outcome{detail: detail, err: err}
+	}()
+
+	var o outcome
+	select {
+	case o = <-done:
+	case <-ctx.Done():
+		o.err = ctx.Err()
+	}
+	result := &Result{Status: StatusUp, Latency: float64(time.Since(start).Microseconds()) / 1000, Detail: o.detail}
+	if o.err != nil {
+		result.Status, result.Error = StatusDown, o.err.Error()
+	}
+	return result
+}
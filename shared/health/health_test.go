@@ -0,0 +1,55 @@
+package health
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/context"
+)
+
+func TestRun(t *testing.T) {
+	checks = nil
+	atomic.StoreInt32(&shuttingDown, 0)
+	SetTimeout(20 * time.Millisecond)
+	defer SetTimeout(0)
+
+	Register("db", func(ctx context.Context) (string, error) {
+		return "", nil
+	})
+	Register("migrations", func(ctx context.Context) (string, error) {
+		return "3.2", nil
+	})
+	report := Run(context.Background())
+	require.EqualValues(t, StatusUp, report.Status)
+	require.Len(t, report.Checks, 2)
+	require.EqualValues(t, StatusUp, report.Checks["db"].Status)
+	require.EqualValues(t, "3.2", report.Checks["migrations"].Detail)
+
+	release := make(chan struct{})
+	defer close(release)
+	// the check ignores its deadline
+	Register("mailer", func(ctx context.Context) (string, error) {
+		<-release
+		return "", nil
+	})
+	Register("blob", func(ctx context.Context) (string, error) {
+		return "", errors.New("connection refused")
+	})
+	start := time.Now()
+	report = Run(context.Background())
+	require.Less(t, time.Since(start), time.Second)
+	require.EqualValues(t, StatusDown, report.Status)
+	require.EqualValues(t, &Result{Status: StatusDown, Error: "connection refused"},
+		&Result{Status: report.Checks["blob"].Status, Error: report.Checks["blob"].Error})
+	require.EqualValues(t, StatusDown, report.Checks["mailer"].Status)
+	require.EqualValues(t, "context deadline exceeded", report.Checks["mailer"].Error)
+	require.GreaterOrEqual(t, report.Checks["mailer"].Latency, float64(20))
+	require.EqualValues(t, StatusUp, report.Checks["db"].Status)
+
+	SetShuttingDown()
+	require.True(t, ShuttingDown())
+	require.EqualValues(t, StatusShuttingDown, Run(context.Background()).Status)
+}
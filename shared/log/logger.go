@@ -1,6 +1,7 @@
 package log
 
 import (
+	"fmt"
 	"io"
 	"os"
 	"time"
@@ -65,11 +66,11 @@ var mapStringLogOutput = map[string]io.Writer{
 }
 
 type Config struct {
-	Level      string `config:"level"`
-	Output     string `config:"output"`
-	MaxBackups int    `config:"max_backups"`
-	MaxSize    int    `config:"max_size"`
-	MaxAge     int    `config:"max_age"`
+	Level      string `config:"level" default:"debug" validate:"omitempty,oneof=trace debug info warn error fatal"`
+	Output     string `config:"output" default:"console"`
+	MaxBackups int    `config:"max_backups" validate:"gte=0"`
+	MaxSize    int    `config:"max_size" default:"300" validate:"gte=0"`
+	MaxAge     int    `config:"max_age" validate:"gte=0"`
 }
 
 func New(c *Config) zerolog.Logger {
@@ -80,15 +81,33 @@ func New(c *Config) zerolog.Logger {
 	if output == nil {
 		output = fileLogWriter(c.Output, c.MaxSize, c.MaxAge, c.MaxBackups)
 	}
-	level := mapStringLogLevel[c.Level]
-	if level == 0 {
+	level, err := ParseLevel(c.Level)
+	if err != nil {
 		level = zerolog.DebugLevel
 	}
+	// the level is the global one, so that SetLevel applies to the loggers derived from Logger as well
 	Logger = zerolog.New(output).
 		With().
 		Timestamp().
-		Logger().
-		Level(mapStringLogLevel[c.Level])
+		Logger()
+	SetLevel(level)
 
 	return Logger
 }
+
+// ParseLevel the level named by level, default: debug
+func ParseLevel(level string) (zerolog.Level, error) {
+	if level == "" {
+		return zerolog.DebugLevel, nil
+	}
+	l, ok := mapStringLogLevel[level]
+	if !ok {
+		return zerolog.NoLevel, fmt.Errorf("unknown log level %q", level)
+	}
+	return l, nil
+}
+
+// SetLevel set the level of all the loggers
+func SetLevel(level zerolog.Level) {
+	zerolog.SetGlobalLevel(level)
+}
@@ -0,0 +1,212 @@
+package mailtemplate
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+)
+
+// Message names
+const (
+	ActivateAccount = "activate_account"
+	ResetPassword   = "reset_password"
+	NewSignIn       = "new_sign_in"
+)
+
+const (
+	fallbackLocale = "en"
+	layoutFile     = "layout.html"
+	subjectFile    = "subject.txt"
+	htmlFile       = "body.html"
+	textFile       = "body.txt"
+)
+
+var ErrNotFound = errors.New("email template not found")
+
+//go:embed templates
+var templateFS embed.FS
+
+// Data is the data passed to every template.
+type Data struct {
+	Locale        string
+	Subject       string
+	To            string
+	Name          string
+	ResetPassword string
+	ActiveUser    string
+	RevokeSession string
+	Values        map[string]string
+}
+
+// Message is a rendered email.
+type Message struct {
+	Name    string `json:"name"`
+	Locale  string `json:"locale"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text"`
+}
+
+// Registry resolves templates by message name and locale. Every file is looked
+// up in the override directory first, then in the embedded templates, so a
+// theme (layout.html) or a single translation can be replaced at runtime.
+type Registry struct {
+	sources       []fs.FS
+	defaultLocale string
+}
+
+// New creates a registry, overrideDir may be empty.
+func New(overrideDir, defaultLocale string) *Registry {
+	embedded, _ := fs.Sub(templateFS, "templates")
+
+	var sources []fs.FS
+	if overrideDir != "" {
+		sources = append(sources, os.DirFS(overrideDir))
+	}
+	sources = append(sources, embedded)
+
+	if defaultLocale == "" {
+		defaultLocale = fallbackLocale
+	}
+
+	return &Registry{
+		sources:       sources,
+		defaultLocale: normalizeLocale(defaultLocale),
+	}
+}
+
+// Names lists every message name known by the registry.
+func (r *Registry) Names() []string {
+	var seen = map[string]bool{}
+	var names []string
+	for _, src := range r.sources {
+		entries, err := fs.ReadDir(src, ".")
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if e.IsDir() && !seen[e.Name()] {
+				seen[e.Name()] = true
+				names = append(names, e.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Render renders the message name in the closest available locale.
+func (r *Registry) Render(name, locale string, data Data) (*Message, error) {
+	locale, ok := r.resolveLocale(name, locale)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	data.Locale = locale
+
+	subject, err := r.renderText(path.Join(name, locale, subjectFile), data)
+	if err != nil {
+		return nil, err
+	}
+	data.Subject = strings.TrimSpace(subject)
+
+	html, err := r.renderHTML(path.Join(name, locale, htmlFile), data)
+	if err != nil {
+		return nil, err
+	}
+
+	text, err := r.renderText(path.Join(name, locale, textFile), data)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	return &Message{
+		Name:    name,
+		Locale:  locale,
+		Subject: data.Subject,
+		HTML:    html,
+		Text:    text,
+	}, nil
+}
+
+// resolveLocale tries the requested locale, its base language, then the default locale.
+func (r *Registry) resolveLocale(name, locale string) (string, bool) {
+	locale = normalizeLocale(locale)
+	candidates := []string{locale}
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		candidates = append(candidates, locale[:idx])
+	}
+	candidates = append(candidates, r.defaultLocale, fallbackLocale)
+
+	for _, c := range candidates {
+		if c == "" {
+			continue
+		}
+		if _, err := r.readFile(path.Join(name, c, subjectFile)); err == nil {
+			return c, true
+		}
+	}
+	return "", false
+}
+
+func (r *Registry) readFile(name string) ([]byte, error) {
+	var err error
+	for _, src := range r.sources {
+		var bs []byte
+		if bs, err = fs.ReadFile(src, name); err == nil {
+			return bs, nil
+		}
+	}
+	return nil, err
+}
+
+func (r *Registry) renderText(name string, data Data) (string, error) {
+	bs, err := r.readFile(name)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := texttemplate.New(name).Parse(string(bs))
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func (r *Registry) renderHTML(name string, data Data) (string, error) {
+	layout, err := r.readFile(layoutFile)
+	if err != nil {
+		return "", err
+	}
+	body, err := r.readFile(name)
+	if err != nil {
+		return "", err
+	}
+
+	tmpl, err := htmltemplate.New(layoutFile).Parse(string(layout))
+	if err != nil {
+		return "", err
+	}
+	if _, err = tmpl.New("content").Parse(string(body)); err != nil {
+		return "", err
+	}
+
+	var out bytes.Buffer
+	if err = tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func normalizeLocale(locale string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+}
@@ -0,0 +1,90 @@
+package mailtemplate
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Render(t *testing.T) {
+	overrideDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(overrideDir, ResetPassword, "vi"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(overrideDir, ResetPassword, "vi", subjectFile), []byte("Overridden {{ .To }}"), 0o644))
+
+	tests := []struct {
+		name    string
+		message string
+		locale  string
+		expect  func(r *require.Assertions, msg *Message, err error)
+	}{
+		{
+			name:    "exact locale",
+			message: ActivateAccount,
+			locale:  "vi",
+			expect: func(r *require.Assertions, msg *Message, err error) {
+				r.NoError(err)
+				r.EqualValues("vi", msg.Locale)
+				r.EqualValues("Kích hoạt tài khoản của bạn", msg.Subject)
+				r.True(strings.Contains(msg.HTML, "<html lang=\"vi\">"))
+				r.True(strings.Contains(msg.Text, "jane.doe@example.com"))
+			},
+		},
+		{
+			name:    "region falls back to language",
+			message: ActivateAccount,
+			locale:  "vi_VN",
+			expect: func(r *require.Assertions, msg *Message, err error) {
+				r.NoError(err)
+				r.EqualValues("vi", msg.Locale)
+			},
+		},
+		{
+			name:    "unknown locale falls back to default",
+			message: ResetPassword,
+			locale:  "fr",
+			expect: func(r *require.Assertions, msg *Message, err error) {
+				r.NoError(err)
+				r.EqualValues("en", msg.Locale)
+				r.EqualValues("Reset Password", msg.Subject)
+			},
+		},
+		{
+			name:    "override directory wins",
+			message: ResetPassword,
+			locale:  "vi",
+			expect: func(r *require.Assertions, msg *Message, err error) {
+				r.NoError(err)
+				r.EqualValues("Overridden jane.doe@example.com", msg.Subject)
+				r.True(strings.Contains(msg.HTML, "Đặt lại mật khẩu"))
+			},
+		},
+		{
+			name:    "message values",
+			message: NewSignIn,
+			locale:  "en",
+			expect: func(r *require.Assertions, msg *Message, err error) {
+				r.NoError(err)
+				r.True(strings.Contains(msg.Text, "Chrome on Windows"))
+				r.True(strings.Contains(msg.HTML, "https://example.com/auth/revoke-session?jt=sample-token"))
+			},
+		},
+		{
+			name:    "unknown message",
+			message: "unknown",
+			locale:  "en",
+			expect: func(r *require.Assertions, msg *Message, err error) {
+				r.ErrorIs(err, ErrNotFound)
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := New(overrideDir, "en")
+			msg, err := registry.Render(tt.message, tt.locale, SampleData(tt.message))
+			tt.expect(require.New(t), msg, err)
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package mailtemplate
+
+// sampleValues holds the message specific values used for previews.
+var sampleValues = map[string]map[string]string{
+	NewSignIn: {
+		"Device": "Chrome on Windows",
+		"IP":     "203.0.113.7",
+		"Time":   "2006-01-02 15:04:05 UTC",
+	},
+}
+
+// SampleData returns placeholder data to preview the message name.
+func SampleData(name string) Data {
+	return Data{
+		To:            "jane.doe@example.com",
+		Name:          "Jane Doe",
+		ResetPassword: "https://example.com/auth/forgot-password?jt=sample-token",
+		ActiveUser:    "https://example.com/auth/activate-password?jt=sample-token",
+		RevokeSession: "https://example.com/auth/revoke-session?jt=sample-token",
+		Values:        sampleValues[name],
+	}
+}
@@ -0,0 +1,111 @@
+// Package metrics the Prometheus metrics of the service, served by /metrics
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "msf"
+
+var registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "The HTTP requests by method, route pattern and status.",
+	}, []string{"method", "route", "status"})
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "The time to handle the HTTP requests by method, route pattern and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+	logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_logins_total",
+		Help:      "The login attempts by result (success, failure) and reason.",
+	}, []string{"result", "reason"})
+	tokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_tokens_issued_total",
+		Help:      "The tokens issued by kind: access, impersonation or the action of the action tokens.",
+	}, []string{"kind"})
+	permissionDenials = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_permission_denied_total",
+		Help:      "The requests denied by the permission they require, admin for the administrators only.",
+	}, []string{"permission"})
+	emails = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "emails_total",
+		Help:      "The emails by template and outcome (sent, render_error, send_error).",
+	}, []string{"template", "outcome"})
+	dbQueries = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "The time of the database queries by database, operation and table.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"db", "operation", "table"})
+)
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests, httpDuration, logins, tokens, permissionDenials, emails, dbQueries,
+	)
+}
+
+// Handler serve the metrics in the Prometheus exposition format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
+
+// ObserveRequest record a request to route, the chi pattern of its path, e.g. /users/{id:[0-9]+}
+func ObserveRequest(method, route string, status int, d time.Duration) {
+	code := strconv.Itoa(status)
+	httpRequests.WithLabelValues(method, route, code).Inc()
+	httpDuration.WithLabelValues(method, route, code).Observe(d.Seconds())
+}
+
+// CountLogin record a login attempt, reason is the outcome recorded in the login history
+func CountLogin(success bool, reason string) {
+	result := "failure"
+	if success {
+		result = "success"
+	}
+	logins.WithLabelValues(result, reason).Inc()
+}
+
+// CountToken record a token issued
+func CountToken(kind string) {
+	tokens.WithLabelValues(kind).Inc()
+}
+
+// CountPermissionDenied record a request denied for lack of permission
+func CountPermissionDenied(permission string) {
+	permissionDenials.WithLabelValues(permission).Inc()
+}
+
+// CountEmail record the outcome of an email
+func CountEmail(template, outcome string) {
+	emails.WithLabelValues(template, outcome).Inc()
+}
+
+// ObserveQuery record a query of the database name, the primary or a replica
+func ObserveQuery(name, operation, table string, d time.Duration) {
+	dbQueries.WithLabelValues(name, operation, table).Observe(d.Seconds())
+}
+
+// RegisterDB report the statistics of the connection pool of the database name, the primary or a replica
+func RegisterDB(name string, db *sql.DB) {
+	registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/tpp/msf/model"
+)
+
+var (
+	userAgentOS = []struct{ token, name string }{
+		{"iPhone", "iPhone"},
+		{"iPad", "iPad"},
+		{"Android", "Android"},
+		{"Windows", "Windows"},
+		{"Mac OS X", "macOS"},
+		{"CrOS", "ChromeOS"},
+		{"Linux", "Linux"},
+	}
+	// order matters, Edge and Opera also advertise Chrome, Chrome also advertises Safari
+	userAgentBrowser = []struct{ token, name string }{
+		{"Edg/", "Edge"},
+		{"OPR/", "Opera"},
+		{"Firefox/", "Firefox"},
+		{"Chrome/", "Chrome"},
+		{"Safari/", "Safari"},
+		{"curl/", "curl"},
+		{"PostmanRuntime/", "Postman"},
+	}
+)
+
+// DeviceFromUserAgent describe the device of a user agent as "<browser> on <os>"
+func DeviceFromUserAgent(userAgent string) string {
+	var os, browser string
+	for _, o := range userAgentOS {
+		if strings.Contains(userAgent, o.token) {
+			os = o.name
+			break
+		}
+	}
+	for _, b := range userAgentBrowser {
+		if strings.Contains(userAgent, b.token) {
+			browser = b.name
+			break
+		}
+	}
+
+	switch {
+	case browser != "" && os != "":
+		return browser + " on " + os
+	case browser != "":
+		return browser
+	case os != "":
+		return os
+	default:
+		return "Unknown device"
+	}
+}
+
+// ClientFromRequest extract the client information of a request
+func ClientFromRequest(r *http.Request) model.ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return model.ClientInfo{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+}
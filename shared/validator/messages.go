@@ -0,0 +1,151 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLocale the locale of the messages when the requested one is not translated
+const DefaultLocale = "en"
+
+// fallbackTag the message of the tags without translation
+const fallbackTag = "fallback"
+
+// messages the validation messages by locale and tag, {param} is replaced by the param of the tag and {value}
+// by the value of the field. The .len messages are the ones of string, slice and map fields, for which
+// the comparison tags constrain the length.
+var messages = map[string]map[string]string{
+	"en": {
+		fallbackTag:          "This field is invalid",
+		"required":           "This field is required",
+		"required_with":      "This field is required",
+		"required_if":        "This field is required",
+		"file":               "the file does not exist",
+		"ip|cidr":            "invalid IP or CIDR",
+		"email":              "invalid email format",
+		"url":                "invalid URL format",
+		"uuid":               "invalid UUID format",
+		"alpha":              "invalid alphabetic format",
+		"alphanum":           "invalid alphanumeric format",
+		"numeric":            "This field must be numeric",
+		"bcp47_language_tag": "invalid language tag",
+		"datetime":           "This field must match the format '{param}'",
+		"oneof":              "This field must be one of '{param}'",
+		"oneoffield":         "not support for value '{value}'",
+		"eq":                 "This field must be equal to '{param}'",
+		"ne":                 "This field must not be equal to '{param}'",
+		"lt":                 "This field value must be less than {param}",
+		"lt.len":             "This field value length must be less than {param}",
+		"lte":                "This field value must be less than or equal to {param}",
+		"lte.len":            "This field value length must be less than or equal to {param}",
+		"gt":                 "This field value must be greater than {param}",
+		"gt.len":             "This field value length must be greater than {param}",
+		"gte":                "This field value must be greater than or equal to {param}",
+		"gte.len":            "This field value length must be greater than or equal to {param}",
+		"min":                "This field value must be at least {param}",
+		"min.len":            "This field value length must be at least {param}",
+		"max":                "This field value must be at most {param}",
+		"max.len":            "This field value length must be at most {param}",
+		"len":                "This field value must be {param}",
+		"len.len":            "This field value length must be {param}",
+	},
+	"vi": {
+		fallbackTag:          "Trường này không hợp lệ",
+		"required":           "Trường này là bắt buộc",
+		"required_with":      "Trường này là bắt buộc",
+		"required_if":        "Trường này là bắt buộc",
+		"file":               "Tệp không tồn tại",
+		"ip|cidr":            "IP hoặc CIDR không hợp lệ",
+		"email":              "Email không đúng định dạng",
+		"url":                "URL không đúng định dạng",
+		"uuid":               "UUID không đúng định dạng",
+		"alpha":              "Trường này chỉ được chứa chữ cái",
+		"alphanum":           "Trường này chỉ được chứa chữ cái và chữ số",
+		"numeric":            "Trường này phải là số",
+		"bcp47_language_tag": "Mã ngôn ngữ không hợp lệ",
+		"datetime":           "Trường này phải đúng định dạng '{param}'",
+		"oneof":              "Trường này phải là một trong '{param}'",
+		"oneoffield":         "Không hỗ trợ giá trị '{value}'",
+		"eq":                 "Trường này phải bằng '{param}'",
+		"ne":                 "Trường này không được bằng '{param}'",
+		"lt":                 "Giá trị phải nhỏ hơn {param}",
+		"lt.len":             "Độ dài phải nhỏ hơn {param}",
+		"lte":                "Giá trị phải nhỏ hơn hoặc bằng {param}",
+		"lte.len":            "Độ dài phải nhỏ hơn hoặc bằng {param}",
+		"gt":                 "Giá trị phải lớn hơn {param}",
+		"gt.len":             "Độ dài phải lớn hơn {param}",
+		"gte":                "Giá trị phải lớn hơn hoặc bằng {param}",
+		"gte.len":            "Độ dài phải lớn hơn hoặc bằng {param}",
+		"min":                "Giá trị tối thiểu là {param}",
+		"min.len":            "Độ dài tối thiểu là {param}",
+		"max":                "Giá trị tối đa là {param}",
+		"max.len":            "Độ dài tối đa là {param}",
+		"len":                "Giá trị phải bằng {param}",
+		"len.len":            "Độ dài phải bằng {param}",
+	},
+}
+
+// Message the message of fe in the closest translated locale
+func Message(locale string, fe FieldError) string {
+	catalog := messages[MatchLocale(locale)]
+
+	msg, ok := "", false
+	switch fe.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		msg, ok = catalog[fe.Tag()+".len"]
+	}
+	if !ok {
+		msg, ok = catalog[fe.Tag()]
+	}
+	if !ok {
+		msg = catalog[fallbackTag]
+	}
+	return strings.NewReplacer("{param}", fe.Param(), "{value}", fmt.Sprint(fe.Value())).Replace(msg)
+}
+
+// MatchLocale the translated locale closest to locale, e.g. vi-VN falls back to vi, then to the default locale
+func MatchLocale(locale string) string {
+	locale = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	if _, ok := messages[locale]; ok {
+		return locale
+	}
+	if idx := strings.Index(locale, "-"); idx > 0 {
+		if _, ok := messages[locale[:idx]]; ok {
+			return locale[:idx]
+		}
+	}
+	return DefaultLocale
+}
+
+// NegotiateLocale the translated locale the most preferred by an Accept-Language header, empty if none is
+func NegotiateLocale(acceptLanguage string) string {
+	type weighted struct {
+		locale string
+		q      float64
+	}
+	var locales []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		locale, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			var err error
+			if q, err = strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err != nil {
+				continue
+			}
+		}
+		if locale != "" && locale != "*" && q > 0 {
+			locales = append(locales, weighted{locale: locale, q: q})
+		}
+	}
+	sort.SliceStable(locales, func(i, j int) bool { return locales[i].q > locales[j].q })
+
+	for _, l := range locales {
+		if matched := MatchLocale(l.locale); matched != DefaultLocale || strings.HasPrefix(strings.ToLower(l.locale), DefaultLocale) {
+			return matched
+		}
+	}
+	return ""
+}
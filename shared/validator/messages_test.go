@@ -0,0 +1,89 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessage(t *testing.T) {
+	type req struct {
+		Name   string `schema:"name" validate:"required"`
+		Code   string `schema:"code" validate:"gte=3"`
+		Limit  int    `schema:"limit" validate:"gte=1"`
+		Mode   string `schema:"mode" validate:"oneof=offset cursor"`
+		Site   string `schema:"site" validate:"url"`
+		Serial string `schema:"serial" validate:"hexadecimal"`
+	}
+	tests := []struct {
+		name   string
+		locale string
+		want   map[string]string
+	}{
+		{
+			"en",
+			"en",
+			map[string]string{
+				"name":   "This field is required",
+				"code":   "This field value length must be greater than or equal to 3",
+				"limit":  "This field value must be greater than or equal to 1",
+				"mode":   "This field must be one of 'offset cursor'",
+				"site":   "invalid URL format",
+				"serial": "This field is invalid",
+			},
+		},
+		{
+			"region falls back to language",
+			"vi-VN",
+			map[string]string{
+				"name":   "Trường này là bắt buộc",
+				"code":   "Độ dài phải lớn hơn hoặc bằng 3",
+				"limit":  "Giá trị phải lớn hơn hoặc bằng 1",
+				"mode":   "Trường này phải là một trong 'offset cursor'",
+				"site":   "URL không đúng định dạng",
+				"serial": "Trường này không hợp lệ",
+			},
+		},
+		{
+			"untranslated falls back to default",
+			"fr",
+			map[string]string{
+				"name": "This field is required",
+			},
+		},
+	}
+	err := Get().Struct(&req{Code: "ab", Mode: "page", Site: "not a url", Serial: "xyz"})
+	var ve ValidationErrors
+	assert.True(t, errors.As(err, &ve))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, fe := range ve {
+				if want, ok := tt.want[fe.Field()]; ok {
+					assert.Equal(t, want, Message(tt.locale, fe), fe.Field())
+				}
+			}
+		})
+	}
+}
+
+func TestNegotiateLocale(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		want           string
+	}{
+		{"empty", "", ""},
+		{"exact", "vi", "vi"},
+		{"region", "vi-VN,vi;q=0.9", "vi"},
+		{"q-values", "en;q=0.5, vi;q=0.8", "vi"},
+		{"skip untranslated", "fr-CH, fr;q=0.9, en;q=0.8", "en"},
+		{"none translated", "fr, de;q=0.5", ""},
+		{"excluded", "vi;q=0, en-US;q=0.2", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, NegotiateLocale(tt.acceptLanguage))
+		})
+	}
+}
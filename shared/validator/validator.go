@@ -60,20 +60,28 @@ func oneOfField(fl validator.FieldLevel) bool {
 	}
 }
 
-// schemaTagName for register new name field by tag
-func schemaTagName(fld reflect.StructField) string {
-	name := fld.Tag.Get("schema")
-	if name != "" {
-		return name
+// tagName for register new name field by tag
+func tagName(tag string) validator.TagNameFunc {
+	return func(fld reflect.StructField) string {
+		name := fld.Tag.Get(tag)
+		if name != "" {
+			return name
+		}
+		return fld.Name
 	}
-	return fld.Name
 }
 
-func init() {
-	v = validator.New()
+// New a validator naming the fields by tag, e.g. schema for the requests and config for the configuration
+func New(tag string) *validator.Validate {
+	v := validator.New()
 	// register custom validator
-	v.RegisterTagNameFunc(schemaTagName)
+	v.RegisterTagNameFunc(tagName(tag))
 	v.RegisterValidation("oneoffield", oneOfField, false)
+	return v
+}
+
+func init() {
+	v = New("schema")
 }
 
 type ValidationErrors = validator.ValidationErrors
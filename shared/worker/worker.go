@@ -0,0 +1,62 @@
+// Package worker runs the background workers of the service, they are stopped and awaited on shutdown
+package worker
+
+import (
+	"sync"
+	"time"
+
+	"github.com/tpp/msf/shared/context"
+	"github.com/tpp/msf/shared/log"
+)
+
+var (
+	// stopping done once Stop is called
+	stopping, stop = context.WithCancel(context.Background())
+	running        sync.WaitGroup
+)
+
+// Go run fn in the background, ctx is done once the workers are stopped, fn must return then
+func Go(name string, fn func(ctx context.Context)) {
+	running.Add(1)
+	go func() {
+		defer running.Done()
+		defer func() {
+			if r := recover(); r != nil {
+				log.Logger.Error().Str("worker", name).Interface("panic", r).Msg("WorkerPanic")
+			}
+		}()
+		fn(stopping)
+	}()
+}
+
+// Every run fn every interval in the background until the workers are stopped, a run in progress is awaited
+func Every(name string, interval time.Duration, fn func(ctx context.Context)) {
+	Go(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				fn(ctx)
+			}
+		}
+	})
+}
+
+// Stop signal the workers to stop and wait for them, at most until ctx is done
+func Stop(ctx context.Context) error {
+	stop()
+	done := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
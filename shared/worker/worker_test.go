@@ -0,0 +1,37 @@
+package worker
+
+import (
+	stdcontext "context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/tpp/msf/shared/context"
+)
+
+func TestStop(t *testing.T) {
+	var runs int32
+	Every("count", time.Millisecond, func(ctx context.Context) {
+		atomic.AddInt32(&runs, 1)
+	})
+	release := make(chan struct{})
+	Go("drain", func(ctx context.Context) {
+		<-ctx.Done()
+		<-release
+	})
+	Go("panic", func(ctx context.Context) {
+		panic("worker failed")
+	})
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&runs) > 0 }, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, Stop(ctx), stdcontext.DeadlineExceeded)
+
+	close(release)
+	require.NoError(t, Stop(context.Background()))
+	stopped := atomic.LoadInt32(&runs)
+	time.Sleep(5 * time.Millisecond)
+	require.EqualValues(t, stopped, atomic.LoadInt32(&runs))
+}
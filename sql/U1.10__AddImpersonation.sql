@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS sessions_impersonator_id_idx;
+
+ALTER TABLE sessions DROP COLUMN IF EXISTS impersonator_id;
+
+DELETE FROM permision_role WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'IMPERSONATE_USER');
+
+DELETE FROM permissions WHERE name = 'IMPERSONATE_USER';
@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS contract_attachments;
+
+DELETE FROM permision_role WHERE permission_id IN (SELECT id FROM permissions WHERE name = 'MANAGE_CONTRACT_ATTACHMENT');
+
+DELETE FROM permissions WHERE name = 'MANAGE_CONTRACT_ATTACHMENT';
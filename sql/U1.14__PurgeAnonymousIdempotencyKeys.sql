@@ -0,0 +1,2 @@
+-- the purged replays are not restored, the anonymous requests retried run again
+SELECT 1;
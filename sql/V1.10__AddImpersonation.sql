@@ -0,0 +1,9 @@
+SELECT pg_catalog.setval('permissions_id_seq', (SELECT COALESCE(MAX(id), 0) + 1 FROM permissions), false);
+
+INSERT INTO permissions (name)
+SELECT 'IMPERSONATE_USER'
+WHERE NOT EXISTS (SELECT 1 FROM permissions WHERE name = 'IMPERSONATE_USER');
+
+ALTER TABLE sessions add IF NOT EXISTS impersonator_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS sessions_impersonator_id_idx ON sessions (impersonator_id);
@@ -0,0 +1,26 @@
+CREATE TABLE IF NOT EXISTS contract_attachments (
+                                                    id              SERIAL PRIMARY KEY,
+                                                    contract_id     INTEGER NOT NULL REFERENCES contracts (id) ON DELETE CASCADE,
+                                                    name            VARCHAR(256) NOT NULL,
+                                                    content_type    VARCHAR(256) NOT NULL,
+                                                    size            BIGINT NOT NULL,
+                                                    checksum        VARCHAR(64) NOT NULL,
+                                                    blob_key        VARCHAR(1024) NOT NULL UNIQUE,
+                                                    uploaded_by     INTEGER,
+                                                    created_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS contract_attachments_contract_id_idx ON contract_attachments (contract_id);
+
+SELECT pg_catalog.setval('permissions_id_seq', (SELECT COALESCE(MAX(id), 0) + 1 FROM permissions), false);
+
+INSERT INTO permissions (name) VALUES ('MANAGE_CONTRACT_ATTACHMENT') ON CONFLICT DO NOTHING;
+
+-- the roles viewing contracts attach their documents
+INSERT INTO permision_role (role_id, permission_id)
+SELECT DISTINCT pr.role_id, p.id
+FROM permision_role pr
+         INNER JOIN permissions v ON v.id = pr.permission_id AND v.name IN ('VIEW_ALL_CONTRACT_LIST', 'VIEW_CONTRACT_LIST')
+         CROSS JOIN permissions p
+WHERE p.name = 'MANAGE_CONTRACT_ATTACHMENT'
+  AND NOT EXISTS (SELECT 1 FROM permision_role e WHERE e.role_id = pr.role_id AND e.permission_id = p.id);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+                                                id              SERIAL PRIMARY KEY,
+                                                user_id         INTEGER NOT NULL DEFAULT 0,
+                                                key             VARCHAR(255) NOT NULL,
+                                                fingerprint     VARCHAR(64) NOT NULL,
+                                                status          INTEGER NOT NULL DEFAULT 0,
+                                                content_type    VARCHAR(256) NOT NULL DEFAULT '',
+                                                body            BYTEA,
+                                                created_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT now(),
+                                                expires_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idempotency_keys_user_id_key_idx ON idempotency_keys (user_id, key);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
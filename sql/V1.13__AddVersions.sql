@@ -0,0 +1,3 @@
+ALTER TABLE users add IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE roles add IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE contracts add IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
@@ -0,0 +1,2 @@
+-- the replayed responses of the anonymous requests, e.g. the access tokens of the logins
+DELETE FROM idempotency_keys WHERE user_id = 0;
@@ -0,0 +1 @@
+ALTER TABLE users  add IF NOT EXISTS locale VARCHAR(16) NOT NULL DEFAULT '';
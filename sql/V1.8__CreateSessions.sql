@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS sessions (
+                                        id              SERIAL PRIMARY KEY,
+                                        token_id        VARCHAR(64) UNIQUE NOT NULL,
+                                        user_id         INTEGER NOT NULL,
+                                        device          VARCHAR(256) NOT NULL DEFAULT '',
+                                        user_agent      VARCHAR(1024) NOT NULL DEFAULT '',
+                                        ip              VARCHAR(64) NOT NULL DEFAULT '',
+                                        created_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT now(),
+                                        last_seen_at    TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT now(),
+                                        expires_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL,
+                                        revoked_at      TIMESTAMP WITHOUT TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS sessions_user_id_idx ON sessions (user_id);
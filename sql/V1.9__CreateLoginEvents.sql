@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS login_events (
+                                            id              SERIAL PRIMARY KEY,
+                                            user_id         INTEGER,
+                                            email           VARCHAR(1024) NOT NULL,
+                                            session_id      INTEGER,
+                                            ip              VARCHAR(64) NOT NULL DEFAULT '',
+                                            user_agent      VARCHAR(1024) NOT NULL DEFAULT '',
+                                            device          VARCHAR(256) NOT NULL DEFAULT '',
+                                            success         BOOLEAN NOT NULL,
+                                            reason          VARCHAR(64) NOT NULL,
+                                            created_at      TIMESTAMP WITHOUT TIME ZONE NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS login_events_user_id_created_at_idx ON login_events (user_id, created_at);
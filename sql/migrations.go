@@ -0,0 +1,9 @@
+// Package sql the migrations of the schema, applied by external-adapter/db/migrate
+package sql
+
+import "embed"
+
+// Migrations the V<version>__<description>.sql scripts migrating the schema and the U ones undoing them
+//
+//go:embed *.sql
+var Migrations embed.FS
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"time"
+
+	"github.com/tpp/msf/domain/usecase/users"
+	"github.com/tpp/msf/external-adapter/db"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/context"
+)
+
+type tokenIssueArgs struct {
+	UserID uint64        `schema:"user" validate:"required"`
+	TTL    time.Duration `schema:"ttl" validate:"gte=0"`
+}
+
+type tokenIssueResult struct {
+	AccessToken string         `json:"access_token"`
+	Session     *model.Session `json:"session"`
+}
+
+// runTokenIssue issue an access token of an user without its password, for debugging. The token opens a session
+// listed and revoked like the ones of the logins.
+func runTokenIssue(cfg *Config, args []string) int {
+	var a tokenIssueArgs
+	fs, p := newFlagSet("token issue", "--user <user id> [--ttl <duration>]")
+	fs.Uint64Var(&a.UserID, "user", 0, "the id of the user")
+	fs.DurationVar(&a.TTL, "ttl", 0, "the lifetime of the token, e.g. 30m, default: the one of the logins")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := validate(&a); err != nil {
+		return p.fail(err)
+	}
+	if err := setup(func() { db.New(cfg.DB) }); err != nil {
+		return p.fail(err)
+	}
+
+	usecase := users.New()
+	var res tokenIssueResult
+	err := inUnitOfWork(func(ctx context.Context) (err error) {
+		res.Session, res.AccessToken, err = usecase.IssueToken(ctx, a.UserID, a.TTL, model.ClientInfo{UserAgent: "msf-cli"})
+		return userNotFound(err, a.UserID)
+	})
+	if err != nil {
+		return p.fail(err)
+	}
+	return p.result(res, "%s", res.AccessToken)
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rs/xid"
+	"github.com/tpp/msf/domain/usecase/users"
+	"github.com/tpp/msf/external-adapter/db"
+	mailer "github.com/tpp/msf/external-adapter/mailer"
+	"github.com/tpp/msf/model"
+	"github.com/tpp/msf/shared/base"
+	"github.com/tpp/msf/shared/context"
+	"gorm.io/gorm"
+)
+
+type userCreateArgs struct {
+	Email    string  `schema:"email" validate:"required,email"`
+	FullName string  `schema:"name" validate:"required"`
+	Locale   string  `schema:"locale" validate:"omitempty,bcp47_language_tag"`
+	Password string  `schema:"password" validate:"omitempty,gte=6"`
+	Admin    bool    `schema:"admin"`
+	RoleIDs  idsFlag `schema:"role"`
+}
+
+type userCreateResult struct {
+	ID      uint64  `json:"id"`
+	Email   string  `json:"email"`
+	IsAdmin bool    `json:"is_admin"`
+	Active  bool    `json:"active"`
+	RoleIDs []int64 `json:"role_ids"`
+}
+
+// runUserCreate create an user as POST /users does, the activation email included. With --password the user is
+// active right away, e.g. the first administrator of a database.
+func runUserCreate(cfg *Config, args []string) int {
+	var a userCreateArgs
+	fs, p := newFlagSet("user create", "--email <email> --name <full name> [--admin] [--role <id,...>] [--password <password>]")
+	fs.StringVar(&a.Email, "email", "", "the email of the user")
+	fs.StringVar(&a.FullName, "name", "", "the full name of the user")
+	fs.StringVar(&a.Locale, "locale", "", "the locale of the user, e.g. vi")
+	fs.StringVar(&a.Password, "password", "", "the password activating the user, otherwise the user activates the account from the email")
+	fs.BoolVar(&a.Admin, "admin", false, "create an administrator")
+	fs.Var(&a.RoleIDs, "role", "the ids of the roles assigned to the user, comma separated or repeated")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := validate(&a); err != nil {
+		return p.fail(err)
+	}
+	if err := setup(func() { db.New(cfg.DB) }, func() { mailer.NewMailer(cfg.Mailer) }); err != nil {
+		return p.fail(err)
+	}
+
+	user := &model.User{
+		FullName: a.FullName,
+		Email:    a.Email,
+		Locale:   a.Locale,
+		IsAdmin:  a.Admin,
+	}
+	usecase := users.New()
+	err := inUnitOfWork(func(ctx context.Context) error {
+		if err := usecase.CreateUser(ctx, user); err != nil {
+			return err
+		}
+		if len(a.RoleIDs) > 0 {
+			if err := usecase.AssignRole(ctx, int64(user.ID), a.RoleIDs); err != nil {
+				return err
+			}
+		}
+		if a.Password != "" {
+			return usecase.UpdatePassWord(ctx, int64(user.ID), a.Password)
+		}
+		return nil
+	})
+	if err != nil {
+		return p.fail(err)
+	}
+
+	res := userCreateResult{
+		ID:      user.ID,
+		Email:   user.Email,
+		IsAdmin: user.IsAdmin,
+		Active:  a.Password != "",
+		RoleIDs: a.RoleIDs,
+	}
+	return p.result(res, "user %d %s created", user.ID, user.Email)
+}
+
+type userResetPasswordArgs struct {
+	UserID   uint64 `schema:"id" validate:"required"`
+	Password string `schema:"password" validate:"omitempty,gte=6"`
+}
+
+type userResetPasswordResult struct {
+	ID uint64 `json:"id"`
+	// Password the generated password, empty when it is given by --password
+	Password string `json:"password,omitempty"`
+}
+
+// runUserResetPassword set the password of an user as PUT /users/reset-password does, which activates the user. A
+// password is generated and printed unless it is given.
+func runUserResetPassword(cfg *Config, args []string) int {
+	var a userResetPasswordArgs
+	fs, p := newFlagSet("user reset-password", "--id <user id> [--password <password>]")
+	fs.Uint64Var(&a.UserID, "id", 0, "the id of the user")
+	fs.StringVar(&a.Password, "password", "", "the new password, generated when it is not given")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+	if err := validate(&a); err != nil {
+		return p.fail(err)
+	}
+	if err := setup(func() { db.New(cfg.DB) }); err != nil {
+		return p.fail(err)
+	}
+
+	res := userResetPasswordResult{ID: a.UserID}
+	if a.Password == "" {
+		a.Password = xid.New().String()
+		res.Password = a.Password
+	}
+	usecase := users.New()
+	err := inUnitOfWork(func(ctx context.Context) error {
+		// the ETags of the user held by the clients are stale
+		if _, err := usecase.ClaimVersion(ctx, a.UserID, 0); err != nil {
+			return err
+		}
+		return usecase.UpdatePassWord(ctx, int64(a.UserID), a.Password)
+	})
+	if err != nil {
+		return p.fail(userNotFound(err, a.UserID))
+	}
+
+	if res.Password != "" {
+		return p.result(res, "the password of user %d is %s", a.UserID, res.Password)
+	}
+	return p.result(res, "the password of user %d is changed", a.UserID)
+}
+
+// userNotFound the error of the commands on a missing user
+func userNotFound(err error, userID uint64) error {
+	if errors.Is(err, base.ErrorNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("user %d does not exist", userID)
+	}
+	return err
+}
+
+// idsFlag a list of ids flag, comma separated or repeated
+type idsFlag []int64
+
+func (f *idsFlag) String() string {
+	var ids []string
+	for _, id := range *f {
+		ids = append(ids, strconv.FormatInt(id, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
+func (f *idsFlag) Set(value string) error {
+	for _, s := range strings.Split(value, ",") {
+		id, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+		if err != nil || id <= 0 {
+			return fmt.Errorf("invalid id %q", s)
+		}
+		*f = append(*f, id)
+	}
+	return nil
+}
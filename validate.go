@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/tpp/msf/config"
+	"github.com/tpp/msf/external-adapter/blob"
+	mailer "github.com/tpp/msf/external-adapter/mailer"
+)
+
+type configValidateResult struct {
+	Valid    bool     `json:"valid"`
+	Problems []string `json:"problems"`
+}
+
+// runConfigValidate check the configuration without connecting to the database, the mailer and the blob store are
+// built as serve builds them
+func runConfigValidate(cfg *Config, args []string) int {
+	fs, p := newFlagSet("config validate", "")
+	if code, ok := parseFlags(fs, args); !ok {
+		return code
+	}
+
+	problems := validateConfig(cfg)
+	res := configValidateResult{Valid: len(problems) == 0, Problems: problems}
+	if res.Valid {
+		return p.result(res, "the configuration is valid")
+	}
+	if p.json {
+		p.result(res, "")
+	} else {
+		for _, problem := range problems {
+			fmt.Fprintf(p.errOut, "error: %s\n", problem)
+		}
+	}
+	return exitFailure
+}
+
+// validateConfig the problems of cfg
+func validateConfig(cfg *Config) []string {
+	problems := append([]string{}, config.Validate(cfg)...)
+
+	if err := setup(func() { mailer.NewMailer(cfg.Mailer) }); err != nil {
+		problems = append(problems, fmt.Sprintf("mailer: %s", err))
+	}
+	if err := setup(func() { blob.New(cfg.Blob) }); err != nil {
+		problems = append(problems, fmt.Sprintf("blob: %s", err))
+	}
+	return problems
+}